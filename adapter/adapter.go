@@ -3,6 +3,7 @@ package adapter
 import (
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
+	"strings"
 )
 
 // Create a standard type all adapters must conform too.
@@ -21,11 +22,22 @@ type base struct {
 
 func newBase(s, adapterConfig *status.Status, adapterUrl string) (b base, e error) {
 	b = base{stoppable.NewBase(), s, adapterConfig, adapterUrl}
-	e = b.status.SetJson(b.adapterUrl, []byte(`{}`), status.UNCHECKED_REVISION)
+
+	// Keep existing contents, since they may have been restored from a saved
+	// snapshot.
+	current, _, e := b.status.Get(b.adapterUrl)
+	if _, ok := current.(map[string]interface{}); e != nil || !ok {
+		e = b.status.SetFrom(b.origin(), b.adapterUrl, map[string]interface{}{}, status.UNCHECKED_REVISION)
+	}
 
 	return
 }
 
+// The origin changes made by the adapter are attributed to.
+func (b *base) origin() string {
+	return "adapter:" + strings.TrimPrefix(b.adapterUrl, "status://")
+}
+
 // This is really only present for testing purposes.
 func newBaseAdapter(m *Manager, base base) (a adapter, e error) {
 	go base.Handler()
@@ -35,5 +47,5 @@ func newBaseAdapter(m *Manager, base base) (a adapter, e error) {
 // This creates a default Stop method for adapters.
 func (b *base) Stop() {
 	b.Base.Stop()
-	b.status.Set(b.adapterUrl, nil, status.UNCHECKED_REVISION)
+	b.status.SetFrom(b.origin(), b.adapterUrl, nil, status.UNCHECKED_REVISION)
 }
@@ -62,11 +62,13 @@ func (a *fileAdapter) loadFile() (e error) {
 	rawJson, e := ioutil.ReadFile(a.filename)
 	if e != nil {
 		// If we can't read the file, nil the contents.
-		a.status.Set(a.adapterUrl, nil, status.UNCHECKED_REVISION)
+		a.status.SetFrom(a.origin(), a.adapterUrl, nil, status.UNCHECKED_REVISION)
 		return e
 	}
 
-	return a.status.SetJson(a.adapterUrl, rawJson, status.UNCHECKED_REVISION)
+	return a.status.UpdateFrom(a.origin(), func(tx *status.Tx) error {
+		return tx.SetJson(a.adapterUrl, rawJson, status.UNCHECKED_REVISION)
+	})
 }
 
 func (a *fileAdapter) Handler() {
@@ -56,10 +56,14 @@ func (a *particleAdapter) Handler() {
 		panic(err)
 	}
 
-	// Create the root for the core devices we are about to discover.
-	err = a.status.SetJson(a.adapterUrl+"/core", []byte(`{}`), status.UNCHECKED_REVISION)
-	if err != nil {
-		panic(err)
+	// Create the root for the core devices we are about to discover. Keep any
+	// restored devices, so their event data survives a restart.
+	current, _, err := a.status.Get(a.adapterUrl + "/core")
+	if _, ok := current.(map[string]interface{}); err != nil || !ok {
+		err = a.status.SetFrom(a.origin(), a.adapterUrl+"/core", map[string]interface{}{}, status.UNCHECKED_REVISION)
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	deviceUpdates, events := a.ParticleApiInterface.Updates()
@@ -116,16 +120,20 @@ func (a particleAdapter) updateFromEvent(event particleapi.Event) {
 	//
 	event_url := device_url + "/details/events/" + event.Name
 
-	// Event data may be in JSON.
 	data_url := event_url + "/data"
-	a.status.SetJsonOrString(data_url, event.Data, status.UNCHECKED_REVISION)
-	a.status.Set(event_url+"/published", event.Published_at, status.UNCHECKED_REVISION)
 
 	//
 	// Publish event as device property.
 	//
 	property_url := device_url + "/" + event.Name
-	a.status.SetJsonOrString(property_url, event.Data, status.UNCHECKED_REVISION)
+
+	a.status.UpdateFrom(a.origin(), func(tx *status.Tx) error {
+		// Event data may be in JSON.
+		tx.SetJsonOrString(data_url, event.Data, status.UNCHECKED_REVISION)
+		tx.Set(event_url+"/published", event.Published_at, status.UNCHECKED_REVISION)
+		tx.SetJsonOrString(property_url, event.Data, status.UNCHECKED_REVISION)
+		return nil
+	})
 }
 
 func (a *particleAdapter) checkForTargetToFire(matches status.UrlMatches) {
@@ -158,7 +166,7 @@ func (a *particleAdapter) checkForTargetToFire(matches status.UrlMatches) {
 
 		// Clear the target value. Again, ignore error. The most likely cause
 		// is that someone else updated the target again, which doesn't bother us.
-		a.status.Set(target_url, nil, raw_value.Revision)
+		a.status.SetFrom(a.origin(), target_url, nil, raw_value.Revision)
 	}
 }
 
@@ -200,51 +208,73 @@ func (a particleAdapter) findDeviceUrl(id string) string {
 func (a particleAdapter) updateDeviceList(devices []particleapi.Device) {
 	core_url := a.adapterUrl + "/core"
 
-	// Remove any old cores that don't exist any more.
 	oldNames, _, err := a.status.GetChildNames(core_url)
 	if err != nil {
 		panic(err)
 	}
 
-OldNames:
-	for _, old := range oldNames {
-		for _, d := range devices {
-			if d.Name == old {
-				continue OldNames // This name still exists, leave it.
+	// Devices which just connected. They are refreshed only after the update
+	// is applied.
+	var connected []particleapi.Device
+
+	// Apply all changes as a single update, so watchers never see a partially
+	// updated device list.
+	err = a.status.UpdateFrom(a.origin(), func(tx *status.Tx) error {
+		connected = nil
+
+		// Remove any old cores that don't exist any more.
+	OldNames:
+		for _, old := range oldNames {
+			for _, d := range devices {
+				if d.Name == old {
+					continue OldNames // This name still exists, leave it.
+				}
+			}
+
+			if err := tx.Remove(core_url+"/"+old, status.UNCHECKED_REVISION); err != nil {
+				return err
 			}
 		}
 
-		err = a.status.Remove(core_url+"/"+old, status.UNCHECKED_REVISION)
-		if err != nil {
-			panic(err)
+		// Add/update devices that exist.
+		for _, d := range devices {
+			justConnected, err := a.updateDevice(tx, d)
+			if err != nil {
+				return err
+			}
+			if justConnected {
+				connected = append(connected, d)
+			}
 		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
 	}
 
-	// Add/update devices that exist.
-	for _, d := range devices {
-		a.updateDevice(d)
+	// Refresh is called for devices that just connected, and after server
+	// restart to allow current events to be resent.
+	for _, d := range connected {
+		a.callRefreshIfPresent(d)
 	}
 }
 
-func (a particleAdapter) updateDevice(device particleapi.Device) {
+// Update a device's details in tx. Returns true if the device just connected.
+func (a particleAdapter) updateDevice(tx *status.Tx, device particleapi.Device) (justConnected bool, e error) {
 	// Add/update devices that exist.
 	safeName := status.EscapeUriElement(device.Name)
 
 	device_details_url := a.adapterUrl + "/core" + "/" + safeName + "/details"
 
-	wasConnected := a.status.GetBoolWithDefault(device_details_url+"/connected", false)
+	connected, _, _ := tx.Get(device_details_url + "/connected")
+	wasConnected, _ := connected.(bool)
 
 	funcNames := make([]interface{}, len(device.Functions))
 	for i, name := range device.Functions {
 		funcNames[i] = name
 	}
 
-	if device.Connected && !wasConnected {
-		// Refresh is called for devices that just connected, and after server
-		// restart to allow current events to be resent.
-		a.callRefreshIfPresent(device)
-	}
-
 	deviceDetails := map[string]interface{}{
 		"id":         device.Id,
 		"last_heard": device.LastHeard,
@@ -254,17 +284,21 @@ func (a particleAdapter) updateDevice(device particleapi.Device) {
 	}
 
 	// If the device existed, and we had events for it, preserve them.
-	events, _, _ := a.status.Get(device_details_url + "/events")
+	events, _, _ := tx.Get(device_details_url + "/events")
 	if events != nil {
 		deviceDetails["events"] = events
 	}
 
-	err := a.status.Set(device_details_url, deviceDetails, status.UNCHECKED_REVISION)
-	if err != nil {
-		panic(err)
+	e = tx.Set(device_details_url, deviceDetails, status.UNCHECKED_REVISION)
+	if e != nil {
+		return false, e
+	}
+
+	if e = a.createEmptyTargets(tx, device); e != nil {
+		return false, e
 	}
 
-	a.createEmptyTargets(device)
+	return device.Connected && !wasConnected, nil
 }
 
 func (a particleAdapter) callRefreshIfPresent(device particleapi.Device) {
@@ -276,20 +310,27 @@ func (a particleAdapter) callRefreshIfPresent(device particleapi.Device) {
 	}
 }
 
-func (a particleAdapter) createEmptyTargets(device particleapi.Device) (e error) {
+func (a particleAdapter) createEmptyTargets(tx *status.Tx, device particleapi.Device) (e error) {
 	device_url := a.adapterUrl + "/core" + "/" + device.Name
 
 	for _, name := range device.Functions {
 		if strings.HasSuffix(name, "_target") {
 			target_url := device_url + "/" + name
-			// Ignore failures. They are expected if target existed.
-			_ = a.status.Set(target_url, nil, status.NONEXISTENT)
+
+			// Leave existing targets alone.
+			if _, _, e := tx.Get(target_url); e == nil {
+				continue
+			}
+
+			if e = tx.Set(target_url, nil, status.NONEXISTENT); e != nil {
+				return e
+			}
 		}
 	}
 	return nil
 }
 
-func (a particleAdapter) functionAction(s *status.Status, action *status.Status) (e error) {
+func (a particleAdapter) functionAction(s *status.Status, origin string, action *status.Status) (e error) {
 	device, _, err := action.GetString("status://device")
 	if err != nil {
 		return err
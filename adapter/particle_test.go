@@ -244,7 +244,7 @@ func (suite *MySuite) TestParticleAdapterAction(c *check.C) {
 		c.Assert(err, check.IsNil)
 
 		// Fire Action
-		adaptor.actionsMgr.FireAction(s, action)
+		adaptor.actionsMgr.FireAction(s, "test", action)
 		c.Check(mock.actionArgs, check.DeepEquals, mockFunctionCall{})
 	}
 
@@ -261,7 +261,7 @@ func (suite *MySuite) TestParticleAdapterAction(c *check.C) {
 		c.Assert(err, check.IsNil)
 
 		// Fire Action
-		adaptor.actionsMgr.FireAction(s, action)
+		adaptor.actionsMgr.FireAction(s, "test", action)
 		c.Check(mock.actionArgs, check.DeepEquals, mockFunctionCall{device, function, argument})
 	}
 
@@ -50,12 +50,8 @@ func newVeraAdapterDetailed(m *Manager, b base, vera_api veraapi.VeraApiInterfac
 }
 
 func (a *veraAdapter) Handler() {
-	// Create the root for the devices we are about to discover.
-	err := a.status.SetJson(a.adapterUrl, []byte(`{}`), status.UNCHECKED_REVISION)
-	if err != nil {
-		panic(err)
-	}
-
+	// newBase created the root for our devices, and may have restored devices
+	// from an earlier run, so leave existing values alone.
 	deviceUpdates := a.VeraApiInterface.Updates()
 
 	for {
@@ -100,7 +96,7 @@ func (a *veraAdapter) checkForTargetToFire(matches status.UrlMatches) {
 
 		// Clear the target value. Again, ignore error. The most likely cause is
 		// that someone else updated the target again, which doesn't bother us.
-		a.status.Set(target_url, nil, raw_value.Revision)
+		a.status.SetFrom(a.origin(), target_url, nil, raw_value.Revision)
 	}
 }
 
@@ -136,6 +132,9 @@ func (a veraAdapter) findDeviceUrl(id int) string {
 }
 
 func (a *veraAdapter) updateDeviceList(devices []veraapi.Device) {
+	// Find the URLs of devices which no longer exist.
+	oldUrls := []string{}
+
 OldNames:
 	for _, old := range a.devices {
 		for _, d := range devices {
@@ -146,22 +145,36 @@ OldNames:
 
 		old_dev_url := a.findDeviceUrl(old.Id)
 		if old_dev_url != "" {
-			err := a.status.Remove(old_dev_url, status.UNCHECKED_REVISION)
-			if err != nil {
-				panic(err)
-			}
+			oldUrls = append(oldUrls, old_dev_url)
 		}
 	}
 
-	// Add/update devices that exist.
-	for _, d := range devices {
-		a.updateDevice(d)
+	// Apply all changes as a single update, so watchers never see a partially
+	// updated device list.
+	err := a.status.UpdateFrom(a.origin(), func(tx *status.Tx) error {
+		for _, old_dev_url := range oldUrls {
+			if err := tx.Remove(old_dev_url, status.UNCHECKED_REVISION); err != nil {
+				return err
+			}
+		}
+
+		// Add/update devices that exist.
+		for _, d := range devices {
+			if err := a.updateDevice(tx, d); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		panic(err)
 	}
 
 	a.devices = devices
 }
 
-func (a veraAdapter) updateDevice(device veraapi.Device) {
+func (a veraAdapter) updateDevice(tx *status.Tx, device veraapi.Device) error {
 	if device.Category == "" {
 		device.Category = "Generic"
 	}
@@ -181,8 +194,5 @@ func (a veraAdapter) updateDevice(device veraapi.Device) {
 		device_values[name] = value
 	}
 
-	err := a.status.Set(device_url, device_values, status.UNCHECKED_REVISION)
-	if err != nil {
-		panic(err)
-	}
+	return tx.Set(device_url, device_values, status.UNCHECKED_REVISION)
 }
@@ -1,7 +1,7 @@
 package adapter
 
 import (
-	// "github.com/DonGar/go-house/status"
+	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/vera-api"
 	"gopkg.in/check.v1"
 )
@@ -75,6 +75,33 @@ func (suite *MySuite) TestVeraAdapterStartStopMock(c *check.C) {
 	checkAdaptorContents(c, &adaptor.base, `null`)
 }
 
+func (suite *MySuite) TestVeraAdapterKeepsRestoredDevices(c *check.C) {
+	s, mgr, b := setupTestAdapter(c,
+		"status://server/adapters/vera/TestVera", "status://TestVera")
+
+	// Devices restored from an earlier run.
+	e := s.Set("status://TestVera/foo/aaa", map[string]interface{}{"id": 1, "name": "aaa"}, status.UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	mock := newMockVeraApi()
+	adaptor, e := newVeraAdapterDetailed(mgr, b, mock)
+	c.Assert(e, check.IsNil)
+
+	// Once the handler reads updates, it's started.
+	mock.devices <- []veraapi.Device{}
+
+	checkAdaptorContents(c, &adaptor.base, `{
+      "foo": {
+          "aaa": {
+              "id": 1,
+              "name": "aaa"
+          }
+      }
+  }`)
+
+	adaptor.Stop()
+}
+
 func (suite *MySuite) TestVeraAdapterCreateDevices(c *check.C) {
 	deviceA := veraapi.Device{Id: 1, Name: "aaa", Category: "foo"}
 	deviceB := veraapi.Device{Id: 2, Name: "bbb", Category: "bar"}
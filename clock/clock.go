@@ -0,0 +1,96 @@
+// Package clock lets time based code run against either the real clock, or
+// a fake one which tests advance by hand.
+package clock
+
+import (
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) *Timer
+}
+
+// A Timer works like time.Timer. Stop and Reset discard any pending value on
+// C, so a stale firing is never received.
+type Timer struct {
+	C    <-chan time.Time
+	impl timerImpl
+}
+
+type timerImpl interface {
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+func (t *Timer) Stop() bool {
+	return t.impl.Stop()
+}
+
+func (t *Timer) Reset(d time.Duration) bool {
+	return t.impl.Reset(d)
+}
+
+// Implemented by clocks which count the events (timer firings, status
+// notifications, condition results) sent to handler goroutines, until each has
+// been handled. A simulation uses this to know when every handler is done
+// reacting, before moving time forward.
+type Tracker interface {
+	Sent()    // An event was sent to a handler goroutine.
+	Handled() // A handler goroutine is done reacting to an event.
+}
+
+// Report an event sent to a handler, if clk counts them.
+func Sent(clk Clock) {
+	if t, ok := clk.(Tracker); ok {
+		t.Sent()
+	}
+}
+
+// Report that a handler is done reacting to an event, if clk counts them.
+// Handlers call this after each event from a timer, status watch or condition.
+func Handled(clk Clock) {
+	if t, ok := clk.(Tracker); ok {
+		t.Handled()
+	}
+}
+
+type realClock struct{}
+
+// The real clock, using the time package.
+func NewReal() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) *Timer {
+	timer := time.NewTimer(d)
+	return &Timer{timer.C, realTimer{timer}}
+}
+
+// Before Go 1.23, a time.Timer which already fired keeps its value on C after
+// Stop or Reset, so drain it by hand.
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) Stop() bool {
+	if t.Timer.Stop() {
+		return true
+	}
+
+	select {
+	case <-t.Timer.C:
+	default:
+	}
+	return false
+}
+
+func (t realTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	t.Timer.Reset(d)
+	return active
+}
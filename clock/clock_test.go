@@ -0,0 +1,160 @@
+package clock
+
+import (
+	"gopkg.in/check.v1"
+	"testing"
+	"time"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MySuite struct{}
+
+var _ = check.Suite(&MySuite{})
+
+var start = time.Date(2014, time.June, 12, 0, 0, 0, 0, time.UTC)
+
+func checkFired(c *check.C, timer *Timer, expected time.Time) {
+	select {
+	case t := <-timer.C:
+		c.Check(t, check.Equals, expected)
+	default:
+		c.Error("Timer didn't fire.")
+	}
+}
+
+func checkNotFired(c *check.C, timer *Timer) {
+	select {
+	case t := <-timer.C:
+		c.Error("Timer fired unexpectedly: ", t)
+	default:
+	}
+}
+
+func (suite *MySuite) TestReal(c *check.C) {
+	clock := NewReal()
+
+	before := time.Now()
+	c.Check(clock.Now().Before(before), check.Equals, false)
+
+	timer := clock.NewTimer(time.Millisecond)
+	<-timer.C
+
+	c.Check(timer.Reset(time.Hour), check.Equals, false)
+	c.Check(timer.Stop(), check.Equals, true)
+
+	// A firing which wasn't received is discarded.
+	c.Check(timer.Reset(time.Millisecond), check.Equals, false)
+	time.Sleep(10 * time.Millisecond)
+	timer.Stop()
+	checkNotFired(c, timer)
+}
+
+func (suite *MySuite) TestFakeNow(c *check.C) {
+	clock := NewFake(start)
+	c.Check(clock.Now(), check.Equals, start)
+
+	clock.Advance(time.Hour)
+	c.Check(clock.Now(), check.Equals, start.Add(time.Hour))
+
+	clock.Set(start.Add(24 * time.Hour))
+	c.Check(clock.Now(), check.Equals, start.Add(24*time.Hour))
+
+	// Time doesn't go backwards.
+	clock.Set(start)
+	c.Check(clock.Now(), check.Equals, start.Add(24*time.Hour))
+}
+
+func (suite *MySuite) TestFakeTimer(c *check.C) {
+	clock := NewFake(start)
+
+	timer := clock.NewTimer(time.Hour)
+	c.Check(clock.PendingTimers(), check.Equals, 1)
+
+	clock.Advance(59 * time.Minute)
+	checkNotFired(c, timer)
+
+	clock.Advance(2 * time.Minute)
+	checkFired(c, timer, start.Add(time.Hour))
+	c.Check(clock.PendingTimers(), check.Equals, 0)
+
+	// Stop.
+	c.Check(timer.Reset(time.Hour), check.Equals, false)
+	c.Check(timer.Stop(), check.Equals, true)
+	c.Check(clock.PendingTimers(), check.Equals, 0)
+	clock.Advance(2 * time.Hour)
+	checkNotFired(c, timer)
+
+	// Reset discards a pending firing.
+	timer.Reset(time.Minute)
+	clock.Advance(time.Hour)
+	timer.Reset(time.Minute)
+	checkNotFired(c, timer)
+	c.Check(clock.PendingTimers(), check.Equals, 1)
+
+	// Zero fires right away.
+	timer = clock.NewTimer(0)
+	checkFired(c, timer, clock.Now())
+	c.Check(clock.PendingTimers(), check.Equals, 1)
+}
+
+func (suite *MySuite) TestFakeTimerOrder(c *check.C) {
+	clock := NewFake(start)
+	clock.Track()
+
+	late := clock.NewTimer(2 * time.Hour)
+	early := clock.NewTimer(time.Hour)
+
+	fired := []time.Time{}
+	done := make(chan bool)
+
+	// React to the early timer by resetting it, the way handlers do, and
+	// report each firing handled.
+	go func() {
+		for len(fired) < 4 {
+			select {
+			case t := <-early.C:
+				fired = append(fired, t)
+				early.Reset(time.Hour)
+			case t := <-late.C:
+				fired = append(fired, t)
+			}
+			clock.Handled()
+		}
+		done <- true
+	}()
+
+	clock.Advance(3 * time.Hour)
+	<-done
+
+	c.Check(fired, check.DeepEquals, []time.Time{
+		start.Add(time.Hour), start.Add(2 * time.Hour), start.Add(2 * time.Hour), start.Add(3 * time.Hour),
+	})
+}
+
+func (suite *MySuite) TestFakeTracking(c *check.C) {
+	clock := NewFake(start)
+
+	// Nothing is counted until tracking starts.
+	clock.Sent()
+	clock.Wait()
+
+	clock.Track()
+
+	// Wait returns once every event is handled.
+	handled := false
+	clock.Sent()
+	go func() {
+		time.Sleep(time.Millisecond)
+		handled = true
+		clock.Handled()
+	}()
+	clock.Wait()
+	c.Check(handled, check.Equals, true)
+
+	// A firing discarded by Stop won't be handled.
+	timer := clock.NewTimer(0)
+	timer.Stop()
+	clock.Wait()
+}
@@ -0,0 +1,224 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// A fake clock for tests and simulations. Time only moves when Advance or Set
+// is called.
+type Fake struct {
+	lock     sync.Mutex
+	now      time.Time
+	timers   []*fakeTimer // Active timers, in the order they were started.
+	tracking bool         // Are events sent to handlers counted?
+	pending  int          // Events sent to handlers, and not yet handled.
+	idle     *sync.Cond   // Signaled when pending drops to zero.
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	deadline time.Time
+	active   bool
+}
+
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.idle = sync.NewCond(&f.lock)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) *Timer {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.start(d)
+
+	return &Timer{t.c, t}
+}
+
+// Count the events sent to handler goroutines: timer firings, plus anything
+// reported with Sent. From then on, each handler using the clock must report
+// the events it has handled, and Set waits for each timer firing to be
+// handled, so that timers set in response are honored.
+func (f *Fake) Track() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.tracking = true
+}
+
+// An event was sent to a handler goroutine.
+func (f *Fake) Sent() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.sent()
+}
+
+// A handler goroutine is done reacting to an event.
+func (f *Fake) Handled() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.handled()
+}
+
+// Called with the lock held.
+func (f *Fake) sent() {
+	if f.tracking {
+		f.pending += 1
+	}
+}
+
+// Called with the lock held.
+func (f *Fake) handled() {
+	if f.tracking {
+		f.pending -= 1
+		if f.pending == 0 {
+			f.idle.Broadcast()
+		}
+	}
+}
+
+// Wait until every event sent to a handler has been handled. Returns right
+// away unless tracking.
+func (f *Fake) Wait() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for f.pending > 0 {
+		f.idle.Wait()
+	}
+}
+
+// Move time forward, firing timers in order as their deadlines are reached.
+// If tracking, each firing is handled before the next timer is chosen.
+func (f *Fake) Advance(d time.Duration) {
+	f.Set(f.Now().Add(d))
+}
+
+// Move time forward to now. See Advance.
+func (f *Fake) Set(now time.Time) {
+	for {
+		f.lock.Lock()
+
+		next := f.nextTimer(now)
+		if next == nil {
+			if now.After(f.now) {
+				f.now = now
+			}
+			f.lock.Unlock()
+			return
+		}
+
+		f.now = next.deadline
+		next.fire()
+
+		f.lock.Unlock()
+
+		f.Wait()
+	}
+}
+
+// The active timer with the earliest deadline at or before limit, if any.
+// Called with the lock held.
+func (f *Fake) nextTimer(limit time.Time) *fakeTimer {
+	var next *fakeTimer
+	for _, t := range f.timers {
+		if !t.deadline.After(limit) && (next == nil || t.deadline.Before(next.deadline)) {
+			next = t
+		}
+	}
+	return next
+}
+
+// Forget a timer that is no longer active. Called with the lock held.
+func (f *Fake) remove(t *fakeTimer) {
+	for i, other := range f.timers {
+		if other == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return
+		}
+	}
+}
+
+// Number of timers waiting to fire.
+func (f *Fake) PendingTimers() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return len(f.timers)
+}
+
+// Called with the clock lock held.
+func (t *fakeTimer) start(d time.Duration) {
+	t.deadline = t.clock.now.Add(d)
+	if !t.active {
+		t.active = true
+		t.clock.timers = append(t.clock.timers, t)
+	}
+
+	if d <= 0 {
+		t.fire()
+	}
+}
+
+// Called with the clock lock held.
+func (t *fakeTimer) fire() {
+	t.deactivate()
+	select {
+	case t.c <- t.clock.now:
+		t.clock.sent()
+	default:
+	}
+}
+
+// Called with the clock lock held.
+func (t *fakeTimer) deactivate() {
+	if t.active {
+		t.active = false
+		t.clock.remove(t)
+	}
+}
+
+// Called with the clock lock held.
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+		// The firing won't be handled now.
+		t.clock.handled()
+	default:
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.deactivate()
+	t.drain()
+
+	return wasActive
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.lock.Lock()
+	defer t.clock.lock.Unlock()
+
+	wasActive := t.active
+	t.drain()
+	t.start(d)
+
+	return wasActive
+}
@@ -2,23 +2,41 @@ package actions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/expr"
 	"github.com/DonGar/go-house/status"
 	"log"
 	"strings"
 	"sync"
 )
 
-// This is the signature of an action implementation.
-type Action func(s *status.Status, action *status.Status) (e error)
+// This is the signature of an action implementation. Changes it makes should
+// be attributed to origin, which describes what fired it.
+type Action func(s *status.Status, origin string, action *status.Status) (e error)
+
+// Action fields ending with this are expressions. "value_expr": "a.b + 1" is
+// evaluated when the action fires, and passed to it as "value".
+const EXPR_SUFFIX = "_expr"
 
 // Type for tracking the known actions in a thread safe manner.
 type Manager struct {
 	lock    sync.Mutex
 	actions map[string]Action
+	record  Action      // If set, called instead of any registered action.
+	clock   clock.Clock // Used by expressions.
 }
 
 func NewManager() *Manager {
-	return &Manager{sync.Mutex{}, map[string]Action{}}
+	return &Manager{sync.Mutex{}, map[string]Action{}, nil, clock.NewReal()}
+}
+
+// Create a manager that passes each action to record, instead of performing
+// it. Redirections and lists are still followed, and expressions evaluated
+// with clk, so record sees each single action that would have been performed.
+// "set" actions only change status, so they are performed after being
+// recorded. Used for simulations.
+func NewRecordingManager(clk clock.Clock, record Action) *Manager {
+	return &Manager{sync.Mutex{}, map[string]Action{}, record, clk}
 }
 
 func (a *Manager) RegisterAction(name string, action Action) error {
@@ -60,21 +78,22 @@ func (a *Manager) lookupAction(name string) (action Action, err error) {
 	}
 }
 
-// This method should always be used to fire any action.
-func (am *Manager) FireAction(s *status.Status, action *status.Status) {
-
-	var err error
+// This method should always be used to fire any action. origin describes
+// what fired it, such as "rule:<name>", and is recorded with any changes made.
+// Errors are logged, and the first one is returned. Later actions in a list
+// are still fired after an error.
+func (am *Manager) FireAction(s *status.Status, origin string, action *status.Status) (e error) {
+	if e = am.fireAction(s, origin, action); e != nil {
+		log.Println("Fire error: ", e)
+	}
+	return e
+}
 
-	// If we exit with an error, we log it.
-	defer func() {
-		if err != nil {
-			log.Println("Fire error: ", err)
-		}
-	}()
+func (am *Manager) fireAction(s *status.Status, origin string, action *status.Status) (err error) {
 
 	actionValue, _, err := action.Get("status://")
 	if err != nil {
-		return
+		return err
 	}
 
 	switch typedAction := actionValue.(type) {
@@ -92,44 +111,105 @@ func (am *Manager) FireAction(s *status.Status, action *status.Status) {
 				fetchStatus.Set("status://url", typedAction, 1)
 
 				// Recurse. This let's us lookup and fire the fetch action normally.
-				am.FireAction(s, fetchStatus)
+				return am.fireAction(s, origin, fetchStatus)
 			}
 
 			// Some other error, probably that the status URL doesn't exist.
-			return
+			return err
 		}
 
 		// We found it, fire it off!
-		am.FireAction(s, redirectAction)
+		return am.fireAction(s, origin, redirectAction)
 
 	case []interface{}:
-		// An array of actions means fire each one in order.
-		// We do NOT return error results.
+		// An array of actions means fire each one in order, even if some fail.
 		for _, subActionValue := range typedAction {
 			subActionStatus := &status.Status{}
 			subActionStatus.Set("status://", subActionValue, 0)
 
-			am.FireAction(s, subActionStatus)
+			if e := am.fireAction(s, origin, subActionStatus); e != nil {
+				if err == nil {
+					err = e
+				} else {
+					// Only the first error is returned, so log the rest.
+					log.Println("Fire error: ", e)
+				}
+			}
 		}
+		return err
 
 	case map[string]interface{}:
 		// We received a dictionary, this is (hopefully) a registered action.
 		actionName, _, err := action.GetString("status://action")
 		if err != nil {
-			err = fmt.Errorf("Action: No action specified: %s", actionName)
-			return
+			return fmt.Errorf("Action: No action specified: %s", actionName)
+		}
+
+		if action, err = am.evaluateExpressions(s, action, typedAction); err != nil {
+			return err
+		}
+
+		if am.record != nil {
+			if err = am.record(s, origin, action); err != nil || actionName != "set" {
+				return err
+			}
+			return actionSet(s, origin, action)
 		}
 
 		actionMethod, err := am.lookupAction(actionName)
 		if err != nil {
-			return
+			return err
 		}
 
 		// Fire the looked up action.
 		log.Println("Firing action: ", actionName)
-		err = actionMethod(s, action)
+		return actionMethod(s, origin, action)
 
 	default:
-		err = fmt.Errorf("Action: Can't perform %#v", actionValue)
+		return fmt.Errorf("Action: Can't perform %#v", actionValue)
+	}
+}
+
+// Replace each expression field of an action with it's value. Actions
+// without expressions are returned unchanged.
+func (am *Manager) evaluateExpressions(s *status.Status, action *status.Status, fields map[string]interface{}) (*status.Status, error) {
+	hasExpr := false
+	for name := range fields {
+		hasExpr = hasExpr || strings.HasSuffix(name, EXPR_SUFFIX)
+	}
+	if !hasExpr {
+		return action, nil
+	}
+
+	result := map[string]interface{}{}
+
+	for name, field := range fields {
+		if !strings.HasSuffix(name, EXPR_SUFFIX) {
+			result[name] = field
+			continue
+		}
+
+		source, ok := field.(string)
+		if !ok {
+			return nil, fmt.Errorf("Action: %s is not an expression: %#v", name, field)
+		}
+
+		x, e := expr.Parse(source)
+		if e != nil {
+			return nil, fmt.Errorf("Action: %s: %s", name, e.Error())
+		}
+
+		value, e := x.Eval(expr.Env{Status: s, Clock: am.clock})
+		if e != nil {
+			return nil, fmt.Errorf("Action: %s: %s", name, e.Error())
+		}
+
+		result[strings.TrimSuffix(name, EXPR_SUFFIX)] = value
+	}
+
+	evaluated := &status.Status{}
+	if e := evaluated.Set("status://", result, 0); e != nil {
+		return nil, e
 	}
+	return evaluated, nil
 }
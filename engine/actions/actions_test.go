@@ -2,6 +2,7 @@ package actions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
 	"testing"
@@ -23,17 +24,17 @@ type mockActionResults struct {
 
 const MOCK_FAILURE_MSG = "Mock Action Failed."
 
-func (m *mockActionResults) success(s *status.Status, action *status.Status) error {
+func (m *mockActionResults) success(s *status.Status, origin string, action *status.Status) error {
 	m.successCalls += 1
 	return nil
 }
 
-func (m *mockActionResults) fail(s *status.Status, action *status.Status) error {
+func (m *mockActionResults) fail(s *status.Status, origin string, action *status.Status) error {
 	m.failCalls += 1
 	return fmt.Errorf(MOCK_FAILURE_MSG)
 }
 
-func (m *mockActionResults) fetch(s *status.Status, action *status.Status) error {
+func (m *mockActionResults) fetch(s *status.Status, origin string, action *status.Status) error {
 	url, _, e := action.GetString("status://url")
 	if e != nil {
 		return e
@@ -73,7 +74,7 @@ func setupTestActionEnv(c *check.C) (r *mockActionResults, s *status.Status, a *
 
 func (suite *MySuite) TestManagerRegisterUnRegister(c *check.C) {
 
-	var testAction Action = func(s *status.Status, action *status.Status) error { return nil }
+	var testAction Action = func(s *status.Status, origin string, action *status.Status) error { return nil }
 
 	mgr := NewManager()
 
@@ -120,7 +121,7 @@ func (suite *MySuite) TestManagerRegisterUnRegister(c *check.C) {
 func (suite *MySuite) TestFireActionNil(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 0)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -131,7 +132,7 @@ func (suite *MySuite) TestFireActionStringRedirect(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", "status://action/actionSuccess", 0)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 1)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -142,7 +143,7 @@ func (suite *MySuite) TestFireActionStringRedirectHttp(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", "http://foo/", 0)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 0)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -154,7 +155,7 @@ func (suite *MySuite) TestFireActionStringRedirectBadLocation(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", "status://bogus/redirect", 0)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 0)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -167,7 +168,7 @@ func (suite *MySuite) TestFireActionArray(c *check.C) {
 		"status://action/actionSuccess",
 		"status://action/actionSuccess"}, 0)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 2)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -181,7 +182,8 @@ func (suite *MySuite) TestFireActionArrayFailure(c *check.C) {
 		"status://action/actionFail",
 		"status://action/actionSuccess"}, 0)
 
-	r.mgr().FireAction(s, a)
+	e := r.mgr().FireAction(s, "test", a)
+	c.Check(e, check.ErrorMatches, MOCK_FAILURE_MSG)
 
 	c.Check(r.successCalls, check.Equals, 2)
 	c.Check(r.failCalls, check.Equals, 1)
@@ -192,7 +194,8 @@ func (suite *MySuite) TestFireActionDict(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", map[string]interface{}{"action": "success"}, 0)
 
-	r.mgr().FireAction(s, a)
+	e := r.mgr().FireAction(s, "test", a)
+	c.Check(e, check.IsNil)
 
 	c.Check(r.successCalls, check.Equals, 1)
 	c.Check(r.failCalls, check.Equals, 0)
@@ -203,7 +206,7 @@ func (suite *MySuite) TestFireActionDictFail(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", map[string]interface{}{"action": "fail"}, 0)
 
-	r.mgr().FireAction(s, a)
+	r.mgr().FireAction(s, "test", a)
 
 	c.Check(r.successCalls, check.Equals, 0)
 	c.Check(r.failCalls, check.Equals, 1)
@@ -214,9 +217,83 @@ func (suite *MySuite) TestFireManagerUnknown(c *check.C) {
 	r, s, a := setupTestActionEnv(c)
 	a.Set("status://", map[string]interface{}{"action": "unknown"}, 0)
 
-	r.mgr().FireAction(s, a)
+	e := r.mgr().FireAction(s, "test", a)
+	c.Check(e, check.ErrorMatches, "Action: Not Registered: unknown")
 
 	c.Check(r.successCalls, check.Equals, 0)
 	c.Check(r.failCalls, check.Equals, 0)
 	c.Check(r.httpCalls, check.Equals, 0)
 }
+
+func (suite *MySuite) TestFireRecordingManager(c *check.C) {
+	_, s, a := setupTestActionEnv(c)
+	a.Set("status://", []interface{}{
+		"status://action/actionSuccess",
+		"status://action/actionUnknown",
+		"http://host/url"}, 0)
+
+	recorded := []string{}
+	origins := []string{}
+	mgr := NewRecordingManager(clock.NewReal(), func(s *status.Status, origin string, action *status.Status) error {
+		name, _, e := action.GetString("status://action")
+		recorded = append(recorded, name)
+		origins = append(origins, origin)
+		return e
+	})
+
+	mgr.FireAction(s, "test", a)
+
+	// Nothing is registered, but every action is recorded.
+	c.Check(recorded, check.DeepEquals, []string{"success", "unknown", "fetch"})
+	c.Check(origins, check.DeepEquals, []string{"test", "test", "test"})
+}
+
+func (suite *MySuite) TestFireExpressions(c *check.C) {
+	_, s, _ := setupTestActionEnv(c)
+	c.Assert(s.Set("status://house/temperature", 20.0, status.UNCHECKED_REVISION), check.IsNil)
+
+	recorded := []interface{}{}
+	mgr := NewRecordingManager(clock.NewReal(), func(s *status.Status, origin string, action *status.Status) error {
+		value, _, e := action.Get("status://")
+		recorded = append(recorded, value)
+		return e
+	})
+
+	a := &status.Status{}
+	e := a.SetJson("status://", []byte(`{
+		"action": "set",
+		"component": "status://house",
+		"dest": "fahrenheit",
+		"value_expr": "house.temperature * 1.8 + 32"
+	}`), 0)
+	c.Assert(e, check.IsNil)
+
+	c.Check(mgr.FireAction(s, "test", a), check.IsNil)
+	c.Check(recorded, check.DeepEquals, []interface{}{
+		map[string]interface{}{
+			"action":    "set",
+			"component": "status://house",
+			"dest":      "fahrenheit",
+			"value":     68.0,
+		},
+	})
+
+	// Set actions are performed, as well as recorded.
+	fahrenheit, _, e := s.Get("status://house/fahrenheit")
+	c.Check(e, check.IsNil)
+	c.Check(fahrenheit, check.Equals, 68.0)
+
+	// Invalid expressions fail the action.
+	bad := []string{
+		`{"action": "set", "value_expr": "house.temperature +"}`,
+		`{"action": "set", "value_expr": "house.temperature / 0"}`,
+		`{"action": "set", "value_expr": 5}`,
+	}
+
+	for _, b := range bad {
+		c.Assert(a.SetJson("status://", []byte(b), status.UNCHECKED_REVISION), check.IsNil)
+		c.Check(mgr.FireAction(s, "test", a), check.NotNil)
+	}
+
+	c.Check(recorded, check.HasLen, 1)
+}
@@ -34,7 +34,7 @@ func errorHandler(e error) {
 // weird with wildcards.
 
 // Implement the "set" action.
-func actionSet(s *status.Status, action *status.Status) (e error) {
+func actionSet(s *status.Status, origin string, action *status.Status) (e error) {
 	componentUrl, _, e := action.GetString("status://component")
 	if e != nil {
 		return e
@@ -65,7 +65,7 @@ func actionSet(s *status.Status, action *status.Status) (e error) {
 
 	for cUrl := range componentMatches {
 		destUrl := cUrl + "/" + dest
-		if e = s.Set(destUrl, value, status.UNCHECKED_REVISION); e != nil {
+		if e = s.SetFrom(origin, destUrl, value, status.UNCHECKED_REVISION); e != nil {
 			final = e
 		}
 	}
@@ -75,7 +75,7 @@ func actionSet(s *status.Status, action *status.Status) (e error) {
 
 // Send a Wake On Lan request to a component. The component must have a "mac"
 // value defined with is the components network mac address.
-func actionWol(s *status.Status, action *status.Status) (e error) {
+func actionWol(s *status.Status, origin string, action *status.Status) (e error) {
 	componentUrl, _, e := action.GetString("status://component")
 	if e != nil {
 		return e
@@ -107,7 +107,7 @@ func actionWol(s *status.Status, action *status.Status) (e error) {
 // Ping a component, and set the "up" value on component to true or false. The
 // name of the component is the name to ping. The "up" value is updated in the
 // background after an arbitrary delay, not right away.
-func actionPing(s *status.Status, action *status.Status) (e error) {
+func actionPing(s *status.Status, origin string, action *status.Status) (e error) {
 	componentUrl, _, e := action.GetString("status://component")
 	if e != nil {
 		return e
@@ -126,13 +126,13 @@ func actionPing(s *status.Status, action *status.Status) (e error) {
 		url_parts := strings.Split(cUrl, "/")
 		hostname := url_parts[len(url_parts)-1]
 
-		go func() { errorHandler(performPing(s, hostname, resultUrl)) }()
+		go func() { errorHandler(performPing(s, origin, hostname, resultUrl)) }()
 	}
 
 	return nil
 }
 
-func performPing(s *status.Status, hostname, resultUrl string) error {
+func performPing(s *status.Status, origin, hostname, resultUrl string) error {
 
 	// Shell out to perform the ping. This avoids needing root permissions.
 	cmd := exec.Command("/bin/ping", "-q", "-c", "3", hostname)
@@ -141,7 +141,7 @@ func performPing(s *status.Status, hostname, resultUrl string) error {
 	// If there was no error, the host is up.
 	result := e == nil
 
-	if e = s.Set(resultUrl, result, status.UNCHECKED_REVISION); e != nil {
+	if e = s.SetFrom(origin, resultUrl, result, status.UNCHECKED_REVISION); e != nil {
 		return e
 	}
 
@@ -152,7 +152,7 @@ func performPing(s *status.Status, hostname, resultUrl string) error {
 // otherwise,
 // Happens ansynronously.
 // Does not require a component to fire.
-func actionFetch(s *status.Status, action *status.Status) (e error) {
+func actionFetch(s *status.Status, origin string, action *status.Status) (e error) {
 	// "url"
 	// "download_name"
 
@@ -201,7 +201,7 @@ type attachemetDesc struct {
 	filename string
 }
 
-func actionEmail(s *status.Status, action *status.Status) (e error) {
+func actionEmail(s *status.Status, origin string, action *status.Status) (e error) {
 	//   * to - Address to send email too.
 	//   * subject - Optional subject string.
 	//   * body - Optional body string.
@@ -42,7 +42,7 @@ func validateTestSet(c *check.C, actionJson, resultJson string) {
 	c.Assert(err, check.IsNil)
 
 	// Perform action.
-	err = actionSet(s, a)
+	err = actionSet(s, "test", a)
 	c.Assert(err, check.IsNil)
 
 	// Validate Result.
@@ -50,6 +50,22 @@ func validateTestSet(c *check.C, actionJson, resultJson string) {
 	c.Check(status.NormalizeJson(string(v)), check.DeepEquals, status.NormalizeJson(resultJson))
 }
 
+func (suite *MySuite) TestSetOrigin(c *check.C) {
+	s, a := setupTestBuiltinActionEnv(c)
+	c.Assert(a.SetJson("status://", []byte(`{
+    "action": "set",
+    "component": "status://adapter/host/hostA",
+    "dest": "component_dest",
+    "value": 1
+  }`), 0), check.IsNil)
+
+	c.Assert(actionSet(s, "rule:test", a), check.IsNil)
+
+	matches, e := s.GetMatchingUrls("status://adapter/host/hostA/component_dest")
+	c.Assert(e, check.IsNil)
+	c.Check(matches["status://adapter/host/hostA/component_dest"].Origin, check.Equals, "rule:test")
+}
+
 func (suite *MySuite) TestSet(c *check.C) {
 	// String Value
 	action := `{
@@ -173,6 +189,37 @@ func (suite *MySuite) TestSet(c *check.C) {
 
 	validateTestSet(c, action, expected)
 
+	// Recursive Wildcard Component, with a predicate.
+	action = `{
+    "action": "set",
+    "component": "status://**/*[mac=00:11:22:33:44:55]",
+    "dest": "component_dest",
+    "value": "new_value"
+  }`
+
+	expected = `{
+    "adapter": {
+      "host": {
+        "hostA": {
+          "component_dest": "new_value",
+          "mac": "00:11:22:33:44:55"
+        },
+        "hostB": {
+        }
+      }
+    },
+    "server": {
+      "downloads": "/tmp/downloads",
+      "email_address": "from@from.org",
+      "relay_id_server": "bogus_server",
+      "relay_password": "bogus_password",
+      "relay_server": "bogus_server:587",
+      "relay_user": "bogus_user"
+    }
+  }`
+
+	validateTestSet(c, action, expected)
+
 	// Unknown Component
 	action = `{
     "action": "set",
@@ -193,7 +240,7 @@ func (suite *MySuite) TestWol(c *check.C) {
 		"component": "status://adapter/host/*",
 	}, 0)
 
-	e := actionWol(s, a)
+	e := actionWol(s, "test", a)
 
 	c.Check(e, check.IsNil)
 }
@@ -205,7 +252,7 @@ func (suite *MySuite) TestPing(c *check.C) {
 		"component": "status://adapter/host/*",
 	}, 0)
 
-	e := actionPing(s, a)
+	e := actionPing(s, "test", a)
 
 	c.Check(e, check.IsNil)
 }
@@ -217,7 +264,7 @@ func (suite *MySuite) TestFetch(c *check.C) {
 		"url":    "http://www.google.com/",
 	}, 0)
 
-	e := actionFetch(s, a)
+	e := actionFetch(s, "test", a)
 
 	c.Check(e, check.IsNil)
 }
@@ -234,7 +281,7 @@ func (suite *MySuite) TestFetchDownload(c *check.C) {
 		"download_name": "foo.{time}.bar",
 	}, 0)
 
-	e := actionFetch(s, a)
+	e := actionFetch(s, "test", a)
 
 	c.Check(e, check.IsNil)
 }
@@ -270,7 +317,7 @@ func (suite *MySuite) NoTestEmail(c *check.C) {
 	}, 0)
 	c.Assert(e, check.IsNil)
 
-	e = actionEmail(s, a)
+	e = actionEmail(s, "test", a)
 	c.Check(e, check.NotNil)
 }
 
@@ -295,6 +342,6 @@ func (suite *MySuite) NoTestEmailAttached(c *check.C) {
 	}, 0)
 	c.Assert(e, check.IsNil)
 
-	e = actionEmail(s, a)
+	e = actionEmail(s, "test", a)
 	c.Check(e, check.NotNil)
 }
@@ -2,6 +2,7 @@ package conditions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"time"
 )
@@ -14,7 +15,7 @@ type afterCondition struct {
 	repeat    time.Duration
 }
 
-func newAfterCondition(s *status.Status, body *status.Status) (*afterCondition, error) {
+func newAfterCondition(s *status.Status, clk clock.Clock, body *status.Status) (*afterCondition, error) {
 
 	// look up the conditionValues.
 	subConditionBody, _, e := body.Get("status://condition")
@@ -25,7 +26,7 @@ func newAfterCondition(s *status.Status, body *status.Status) (*afterCondition,
 	conditionBodyStatus := &status.Status{}
 	conditionBodyStatus.Set("status://", subConditionBody, status.UNCHECKED_REVISION)
 
-	condition, e := NewCondition(s, conditionBodyStatus)
+	condition, e := NewCondition(s, clk, conditionBodyStatus)
 	if e != nil {
 		return nil, fmt.Errorf("After condition: (%#v): %s", subConditionBody, e.Error())
 	}
@@ -50,7 +51,8 @@ func newAfterCondition(s *status.Status, body *status.Status) (*afterCondition,
 	}
 
 	// Create our condition.
-	c := &afterCondition{newBase(s), condition, delay, repeat}
+	c := &afterCondition{newBase(s, clk), condition, delay, repeat}
+	c.setChildren(condition)
 
 	c.start()
 	return c, nil
@@ -58,7 +60,7 @@ func newAfterCondition(s *status.Status, body *status.Status) (*afterCondition,
 
 func (c *afterCondition) start() {
 	// Start it's goroutine.
-	go c.Handler()
+	c.startHandler(c.Handler)
 }
 
 func (c *afterCondition) Stop() {
@@ -71,18 +73,20 @@ func (c *afterCondition) Stop() {
 func (c *afterCondition) Handler() {
 	// Create the timer with a long timeout, then stop it.
 	// We'll reset, when we're ready to really start it.
-	timer := time.NewTimer(time.Hour)
+	timer := c.clock.NewTimer(time.Hour)
 	timer.Stop()
 
 	c.sendResult(false)
 
+	c.handled()
+
 	for {
 		select {
 		case condValue := <-c.condition.Result():
 			if condValue {
-				timer.Reset(c.delay)
+				c.resetTimer(timer, c.delay)
 			} else {
-				timer.Stop()
+				c.stopTimer(timer)
 				c.sendResult(false)
 			}
 
@@ -93,12 +97,16 @@ func (c *afterCondition) Handler() {
 
 			// If we repeat after being true,
 			if c.repeat != 0 {
-				timer.Reset(c.repeat)
+				c.resetTimer(timer, c.repeat)
+			} else {
+				c.setNextChange(time.Time{})
 			}
 
 		case <-c.StopChan:
 			c.StopChan <- true
 			return
 		}
+
+		c.handled()
 	}
 }
@@ -1,8 +1,10 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
+	"time"
 )
 
 func (suite *MySuite) TestAfterStartStop(c *check.C) {
@@ -82,7 +84,7 @@ func (suite *MySuite) TestAfterMock(c *check.C) {
 	mockCond := &mockCondition{make(chan bool)}
 
 	cond := &afterCondition{
-		newBase(s), mockCond, 2 * EMPTY_DELAY, 0}
+		newBase(s, clock.NewReal()), mockCond, 2 * EMPTY_DELAY, 0}
 	cond.start()
 
 	validateChannelRead(c, cond, false)
@@ -123,7 +125,7 @@ func (suite *MySuite) TestAfterMockRepeat(c *check.C) {
 	mockCond := &mockCondition{make(chan bool)}
 
 	cond := &afterCondition{
-		newBase(s), mockCond, 2 * EMPTY_DELAY, 2 * EMPTY_DELAY}
+		newBase(s, clock.NewReal()), mockCond, 2 * EMPTY_DELAY, 2 * EMPTY_DELAY}
 	cond.start()
 
 	validateChannelRead(c, cond, false)
@@ -163,3 +165,32 @@ func (suite *MySuite) TestAfterMockRepeat(c *check.C) {
 
 	cond.Stop()
 }
+
+func (suite *MySuite) TestAfterFakeClock(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 0, 0, 0, 0, time.UTC))
+
+	cond := &afterCondition{newBase(s, clk), mockCond, time.Hour, 24 * time.Hour}
+	cond.start()
+
+	validateChannelRead(c, cond, false)
+
+	mockCond.result <- true
+	waitForTimers(c, clk, 1)
+
+	clk.Advance(59 * time.Minute)
+	validateChannelEmptyInstant(c, cond)
+
+	clk.Advance(time.Minute)
+	validateChannelRead(c, cond, true)
+
+	// Repeat daily, for a week.
+	for i := 0; i < 7; i++ {
+		clk.Advance(24 * time.Hour)
+		validateChannelRead(c, cond, false)
+		validateChannelRead(c, cond, true)
+	}
+
+	cond.Stop()
+}
@@ -2,6 +2,7 @@ package conditions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"reflect"
 )
@@ -16,26 +17,29 @@ type andCondition struct {
 	conditions []conditionValue
 }
 
-func newAndCondition(s *status.Status, body *status.Status) (*andCondition, error) {
+func newAndCondition(s *status.Status, clk clock.Clock, body *status.Status) (*andCondition, error) {
 
 	// look up the conditionValues.
 	valuesRaw, _, e := body.Get("status://conditions")
 	if e != nil {
 		return nil, e
 	}
-	conditionValues, e := parseConditionValues(s, valuesRaw)
+	conditionValues, e := parseConditionValues(s, clk, "And", valuesRaw)
 	if e != nil {
 		return nil, e
 	}
 
 	// Create our condition.
-	c := &andCondition{newBase(s), conditionValues}
+	c := &andCondition{newBase(s, clk), conditionValues}
+	c.setChildren(childConditions(conditionValues)...)
 
 	c.start()
 	return c, nil
 }
 
-func parseConditionValues(s *status.Status, valuesRaw interface{}) ([]conditionValue, error) {
+// Create the subconditions in a "conditions" list. kind names the condition
+// they belong to, for errors.
+func parseConditionValues(s *status.Status, clk clock.Clock, kind string, valuesRaw interface{}) ([]conditionValue, error) {
 
 	valuesArray, ok := valuesRaw.([]interface{})
 	if !ok {
@@ -48,9 +52,13 @@ func parseConditionValues(s *status.Status, valuesRaw interface{}) ([]conditionV
 		conditionBodyStatus := &status.Status{}
 		conditionBodyStatus.Set("status://", subConditionBody, status.UNCHECKED_REVISION)
 
-		condition, e := NewCondition(s, conditionBodyStatus)
+		condition, e := NewCondition(s, clk, conditionBodyStatus)
 		if e != nil {
-			return nil, fmt.Errorf("And condition: %d (%#v): %s", i, subConditionBody, e.Error())
+			// Stop the conditions we already started.
+			for _, started := range conditionValues[:i] {
+				started.condition.Stop()
+			}
+			return nil, fmt.Errorf("%s condition: %d (%#v): %s", kind, i, subConditionBody, e.Error())
 		}
 
 		// We successfully parsed a value element. Remember it.
@@ -60,19 +68,32 @@ func parseConditionValues(s *status.Status, valuesRaw interface{}) ([]conditionV
 	return conditionValues, nil
 }
 
+// The conditions in a list of conditionValues.
+func childConditions(values []conditionValue) []Condition {
+	result := []Condition{}
+	for _, v := range values {
+		result = append(result, v.condition)
+	}
+	return result
+}
+
 func (c *andCondition) start() {
 	// Start it's goroutine.
-	go c.Handler()
+	c.startHandler(c.Handler)
 }
 
 func (c *andCondition) Stop() {
-	// Shut down inner conditions before stopping ourselves. This means we
-	// can react to final result updates from them, and avoid race conditions.
-	for _, condValue := range c.conditions {
+	c.stopConditions(c.conditions)
+}
+
+// Shut down inner conditions before stopping ourselves. This means we can
+// react to final result updates from them, and avoid race conditions.
+func (b *base) stopConditions(conditions []conditionValue) {
+	for _, condValue := range conditions {
 		condValue.condition.Stop()
 	}
 
-	c.base.Stop()
+	b.Stop()
 }
 
 func (c *andCondition) currentValue() bool {
@@ -86,35 +107,45 @@ func (c *andCondition) currentValue() bool {
 }
 
 func (c *andCondition) Handler() {
+	c.handleConditions(c.conditions, c.currentValue)
+}
+
+// The handler for conditions that combine the results of subconditions.
+// Subcondition results are recorded in conditions, and currentValue is used to
+// find our result after each change.
+func (b *base) handleConditions(conditions []conditionValue, currentValue func() bool) {
 	// Read initial state from all conditions, and send out our initial state.
-	for i := range c.conditions {
-		c.conditions[i].result = <-c.conditions[i].condition.Result()
+	for i := range conditions {
+		conditions[i].result = <-conditions[i].condition.Result()
+		b.handled()
 	}
-	c.sendResult(c.currentValue())
+	b.sendResult(currentValue())
 
 	// Build reflect list of all condition channels to listen for.
-	channels := make([]reflect.SelectCase, len(c.conditions)+1)
-	for i := range c.conditions {
+	channels := make([]reflect.SelectCase, len(conditions)+1)
+	for i := range conditions {
 		channels[i] = reflect.SelectCase{
 			Dir:  reflect.SelectRecv,
-			Chan: reflect.ValueOf(c.conditions[i].condition.Result()),
+			Chan: reflect.ValueOf(conditions[i].condition.Result()),
 		}
 	}
 
 	// We also listen to the stop channel.
 	channels[len(channels)-1] = reflect.SelectCase{
-		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.StopChan)}
+		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(b.StopChan)}
 
+	b.handled()
 	for {
 		receivedOnChannel, value, _ := reflect.Select(channels)
 
-		if receivedOnChannel < len(c.conditions) {
+		if receivedOnChannel < len(conditions) {
 			// One of our conditions updated it's result. Update ours accordingly.
-			c.conditions[receivedOnChannel].result = value.Bool()
-			c.sendResult(c.currentValue())
+			conditions[receivedOnChannel].result = value.Bool()
+			b.sendResult(currentValue())
+			b.handled()
 		} else {
 			// If it's after the conditions, it's the stop channel.
-			c.StopChan <- true
+			b.StopChan <- true
 			return
 		}
 	}
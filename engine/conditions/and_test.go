@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
 )
@@ -14,7 +15,7 @@ func validateAndParseStartStop(c *check.C, conditionsJson string, expectedValue
 	e := body.Set("status://test", "and", 0)
 	e = body.SetJson("status://conditions", []byte(conditionsJson), 1)
 
-	cond, e := NewCondition(s, body)
+	cond, e := NewCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	validateChannelRead(c, cond, expectedValue)
@@ -52,7 +53,7 @@ func (suite *MySuite) TestAndOneMock(c *check.C) {
 
 	conditionValues := []conditionValue{{mockCond, false}}
 
-	cond := &andCondition{newBase(s), conditionValues}
+	cond := &andCondition{newBase(s, clock.NewReal()), conditionValues}
 	cond.start()
 
 	validateChannelEmpty(c, cond)
@@ -88,7 +89,7 @@ func (suite *MySuite) TestAndThreeMock(c *check.C) {
 		conditionValues[i] = conditionValue{mockCond[i], false}
 	}
 
-	cond := &andCondition{newBase(s), conditionValues}
+	cond := &andCondition{newBase(s, clock.NewReal()), conditionValues}
 	cond.start()
 
 	validateChannelEmpty(c, cond)
@@ -119,3 +120,18 @@ func (suite *MySuite) TestAndThreeMock(c *check.C) {
 
 	cond.Stop()
 }
+
+func (suite *MySuite) TestAndParsingBad(c *check.C) {
+	body := &status.Status{}
+	e := body.SetJson("status://", []byte(`{"test": "and", "conditions": [{"test": "bogus"}]}`), 0)
+	c.Assert(e, check.IsNil)
+
+	_, e = NewCondition(&status.Status{}, clock.NewReal(), body)
+	c.Check(e, check.ErrorMatches, "(?s)And condition: 0 .*")
+
+	e = body.Set("status://test", "or", status.UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	_, e = NewCondition(&status.Status{}, clock.NewReal(), body)
+	c.Check(e, check.ErrorMatches, "(?s)Or condition: 0 .*")
+}
@@ -0,0 +1,184 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"time"
+)
+
+// A time which happens once on each day, like "22:00" or "sunset-30m".
+type dailyTime interface {
+	// When it happens on the date containing day, in day's location. ok is false
+	// if it doesn't happen that day.
+	on(day time.Time) (t time.Time, ok bool)
+}
+
+type clockTime struct {
+	hour, minute, second int
+}
+
+func (t clockTime) on(day time.Time) (time.Time, bool) {
+	year, month, date := day.Date()
+	result := time.Date(year, month, date, t.hour, t.minute, t.second, 0, day.Location())
+
+	if result.Hour() != t.hour || result.Minute() != t.minute {
+		// Skipped by daylight savings. Use the offset from before the change,
+		// which moves it forward (2:30AM becomes 3:30AM).
+		_, offset := result.Add(-3 * time.Hour).Zone()
+		result = time.Date(year, month, date, t.hour, t.minute, t.second, 0,
+			time.FixedZone("", offset)).In(day.Location())
+	}
+
+	return result, true
+}
+
+type solarTime struct {
+	event     solarEvent
+	offset    time.Duration
+	latitude  float64
+	longitude float64
+}
+
+func (t solarTime) on(day time.Time) (time.Time, bool) {
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, 0, 0, 0, 0, day.Location())
+
+	next := findNextSolarEvent(start.Add(-time.Nanosecond), t.event, t.latitude, t.longitude)
+	if next.IsZero() || !next.Before(start.AddDate(0, 0, 1)) {
+		return time.Time{}, false
+	}
+
+	return next.Add(t.offset).In(day.Location()), true
+}
+
+// Formats accepted for clock times.
+var clockLayouts = []string{"15:04:05", "15:04", "3:04:05PM", "3:04PM", "3PM"}
+
+// Parse "22:00", "10:30PM", "sunset", "sunrise+15m", etc.
+func parseDailyTime(s *status.Status, value string) (dailyTime, error) {
+	event, offset, ok, e := parseSolarExpression(value)
+	if e != nil {
+		return nil, e
+	}
+
+	if ok {
+		latitude := s.GetFloatWithDefault("status://server/latitude", 0.0)
+		longitude := s.GetFloatWithDefault("status://server/longitude", 0.0)
+		return solarTime{event, offset, latitude, longitude}, nil
+	}
+
+	for _, layout := range clockLayouts {
+		if t, e := time.Parse(layout, value); e == nil {
+			return clockTime{t.Hour(), t.Minute(), t.Second()}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown time: %s", value)
+}
+
+type betweenCondition struct {
+	base
+
+	start    dailyTime
+	end      dailyTime
+	location *time.Location
+}
+
+func newBetweenCondition(s *status.Status, clk clock.Clock, body *status.Status) (*betweenCondition, error) {
+	var times [2]dailyTime
+
+	for i, name := range []string{"start", "end"} {
+		value, _, e := body.GetString("status://" + name)
+		if e != nil {
+			return nil, fmt.Errorf("Between condition: No '%s'.", name)
+		}
+
+		if times[i], e = parseDailyTime(s, value); e != nil {
+			return nil, fmt.Errorf("Between condition: %s", e.Error())
+		}
+	}
+
+	location, e := options.Location(s)
+	if e != nil {
+		return nil, fmt.Errorf("Between condition: %s", e.Error())
+	}
+
+	c := &betweenCondition{newBase(s, clk), times[0], times[1], location}
+
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+
+	return c, nil
+}
+
+// The window starting on the date containing day. If end isn't after start,
+// the window ends on the next day.
+func (c *betweenCondition) window(day time.Time) (start, end time.Time, ok bool) {
+	if start, ok = c.start.on(day); !ok {
+		return start, end, false
+	}
+
+	if end, ok = c.end.on(day); ok && end.After(start) {
+		return start, end, true
+	}
+
+	end, ok = c.end.on(day.AddDate(0, 0, 1))
+	return start, end, ok && end.After(start)
+}
+
+func (c *betweenCondition) findActiveAndNextChange(now time.Time) (active bool, changeTime time.Time) {
+	now = now.In(c.location)
+
+	// Check the windows starting yesterday (which may still be open), today and
+	// tomorrow.
+	for _, days := range []int{-1, 0, 1} {
+		start, end, ok := c.window(now.AddDate(0, 0, days))
+		if !ok {
+			continue
+		}
+
+		if !now.Before(start) && now.Before(end) {
+			active = true
+		}
+
+		for _, t := range []time.Time{start, end} {
+			if t.After(now) && (changeTime.IsZero() || t.Before(changeTime)) {
+				changeTime = t
+			}
+		}
+	}
+
+	if changeTime.IsZero() {
+		// Nothing happens soon (near the poles). Check again tomorrow.
+		changeTime = now.Add(24 * time.Hour)
+	}
+
+	return active, changeTime
+}
+
+func (c *betweenCondition) Handler() {
+	// Set the timer to fire immediately to send the initial state.
+	timer := c.clock.NewTimer(0)
+
+	c.handled()
+
+	for {
+		select {
+		case <-timer.C:
+			now := c.clock.Now()
+			active, changeTime := c.findActiveAndNextChange(now)
+			c.resetTimer(timer, changeTime.Sub(now))
+
+			c.sendResult(active)
+
+		case <-c.StopChan:
+			timer.Stop()
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
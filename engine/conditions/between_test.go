@@ -0,0 +1,121 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func setupBetweenCondition(c *check.C, timezone, start, end string) *betweenCondition {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/latitude", LATITUDE, status.UNCHECKED_REVISION), check.IsNil)
+	c.Assert(s.Set("status://server/longitude", LONGITUDE, status.UNCHECKED_REVISION), check.IsNil)
+	c.Assert(s.Set("status://server/timezone", timezone, status.UNCHECKED_REVISION), check.IsNil)
+
+	body := &status.Status{}
+	c.Assert(body.Set("status://start", start, 0), check.IsNil)
+	c.Assert(body.Set("status://end", end, 1), check.IsNil)
+
+	cond, e := newBetweenCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	return cond
+}
+
+func checkBetween(c *check.C, cond *betweenCondition, now time.Time, active bool, change time.Time) {
+	a, ch := cond.findActiveAndNextChange(now)
+	c.Check(a, check.Equals, active, check.Commentf("%s", now))
+	c.Check(ch.Equal(change), check.Equals, true, check.Commentf("%s: %s != %s", now, ch, change))
+}
+
+func (suite *MySuite) TestBetweenSameDay(c *check.C) {
+	cond := setupBetweenCondition(c, "UTC", "9:00AM", "17:30")
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2014, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	checkBetween(c, cond, at(12, 8, 0), false, at(12, 9, 0))
+	checkBetween(c, cond, at(12, 9, 0), true, at(12, 17, 30))
+	checkBetween(c, cond, at(12, 17, 29), true, at(12, 17, 30))
+	checkBetween(c, cond, at(12, 17, 30), false, at(13, 9, 0))
+	checkBetween(c, cond, at(12, 23, 0), false, at(13, 9, 0))
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestBetweenCrossesMidnight(c *check.C) {
+	cond := setupBetweenCondition(c, "UTC", "22:00", "06:30")
+
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2014, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	checkBetween(c, cond, at(12, 12, 0), false, at(12, 22, 0))
+	checkBetween(c, cond, at(12, 22, 0), true, at(13, 6, 30))
+	checkBetween(c, cond, at(13, 0, 0), true, at(13, 6, 30))
+	checkBetween(c, cond, at(13, 6, 30), false, at(13, 22, 0))
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestBetweenDaylightSavings(c *check.C) {
+	pacific, e := time.LoadLocation("America/Los_Angeles")
+	c.Assert(e, check.IsNil)
+
+	cond := setupBetweenCondition(c, "America/Los_Angeles", "22:00", "06:30")
+
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2014, month, day, hour, minute, 0, 0, pacific)
+	}
+
+	// Clocks sprang forward at 2AM on March 9th 2014. The window is an hour
+	// shorter, but still ends at 6:30 local time.
+	checkBetween(c, cond, at(time.March, 8, 23, 0), true, at(time.March, 9, 6, 30))
+	checkBetween(c, cond, at(time.March, 9, 3, 30), true, at(time.March, 9, 6, 30))
+	checkBetween(c, cond, at(time.March, 9, 7, 0), false, at(time.March, 9, 22, 0))
+
+	// Clocks fell back at 2AM on November 2nd 2014.
+	checkBetween(c, cond, at(time.November, 1, 23, 0), true, at(time.November, 2, 6, 30))
+	end := at(time.November, 2, 6, 30)
+	c.Check(end.Sub(at(time.November, 1, 22, 0)), check.Equals, 9*time.Hour+30*time.Minute)
+
+	// Times are always in the configured timezone, whatever now's location is.
+	checkBetween(c, cond, at(time.June, 12, 23, 0).UTC(), true, at(time.June, 13, 6, 30))
+
+	cond.Stop()
+
+	// A start skipped by daylight savings moves forward.
+	cond = setupBetweenCondition(c, "America/Los_Angeles", "2:30AM", "4:00AM")
+	checkBetween(c, cond, at(time.March, 9, 1, 0), false, time.Date(2014, time.March, 9, 10, 30, 0, 0, time.UTC))
+	cond.Stop()
+}
+
+func (suite *MySuite) TestBetweenSolar(c *check.C) {
+	pacific, e := time.LoadLocation("America/Los_Angeles")
+	c.Assert(e, check.IsNil)
+
+	cond := setupBetweenCondition(c, "America/Los_Angeles", "sunset", "sunrise-15m")
+
+	testDay := time.Date(2014, time.June, 13, 12, 0, 0, 0, pacific)
+	sunset, ok := solarTime{solarEvents["sunset"], 0, LATITUDE, LONGITUDE}.on(testDay)
+	c.Assert(ok, check.Equals, true)
+	sunrise, ok := solarTime{solarEvents["sunrise"], 0, LATITUDE, LONGITUDE}.on(testDay.AddDate(0, 0, 1))
+	c.Assert(ok, check.Equals, true)
+
+	checkBetween(c, cond, sunset.Add(-time.Minute), false, sunset)
+	checkBetween(c, cond, sunset, true, sunrise.Add(-15*time.Minute))
+	nextSunset, ok := solarTime{solarEvents["sunset"], 0, LATITUDE, LONGITUDE}.on(testDay.AddDate(0, 0, 1))
+	c.Assert(ok, check.Equals, true)
+	checkBetween(c, cond, sunrise.Add(-15*time.Minute), false, nextSunset)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestBetweenBadJson(c *check.C) {
+	validateConditionBadJson(c, `{"test": "between", "start": "22:00"}`)
+	validateConditionBadJson(c, `{"test": "between", "end": "22:00"}`)
+	validateConditionBadJson(c, `{"test": "between", "start": "bedtime", "end": "22:00"}`)
+	validateConditionBadJson(c, `{"test": "between", "start": "sunset+soon", "end": "22:00"}`)
+}
@@ -0,0 +1,316 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/fsnotify.v1"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// A range of days in the year, which may wrap around the new year.
+type dateRange struct {
+	from, to monthDay
+}
+
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+func (d monthDay) before(other monthDay) bool {
+	return d.month < other.month || (d.month == other.month && d.day < other.day)
+}
+
+func (r dateRange) contains(d monthDay) bool {
+	if r.to.before(r.from) {
+		// Wraps around the new year.
+		return !d.before(r.from) || !r.to.before(d)
+	}
+	return !d.before(r.from) && !r.to.before(d)
+}
+
+type calendarCondition struct {
+	base
+
+	weekdays map[time.Weekday]bool // nil for any day.
+	dates    []dateRange           // nil for any date.
+
+	icsFile string         // "" if no calendar file is used.
+	summary *regexp.Regexp // nil to match every event.
+	events  []*icsEvent
+
+	watcher  *fsnotify.Watcher
+	location *time.Location
+}
+
+var weekdayNames = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+func newCalendarCondition(s *status.Status, clk clock.Clock, body *status.Status) (*calendarCondition, error) {
+	c := &calendarCondition{base: newBase(s, clk)}
+
+	var e error
+	if c.location, e = options.Location(s); e != nil {
+		return nil, fmt.Errorf("Calendar condition: %s", e.Error())
+	}
+
+	if c.weekdays, e = parseWeekdays(body); e != nil {
+		return nil, e
+	}
+
+	if c.dates, e = parseDateRanges(body); e != nil {
+		return nil, e
+	}
+
+	if summary, _, e := body.GetString("status://summary"); e == nil {
+		if c.summary, e = regexp.Compile(summary); e != nil {
+			return nil, fmt.Errorf("Calendar condition: %s", e.Error())
+		}
+	}
+
+	if icsFile, _, e := body.GetString("status://ics"); e == nil {
+		// Relative file names are in the config dir.
+		if !filepath.IsAbs(icsFile) {
+			icsFile = filepath.Join(s.GetStringWithDefault(options.CONFIG_DIR, ""), icsFile)
+		}
+
+		c.icsFile = icsFile
+		if e = c.loadIcs(); e != nil {
+			return nil, e
+		}
+
+		// Watch the directory, since editors often replace the file.
+		if c.watcher, e = fsnotify.NewWatcher(); e != nil {
+			return nil, e
+		}
+		if e = c.watcher.Add(filepath.Dir(icsFile)); e != nil {
+			c.watcher.Close()
+			return nil, e
+		}
+	} else if c.summary != nil {
+		return nil, fmt.Errorf("Calendar condition: 'summary' requires 'ics'.")
+	}
+
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+
+	return c, nil
+}
+
+// Parse "weekdays": ["mon", "tue", ...]. Full day names, "weekdays" and
+// "weekends" are also accepted.
+func parseWeekdays(body *status.Status) (map[time.Weekday]bool, error) {
+	raw, _, e := body.Get("status://weekdays")
+	if e != nil {
+		return nil, nil
+	}
+
+	names, ok := raw.([]interface{})
+	if !ok || len(names) == 0 {
+		return nil, fmt.Errorf("Calendar condition: 'weekdays' must be a non-empty list.")
+	}
+
+	result := map[time.Weekday]bool{}
+	for _, name := range names {
+		str, _ := name.(string)
+		str = strings.ToLower(str)
+
+		days, found := weekdayNames[str]
+		if !found && len(str) > 3 {
+			// Full names, like "monday".
+			days, found = weekdayNames[str[:3]]
+			found = found && strings.ToLower(days[0].String()) == str
+		}
+
+		if !found {
+			return nil, fmt.Errorf("Calendar condition: Unknown weekday: %#v", name)
+		}
+
+		for _, day := range days {
+			result[day] = true
+		}
+	}
+
+	return result, nil
+}
+
+// Parse "dates": ["12/25", "12/1-1/6", ...].
+func parseDateRanges(body *status.Status) ([]dateRange, error) {
+	raw, _, e := body.Get("status://dates")
+	if e != nil {
+		return nil, nil
+	}
+
+	ranges, ok := raw.([]interface{})
+	if !ok || len(ranges) == 0 {
+		return nil, fmt.Errorf("Calendar condition: 'dates' must be a non-empty list.")
+	}
+
+	result := []dateRange{}
+	for _, r := range ranges {
+		str, _ := r.(string)
+		parts := strings.Split(str, "-")
+		if len(parts) > 2 {
+			return nil, fmt.Errorf("Calendar condition: Invalid date range: %#v", r)
+		}
+
+		from, e := parseMonthDay(parts[0])
+		if e != nil {
+			return nil, e
+		}
+
+		to := from
+		if len(parts) == 2 {
+			if to, e = parseMonthDay(parts[1]); e != nil {
+				return nil, e
+			}
+		}
+
+		result = append(result, dateRange{from, to})
+	}
+
+	return result, nil
+}
+
+// Parse "12/25".
+func parseMonthDay(value string) (monthDay, error) {
+	// Use a leap year, so "2/29" is valid.
+	t, e := time.Parse("2006/1/2", "2000/"+strings.TrimSpace(value))
+	if e != nil {
+		return monthDay{}, fmt.Errorf("Calendar condition: Invalid date: %q", value)
+	}
+
+	return monthDay{t.Month(), t.Day()}, nil
+}
+
+// (Re)load the calendar file.
+func (c *calendarCondition) loadIcs() error {
+	f, e := os.Open(c.icsFile)
+	if e != nil {
+		return fmt.Errorf("Calendar condition: %s", e.Error())
+	}
+	defer f.Close()
+
+	events, e := parseIcs(f, c.location)
+	if e != nil {
+		return fmt.Errorf("Calendar condition: %s: %s", c.icsFile, e.Error())
+	}
+
+	// Keep only the events we care about.
+	c.events = []*icsEvent{}
+	for _, event := range events {
+		if c.summary == nil || c.summary.MatchString(event.summary) {
+			c.events = append(c.events, event)
+		}
+	}
+
+	return nil
+}
+
+// Find our value at now, and when it next needs to be checked.
+func (c *calendarCondition) findActiveAndNextChange(now time.Time) (active bool, changeTime time.Time) {
+	now = now.In(c.location)
+
+	// Day based checks change at midnight.
+	year, month, day := now.Date()
+	changeTime = time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+
+	active = c.weekdays == nil || c.weekdays[now.Weekday()]
+
+	if active && c.dates != nil {
+		inRange := false
+		for _, r := range c.dates {
+			inRange = inRange || r.contains(monthDay{month, day})
+		}
+		active = inRange
+	}
+
+	if c.icsFile == "" {
+		return active, changeTime
+	}
+
+	eventActive := false
+	for _, event := range c.events {
+		isActive, until, nextStart := event.state(now)
+		eventActive = eventActive || isActive
+
+		if isActive && until.Before(changeTime) {
+			changeTime = until
+		}
+		if !nextStart.IsZero() && nextStart.Before(changeTime) {
+			changeTime = nextStart
+		}
+	}
+
+	return active && eventActive, changeTime
+}
+
+func (c *calendarCondition) Handler() {
+	// Set the timer to fire immediately to send the initial state.
+	timer := c.clock.NewTimer(0)
+
+	// A nil channel never receives, so we can select without a watcher.
+	var fileEvents <-chan fsnotify.Event
+	var fileErrors <-chan error
+	if c.watcher != nil {
+		fileEvents, fileErrors = c.watcher.Events, c.watcher.Errors
+	}
+
+	update := func() {
+		now := c.clock.Now()
+		active, changeTime := c.findActiveAndNextChange(now)
+		c.resetTimer(timer, changeTime.Sub(now))
+		c.sendResult(active)
+	}
+
+	c.handled()
+
+	for {
+		select {
+		case <-timer.C:
+			update()
+			c.handled()
+
+		case ev := <-fileEvents:
+			if filepath.Clean(ev.Name) != filepath.Clean(c.icsFile) {
+				continue
+			}
+
+			if e := c.loadIcs(); e != nil {
+				// Keep the old events, the file may be half written.
+				log.Println(e.Error())
+				continue
+			}
+
+			timer.Stop()
+			update()
+
+		case e := <-fileErrors:
+			log.Printf("Calendar condition: %s: %v", c.icsFile, e)
+
+		case <-c.StopChan:
+			timer.Stop()
+			if c.watcher != nil {
+				c.watcher.Close()
+			}
+			c.StopChan <- true
+			return
+		}
+	}
+}
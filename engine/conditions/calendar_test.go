@@ -0,0 +1,135 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+)
+
+func setupCalendarCondition(c *check.C, s *status.Status, bodyJson string) *calendarCondition {
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(bodyJson), 0), check.IsNil)
+
+	cond, e := newCalendarCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	return cond
+}
+
+func (suite *MySuite) TestCalendarWeekdays(c *check.C) {
+	cond := setupCalendarCondition(c, &status.Status{}, `{"weekdays": ["weekdays", "Sunday"]}`)
+
+	// June 14 2014 is a Saturday.
+	active, change := cond.findActiveAndNextChange(time.Date(2014, time.June, 14, 10, 0, 0, 0, time.Local))
+	c.Check(active, check.Equals, false)
+	c.Check(change, check.Equals, time.Date(2014, time.June, 15, 0, 0, 0, 0, time.Local))
+
+	active, _ = cond.findActiveAndNextChange(time.Date(2014, time.June, 15, 10, 0, 0, 0, time.Local))
+	c.Check(active, check.Equals, true)
+
+	active, _ = cond.findActiveAndNextChange(time.Date(2014, time.June, 16, 10, 0, 0, 0, time.Local))
+	c.Check(active, check.Equals, true)
+
+	validateChannelRead(c, cond, cond.weekdays[time.Now().Weekday()])
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCalendarDates(c *check.C) {
+	cond := setupCalendarCondition(c, &status.Status{}, `{"dates": ["12/1-1/6", "7/4"]}`)
+
+	checkDate := func(month time.Month, day int, expected bool) {
+		active, _ := cond.findActiveAndNextChange(time.Date(2014, month, day, 10, 0, 0, 0, time.Local))
+		c.Check(active, check.Equals, expected, check.Commentf("%s %d", month, day))
+	}
+
+	checkDate(time.November, 30, false)
+	checkDate(time.December, 1, true)
+	checkDate(time.December, 31, true)
+	checkDate(time.January, 1, true)
+	checkDate(time.January, 6, true)
+	checkDate(time.January, 7, false)
+	checkDate(time.July, 3, false)
+	checkDate(time.July, 4, true)
+	checkDate(time.July, 5, false)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCalendarIcs(c *check.C) {
+	configDir := c.MkDir()
+	icsFile := filepath.Join(configDir, "holidays.ics")
+	c.Assert(ioutil.WriteFile(icsFile, []byte(testIcs), 0644), check.IsNil)
+
+	s := &status.Status{}
+	c.Assert(s.Set(options.CONFIG_DIR, configDir, 0), check.IsNil)
+
+	cond := setupCalendarCondition(c, s, `{"ics": "holidays.ics", "summary": "^Christmas$"}`)
+	c.Check(len(cond.events), check.Equals, 1)
+
+	christmas := time.Date(2016, time.December, 25, 0, 0, 0, 0, time.Local)
+
+	active, change := cond.findActiveAndNextChange(christmas.Add(-time.Minute))
+	c.Check(active, check.Equals, false)
+	c.Check(change, check.Equals, christmas)
+
+	active, change = cond.findActiveAndNextChange(christmas.Add(time.Hour))
+	c.Check(active, check.Equals, true)
+	c.Check(change, check.Equals, christmas.AddDate(0, 0, 1))
+
+	validateChannelRead(c, cond, false)
+
+	// Replace the file with an event that's happening now, and expect a reload.
+	now := time.Now().UTC()
+	c.Assert(ioutil.WriteFile(icsFile, []byte("BEGIN:VEVENT\nSUMMARY:Christmas\n"+
+		"DTSTART:"+now.Add(-time.Hour).Format("20060102T150405Z")+"\n"+
+		"DTEND:"+now.Add(time.Hour).Format("20060102T150405Z")+"\n"+
+		"END:VEVENT\n"), 0644), check.IsNil)
+
+	validateChannelRead(c, cond, true)
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCalendarBadJson(c *check.C) {
+	validateConditionBadJson(c, `{"test": "calendar", "weekdays": []}`)
+	validateConditionBadJson(c, `{"test": "calendar", "weekdays": ["someday"]}`)
+	validateConditionBadJson(c, `{"test": "calendar", "dates": ["13/1"]}`)
+	validateConditionBadJson(c, `{"test": "calendar", "dates": ["1/1-2/1-3/1"]}`)
+	validateConditionBadJson(c, `{"test": "calendar", "summary": "x"}`)
+	validateConditionBadJson(c, `{"test": "calendar", "ics": "/does/not/exist.ics"}`)
+}
+
+func (suite *MySuite) TestCalendarTimezone(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/timezone", "Asia/Tokyo", 0), check.IsNil)
+
+	cond := setupCalendarCondition(c, s, `{"weekdays": ["sat"]}`)
+
+	// Friday evening in UTC is already Saturday in Tokyo.
+	active, change := cond.findActiveAndNextChange(time.Date(2014, time.June, 13, 20, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+	c.Check(change.Equal(time.Date(2014, time.June, 14, 15, 0, 0, 0, time.UTC)), check.Equals, true)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCalendarDaylightSavings(c *check.C) {
+	pacific, e := time.LoadLocation("America/Los_Angeles")
+	c.Assert(e, check.IsNil)
+
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/timezone", "America/Los_Angeles", 0), check.IsNil)
+
+	cond := setupCalendarCondition(c, s, `{"weekdays": ["sun"]}`)
+
+	// The day clocks spring forward is only 23 hours long.
+	start := time.Date(2014, time.March, 9, 0, 0, 0, 0, pacific)
+	active, change := cond.findActiveAndNextChange(start)
+	c.Check(active, check.Equals, true)
+	c.Check(change.Sub(start), check.Equals, 23*time.Hour)
+
+	cond.Stop()
+}
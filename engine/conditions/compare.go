@@ -0,0 +1,275 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Test a single watched value. wasTrue is the last result for the same URL,
+// which is used to apply hysteresis.
+type compareTest func(value interface{}, wasTrue bool) bool
+
+type compareCondition struct {
+	base
+
+	test    compareTest
+	all     bool            // All matches must pass, instead of any.
+	results map[string]bool // Last result for each matching URL.
+
+	watchChan <-chan status.UrlMatches
+}
+
+func newCompareCondition(s *status.Status, clk clock.Clock, body *status.Status) (*compareCondition, error) {
+
+	watchUrl, _, e := body.GetString("status://watch")
+	if e != nil {
+		return nil, e
+	}
+
+	op, _, e := body.GetString("status://op")
+	if e != nil {
+		return nil, fmt.Errorf("Compare condition: No 'op'.")
+	}
+
+	var hysteresis float64
+	if raw, _, e := body.Get("status://hysteresis"); e == nil {
+		var ok bool
+		if hysteresis, ok = numberValue(raw); !ok || hysteresis < 0 {
+			return nil, fmt.Errorf("Compare condition: Invalid 'hysteresis': %#v", raw)
+		}
+	}
+
+	var all bool
+	switch match := body.GetStringWithDefault("status://match", "any"); match {
+	case "any":
+	case "all":
+		all = true
+	default:
+		return nil, fmt.Errorf("Compare condition: Invalid 'match': %s", match)
+	}
+
+	test, e := newCompareTest(body, op, hysteresis)
+	if e != nil {
+		return nil, e
+	}
+
+	// Start watching for updates.
+	watchChan, e := s.WatchForUpdate(watchUrl)
+	if e != nil {
+		return nil, e
+	}
+
+	// Create our condition.
+	c := &compareCondition{newBase(s, clk), test, all, map[string]bool{}, watchChan}
+
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+
+	return c, nil
+}
+
+// Build the test for an op. Numeric ops (<, <=, >, >=, between) accept
+// hysteresis, which makes a passing value keep passing until it moves that far
+// past the threshold.
+func newCompareTest(body *status.Status, op string, hysteresis float64) (compareTest, error) {
+	switch op {
+	case "<", "<=", ">", ">=":
+		threshold, e := numberOption(body, "value")
+		if e != nil {
+			return nil, e
+		}
+
+		// The direction to move the threshold, once we pass.
+		if op == ">" || op == ">=" {
+			hysteresis = -hysteresis
+		}
+
+		return func(value interface{}, wasTrue bool) bool {
+			v, ok := numberValue(value)
+			if !ok {
+				return false
+			}
+
+			t := threshold
+			if wasTrue {
+				t += hysteresis
+			}
+
+			switch op {
+			case "<":
+				return v < t
+			case "<=":
+				return v <= t
+			case ">":
+				return v > t
+			default:
+				return v >= t
+			}
+		}, nil
+
+	case "between":
+		min, e := numberOption(body, "min")
+		if e != nil {
+			return nil, e
+		}
+
+		max, e := numberOption(body, "max")
+		if e != nil {
+			return nil, e
+		}
+
+		return func(value interface{}, wasTrue bool) bool {
+			v, ok := numberValue(value)
+			if !ok {
+				return false
+			}
+
+			if wasTrue {
+				return v >= min-hysteresis && v <= max+hysteresis
+			}
+			return v >= min && v <= max
+		}, nil
+	}
+
+	if hysteresis != 0 {
+		return nil, fmt.Errorf("Compare condition: 'hysteresis' not allowed with op %s", op)
+	}
+
+	switch op {
+	case "==", "!=":
+		expected, _, e := body.Get("status://value")
+		if e != nil {
+			return nil, fmt.Errorf("Compare condition: No 'value'.")
+		}
+
+		return func(value interface{}, wasTrue bool) bool {
+			return compareEqual(value, expected) == (op == "==")
+		}, nil
+
+	case "contains":
+		substring, _, e := body.GetString("status://value")
+		if e != nil {
+			return nil, fmt.Errorf("Compare condition: 'value' must be a string.")
+		}
+
+		return func(value interface{}, wasTrue bool) bool {
+			str, ok := value.(string)
+			return ok && strings.Contains(str, substring)
+		}, nil
+
+	case "regex":
+		expr, _, e := body.GetString("status://value")
+		if e != nil {
+			return nil, fmt.Errorf("Compare condition: 'value' must be a string.")
+		}
+
+		re, e := regexp.Compile(expr)
+		if e != nil {
+			return nil, fmt.Errorf("Compare condition: %s", e.Error())
+		}
+
+		return func(value interface{}, wasTrue bool) bool {
+			str, ok := value.(string)
+			return ok && re.MatchString(str)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("Compare condition: Unknown 'op': %s", op)
+	}
+}
+
+// Read a required numeric option from a condition body.
+func numberOption(body *status.Status, name string) (float64, error) {
+	raw, _, e := body.Get("status://" + name)
+	if e != nil {
+		return 0, fmt.Errorf("Compare condition: No '%s'.", name)
+	}
+
+	value, ok := numberValue(raw)
+	if !ok {
+		return 0, fmt.Errorf("Compare condition: '%s' is not a number: %#v", name, raw)
+	}
+
+	return value, nil
+}
+
+// Convert a status value to a number, if possible. Numeric strings (which
+// some adapters report) are converted too.
+func numberValue(value interface{}) (float64, bool) {
+	switch t := value.(type) {
+	case float64:
+		return t, true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case string:
+		f, e := strconv.ParseFloat(strings.TrimSpace(t), 64)
+		return f, e == nil
+	default:
+		return 0, false
+	}
+}
+
+// Are two values equal? Numbers are compared by value, regardless of type.
+func compareEqual(left, right interface{}) bool {
+	_, leftString := left.(string)
+	_, rightString := right.(string)
+
+	if !leftString && !rightString {
+		l, lOk := numberValue(left)
+		r, rOk := numberValue(right)
+		if lOk && rOk {
+			return l == r
+		}
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// Find our result for a new set of matches.
+func (c *compareCondition) update(matches status.UrlMatches) bool {
+	results := map[string]bool{}
+	passed := 0
+
+	for url, match := range matches {
+		results[url] = c.test(match.Value, c.results[url])
+		if results[url] {
+			passed += 1
+		}
+	}
+
+	c.results = results
+
+	if len(matches) == 0 {
+		return false
+	}
+
+	if c.all {
+		return passed == len(matches)
+	}
+	return passed > 0
+}
+
+func (c *compareCondition) Handler() {
+	c.handled()
+
+	for {
+		select {
+		case matches := <-c.watchChan:
+			c.sendResult(c.update(matches))
+
+		case <-c.StopChan:
+			c.status.ReleaseWatch(c.watchChan)
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
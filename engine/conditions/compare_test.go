@@ -0,0 +1,159 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+)
+
+func setupCompareCondition(c *check.C, condJson string) (*status.Status, *compareCondition) {
+	s := &status.Status{}
+
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(condJson), 0), check.IsNil)
+
+	cond, e := newCompareCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	return s, cond
+}
+
+// Set a value, and check the result that should (or should not) follow.
+func checkCompare(c *check.C, s *status.Status, cond Condition, url string, value interface{}, expected ...bool) {
+	c.Assert(s.Set(url, value, status.UNCHECKED_REVISION), check.IsNil)
+	for _, e := range expected {
+		validateChannelRead(c, cond, e)
+	}
+	validateChannelEmpty(c, cond)
+}
+
+func (suite *MySuite) TestCompareParsingBad(c *check.C) {
+	validateConditionBadJson(c, `{"test": "compare", "op": ">", "value": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "value": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": "~", "value": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": ">"}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": ">", "value": "x"}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": "between", "min": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": "regex", "value": "("}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": "contains", "value": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": "!=", "value": 1, "hysteresis": 1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": ">", "value": 1, "hysteresis": -1}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "status://a", "op": ">", "value": 1, "match": "most"}`)
+	validateConditionBadJson(c, `{"test": "compare", "watch": "bad url", "op": ">", "value": 1}`)
+}
+
+func (suite *MySuite) TestCompareStartStop(c *check.C) {
+	statusJson := `{"temp": 80, "name": "front door"}`
+
+	validateConditionJson(c, statusJson, `{"test": "compare", "watch": "status://temp", "op": ">", "value": 78}`, true)
+	validateConditionJson(c, statusJson, `{"test": "compare", "watch": "status://temp", "op": "<=", "value": 78}`, false)
+	validateConditionJson(c, statusJson, `{"test": "compare", "watch": "status://missing", "op": "<", "value": 78}`, false)
+	validateConditionJson(c, statusJson,
+		`{"test": "compare", "watch": "status://temp", "op": "between", "min": 70, "max": 80}`, true)
+	validateConditionJson(c, statusJson, `{"test": "compare", "watch": "status://temp", "op": "!=", "value": 80}`, false)
+	validateConditionJson(c, statusJson, `{"test": "compare", "watch": "status://temp", "op": "==", "value": 80}`, true)
+	validateConditionJson(c, statusJson,
+		`{"test": "compare", "watch": "status://name", "op": "contains", "value": "door"}`, true)
+	validateConditionJson(c, statusJson,
+		`{"test": "compare", "watch": "status://name", "op": "regex", "value": "^back"}`, false)
+}
+
+func (suite *MySuite) TestCompareThreshold(c *check.C) {
+	s, cond := setupCompareCondition(c, `{"watch": "status://temp", "op": ">=", "value": 78}`)
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	checkCompare(c, s, cond, "status://temp", 77)
+	checkCompare(c, s, cond, "status://temp", 78, true)
+	checkCompare(c, s, cond, "status://temp", 79)
+	checkCompare(c, s, cond, "status://temp", 77.9, false)
+
+	// Numeric strings are numbers.
+	checkCompare(c, s, cond, "status://temp", "80", true)
+
+	// Other values aren't.
+	checkCompare(c, s, cond, "status://temp", "hot", false)
+	checkCompare(c, s, cond, "status://temp", true)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCompareHysteresis(c *check.C) {
+	s, cond := setupCompareCondition(c, `{"watch": "status://battery", "op": "<", "value": 20, "hysteresis": 5}`)
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	checkCompare(c, s, cond, "status://battery", 20)
+	checkCompare(c, s, cond, "status://battery", 19, true)
+
+	// Flapping around the threshold doesn't change anything.
+	checkCompare(c, s, cond, "status://battery", 21)
+	checkCompare(c, s, cond, "status://battery", 19)
+	checkCompare(c, s, cond, "status://battery", 24.9)
+	checkCompare(c, s, cond, "status://battery", 25, false)
+	checkCompare(c, s, cond, "status://battery", 21)
+	checkCompare(c, s, cond, "status://battery", 19, true)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCompareBetweenHysteresis(c *check.C) {
+	s, cond := setupCompareCondition(c,
+		`{"watch": "status://temp", "op": "between", "min": 68, "max": 72, "hysteresis": 1}`)
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	checkCompare(c, s, cond, "status://temp", 67.5)
+	checkCompare(c, s, cond, "status://temp", 68, true)
+	checkCompare(c, s, cond, "status://temp", 67.5)
+	checkCompare(c, s, cond, "status://temp", 72.5)
+	checkCompare(c, s, cond, "status://temp", 73.5, false)
+	checkCompare(c, s, cond, "status://temp", 72.5)
+	checkCompare(c, s, cond, "status://temp", 70, true)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCompareAnyAll(c *check.C) {
+	s, any := setupCompareCondition(c, `{"watch": "status://*/temp", "op": ">", "value": 78}`)
+
+	// Watch the same status with "all".
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(
+		`{"watch": "status://*/temp", "op": ">", "value": 78, "match": "all"}`), 0), check.IsNil)
+	all, e := newCompareCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	validateChannelRead(c, any, false)
+	validateChannelRead(c, all, false)
+
+	c.Assert(s.Set("status://kitchen/temp", 80, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelRead(c, any, true)
+	validateChannelRead(c, all, true)
+
+	c.Assert(s.Set("status://porch/temp", 70, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelEmpty(c, any)
+	validateChannelRead(c, all, false)
+
+	c.Assert(s.Set("status://porch/temp", 79, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelEmpty(c, any)
+	validateChannelRead(c, all, true)
+
+	c.Assert(s.Set("status://kitchen/temp", 70, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelEmpty(c, any)
+	validateChannelRead(c, all, false)
+
+	// Nothing matching is false for both.
+	c.Assert(s.Update(func(tx *status.Tx) error {
+		tx.Remove("status://kitchen", status.UNCHECKED_REVISION)
+		return tx.Remove("status://porch", status.UNCHECKED_REVISION)
+	}), check.IsNil)
+	validateChannelRead(c, any, false)
+	validateChannelEmpty(c, all)
+
+	any.Stop()
+	all.Stop()
+}
@@ -2,18 +2,22 @@ package conditions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
+	"time"
 )
 
 type Condition interface {
 	Result() <-chan bool
+	Explain() Explanation
 	Stop()
 }
 
 // A constructor interface all rules are expected to implement.
 func NewCondition(
 	s *status.Status,
+	clk clock.Clock,
 	body *status.Status) (c Condition, e error) {
 
 	conditionValue, _, e := body.Get("status://")
@@ -28,7 +32,7 @@ func NewCondition(
 		if e != nil {
 			return nil, fmt.Errorf("Condition: url invalid: %s: %s", typedValue, e.Error())
 		}
-		return NewCondition(s, redirectBody)
+		return NewCondition(s, clk, redirectBody)
 
 	case []interface{}:
 		// A array is syntactic sugur for an 'and' condition.
@@ -41,7 +45,7 @@ func NewCondition(
 		if e != nil {
 			panic(e)
 		}
-		return NewCondition(s, andBody)
+		return NewCondition(s, clk, andBody)
 
 	case map[string]interface{}:
 		// We received a dictionary, this is (hopefully) a registered action.
@@ -50,45 +54,127 @@ func NewCondition(
 			return nil, fmt.Errorf("Condition: No condition specified: %s", conditionName)
 		}
 
-		switch conditionName {
-		case "true":
-			return newConstCondition(s, body, true)
-		case "false":
-			return newConstCondition(s, body, false)
-		case "after":
-			return newAfterCondition(s, body)
-		case "and":
-			return newAndCondition(s, body)
-		case "day":
-			return newDaylightCondition(s, body, true)
-		case "night":
-			return newDaylightCondition(s, body, false)
-		case "time":
-			return newTimeCondition(s, body)
-		case "watch":
-			return newWatchCondition(s, body)
-		default:
-			return nil, fmt.Errorf("Condition: No known type: %s", conditionName)
+		c, e = newTypedCondition(s, clk, body, conditionName)
+		if e != nil {
+			return nil, e
 		}
 
+		if d, ok := c.(describer); ok {
+			d.describe(conditionName, typedValue)
+		}
+		return c, nil
+
 	default:
 		return nil, fmt.Errorf("Condition: Doin't understand %#v", conditionValue)
 	}
 }
 
+// Create a condition from a body with a "test" of conditionName.
+func newTypedCondition(
+	s *status.Status,
+	clk clock.Clock,
+	body *status.Status,
+	conditionName string) (Condition, error) {
+
+	switch conditionName {
+	case "true":
+		return newConstCondition(s, clk, body, true)
+	case "false":
+		return newConstCondition(s, clk, body, false)
+	case "hold":
+		return newHoldCondition(s, clk, body)
+	case "after":
+		return newAfterCondition(s, clk, body)
+	case "and":
+		return newAndCondition(s, clk, body)
+	case "between":
+		return newBetweenCondition(s, clk, body)
+	case "calendar":
+		return newCalendarCondition(s, clk, body)
+	case "compare":
+		return newCompareCondition(s, clk, body)
+	case "count":
+		return newCountCondition(s, clk, body)
+	case "count_within":
+		return newCountWithinCondition(s, clk, body)
+	case "day":
+		return newDaylightCondition(s, clk, body, true)
+	case "debounce":
+		return newDebounceCondition(s, clk, body)
+	case "expr":
+		return newExprCondition(s, clk, body)
+	case "night":
+		return newDaylightCondition(s, clk, body, false)
+	case "not":
+		return newNotCondition(s, clk, body)
+	case "or":
+		return newOrCondition(s, clk, body)
+	case "throttle":
+		return newThrottleCondition(s, clk, body)
+	case "time":
+		return newTimeCondition(s, clk, body)
+	case "watch":
+		return newWatchCondition(s, clk, body)
+	case "xor":
+		return newXorCondition(s, clk, body)
+	default:
+		return nil, fmt.Errorf("Condition: No known type: %s", conditionName)
+	}
+}
+
+// Create the inner "condition" of a condition which wraps another. kind names
+// the outer condition for errors.
+func newInnerCondition(s *status.Status, clk clock.Clock, body *status.Status, kind string) (Condition, error) {
+	subConditionBody, _, e := body.Get("status://condition")
+	if e != nil {
+		return nil, fmt.Errorf("%s condition: No 'condition'.", kind)
+	}
+
+	conditionBodyStatus := &status.Status{}
+	conditionBodyStatus.Set("status://", subConditionBody, status.UNCHECKED_REVISION)
+
+	condition, e := NewCondition(s, clk, conditionBodyStatus)
+	if e != nil {
+		return nil, fmt.Errorf("%s condition: (%#v): %s", kind, subConditionBody, e.Error())
+	}
+
+	return condition, nil
+}
+
+// Read a positive duration ("5s", "2h", etc) from a condition body.
+func durationOption(body *status.Status, name string, kind string) (time.Duration, error) {
+	durationStr, _, e := body.GetString("status://" + name)
+	if e != nil {
+		return 0, fmt.Errorf("%s condition: No '%s'.", kind, name)
+	}
+
+	duration, e := time.ParseDuration(durationStr)
+	if e != nil {
+		return 0, fmt.Errorf("%s condition: %s", kind, e.Error())
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s condition: '%s' must be positive: %s", kind, name, durationStr)
+	}
+
+	return duration, nil
+}
+
 // The base type all rules should compose with.
 type base struct {
 	status *status.Status
+	clock  clock.Clock
 
 	initialSent bool
 	lastSent    bool
 
 	resultChan chan bool
+	explain    *explainState
 	stoppable.Base
 }
 
-func newBase(s *status.Status) base {
-	return base{s, false, false, make(chan bool, 2), stoppable.NewBase()}
+func newBase(s *status.Status, clk clock.Clock) base {
+	return base{s, clk, false, false, make(chan bool, 2), &explainState{}, stoppable.NewBase()}
 }
 
 func (b *base) Result() <-chan bool {
@@ -105,6 +191,9 @@ func (b *base) sendResult(result bool) {
 
 	b.initialSent = true
 	b.lastSent = result
+	b.recordResult(result)
+
+	clock.Sent(b.clock)
 
 	select {
 	case b.resultChan <- b.lastSent:
@@ -113,6 +202,8 @@ func (b *base) sendResult(result bool) {
 		select {
 		case <-b.resultChan:
 			// If we were blocked, try to clear one value from the channel buffer..
+			// It won't be handled now.
+			clock.Handled(b.clock)
 		default:
 		}
 
@@ -121,10 +212,23 @@ func (b *base) sendResult(result bool) {
 	}
 }
 
+// Start a handler goroutine. Starting counts as an event sent to it, so the
+// handler must call handled once it's ready for other events, as well as after
+// each timer firing, watch update or inner condition result.
+func (b *base) startHandler(handler func()) {
+	clock.Sent(b.clock)
+	go handler()
+}
+
+// Report that the handler is done reacting to an event.
+func (b *base) handled() {
+	clock.Handled(b.clock)
+}
+
 // This only exists for testing, it should not be used by classes that compose
 // base.
-func newBaseCondition(s *status.Status) Condition {
-	c := newBase(s)
+func newBaseCondition(s *status.Status, clk clock.Clock) Condition {
+	c := newBase(s, clk)
 	go c.Handler()
 
 	return &c
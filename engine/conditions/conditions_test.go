@@ -2,6 +2,7 @@ package conditions
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/wait"
 	"gopkg.in/check.v1"
@@ -80,6 +81,10 @@ func (m *mockCondition) Result() <-chan bool {
 func (m *mockCondition) Stop() {
 }
 
+func (m *mockCondition) Explain() Explanation {
+	return Explanation{Type: "mock"}
+}
+
 //
 // Condition Parsing Tests
 //
@@ -95,7 +100,7 @@ func constructConditionJson(c *check.C, statusJson, condJson string) (cond Condi
 	e := body.SetJson("status://", []byte(condJson), 0)
 	c.Assert(e, check.IsNil)
 
-	return NewCondition(s, body)
+	return NewCondition(s, clock.NewReal(), body)
 }
 
 func validateConditionJson(c *check.C, statusJson, condJson string, expected bool) {
@@ -155,3 +160,9 @@ func (suite *MySuite) TestConditionStartStop(c *check.C) {
 //
 // Base condition tests.
 //
+
+// Wait for a condition using a fake clock to set its timers.
+func waitForTimers(c *check.C, clk *clock.Fake, expected int) {
+	wait.Wait(READ_DELAY, func() bool { return clk.PendingTimers() == expected })
+	c.Assert(clk.PendingTimers(), check.Equals, expected)
+}
@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 )
 
@@ -10,9 +11,9 @@ type constCondition struct {
 	result bool
 }
 
-func newConstCondition(s *status.Status, body *status.Status, result bool) (*constCondition, error) {
+func newConstCondition(s *status.Status, clk clock.Clock, body *status.Status, result bool) (*constCondition, error) {
 	// Create our condition.
-	c := &constCondition{newBase(s), result}
+	c := &constCondition{newBase(s, clk), result}
 
 	c.start()
 	return c, nil
@@ -20,10 +21,11 @@ func newConstCondition(s *status.Status, body *status.Status, result bool) (*con
 
 func (c *constCondition) start() {
 	// Start it's goroutine.
-	go c.Handler()
+	c.startHandler(c.Handler)
 }
 
 func (c *constCondition) Handler() {
 	c.sendResult(c.result)
+	c.handled()
 	c.base.Handler()
 }
@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
 )
@@ -9,7 +10,7 @@ func setupConstCondition(c *check.C, result bool) *constCondition {
 	s := &status.Status{}
 	body := &status.Status{}
 
-	cond, e := newConstCondition(s, body, result)
+	cond, e := newConstCondition(s, clock.NewReal(), body, result)
 	c.Assert(e, check.IsNil)
 
 	return cond
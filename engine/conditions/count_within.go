@@ -0,0 +1,129 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"time"
+)
+
+// Becomes true when an inner condition becomes true "count" times within a
+// sliding window. It then stays true for a cooldown (or pulses, if there is
+// none), ignoring the inner condition, and starts counting again from zero.
+type countWithinCondition struct {
+	base
+
+	condition Condition
+	count     int
+	window    time.Duration
+	cooldown  time.Duration
+}
+
+func newCountWithinCondition(s *status.Status, clk clock.Clock, body *status.Status) (*countWithinCondition, error) {
+	count, _, e := body.GetInt("status://count")
+	if e != nil || count < 1 {
+		return nil, fmt.Errorf("Count within condition: Invalid or missing 'count'.")
+	}
+
+	window, e := durationOption(body, "window", "Count within")
+	if e != nil {
+		return nil, e
+	}
+
+	var cooldown time.Duration
+	if _, _, e = body.Get("status://cooldown"); e == nil {
+		if cooldown, e = durationOption(body, "cooldown", "Count within"); e != nil {
+			return nil, e
+		}
+	}
+
+	condition, e := newInnerCondition(s, clk, body, "Count within")
+	if e != nil {
+		return nil, e
+	}
+
+	// Create our condition.
+	c := &countWithinCondition{newBase(s, clk), condition, count, window, cooldown}
+	c.setChildren(condition)
+
+	c.start()
+	return c, nil
+}
+
+func (c *countWithinCondition) start() {
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+}
+
+func (c *countWithinCondition) Stop() {
+	// Shut down inner condition before stopping ourselves. This means we
+	// can react to final result updates from them, and avoid deadlocks.
+	c.condition.Stop()
+	c.base.Stop()
+}
+
+func (c *countWithinCondition) Handler() {
+	// Create the timer with a long timeout, then stop it.
+	// We'll reset, when we're ready to really start it.
+	timer := c.clock.NewTimer(time.Hour)
+	timer.Stop()
+
+	// The times the inner condition became true, oldest first.
+	edges := []time.Time{}
+	coolingDown := false
+	initial := true
+
+	c.sendResult(false)
+
+	c.handled()
+
+	for {
+		select {
+		case condValue := <-c.condition.Result():
+			// The initial value isn't an event.
+			if initial {
+				initial = false
+				break
+			}
+
+			if !condValue || coolingDown {
+				break
+			}
+
+			now := c.clock.Now()
+			edges = append(edges, now)
+
+			// Forget edges that have left the window.
+			oldest := now.Add(-c.window)
+			for len(edges) > 0 && edges[0].Before(oldest) {
+				edges = edges[1:]
+			}
+
+			if len(edges) < c.count {
+				break
+			}
+
+			edges = []time.Time{}
+			c.sendResult(true)
+
+			if c.cooldown == 0 {
+				c.sendResult(false)
+			} else {
+				coolingDown = true
+				c.resetTimer(timer, c.cooldown)
+			}
+
+		case <-timer.C:
+			c.setNextChange(time.Time{})
+			coolingDown = false
+			c.sendResult(false)
+
+		case <-c.StopChan:
+			timer.Stop()
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
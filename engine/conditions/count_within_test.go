@@ -0,0 +1,96 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestCountWithinParsing(c *check.C) {
+	validateConditionJson(c, "{}",
+		`{"test": "count_within", "count": 3, "window": "10s", "condition": {"test": "true"}}`, false)
+	validateConditionJson(c, "{}",
+		`{"test": "count_within", "count": 3, "window": "10s", "cooldown": "1m", "condition": {"test": "true"}}`, false)
+
+	validateConditionBadJson(c, `{"test": "count_within", "window": "10s", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "count_within", "count": 0, "window": "10s", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "count_within", "count": 3, "condition": {"test": "true"}}`)
+	validateConditionBadJson(c,
+		`{"test": "count_within", "count": 3, "window": "10s", "cooldown": "x", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "count_within", "count": 3, "window": "10s"}`)
+}
+
+// Pulse a mock condition.
+func pulseMock(mockCond *mockCondition) {
+	mockCond.result <- true
+	mockCond.result <- false
+}
+
+func (suite *MySuite) TestCountWithinPulse(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+
+	window := 10 * EMPTY_DELAY
+	cond := &countWithinCondition{newBase(s, clock.NewReal()), mockCond, 3, window, 0}
+	cond.start()
+
+	validateChannelRead(c, cond, false)
+
+	// The initial value doesn't count.
+	mockCond.result <- true
+	mockCond.result <- false
+
+	pulseMock(mockCond)
+	pulseMock(mockCond)
+	validateChannelEmpty(c, cond)
+
+	pulseMock(mockCond)
+	validateChannelRead(c, cond, true)
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	// Counting starts over.
+	pulseMock(mockCond)
+	pulseMock(mockCond)
+	validateChannelEmpty(c, cond)
+
+	// Old pulses leave the window.
+	time.Sleep(window)
+	pulseMock(mockCond)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCountWithinCooldown(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+
+	cooldown := 4 * EMPTY_DELAY
+	cond := &countWithinCondition{newBase(s, clock.NewReal()), mockCond, 2, time.Minute, cooldown}
+	cond.start()
+
+	validateChannelRead(c, cond, false)
+	mockCond.result <- false
+
+	pulseMock(mockCond)
+	pulseMock(mockCond)
+	validateChannelRead(c, cond, true)
+
+	// Pulses during the cooldown are ignored.
+	pulseMock(mockCond)
+	pulseMock(mockCond)
+	validateChannelEmptyInstant(c, cond)
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	pulseMock(mockCond)
+	validateChannelEmpty(c, cond)
+
+	pulseMock(mockCond)
+	validateChannelRead(c, cond, true)
+
+	cond.Stop()
+}
@@ -1,9 +1,9 @@
 package conditions
 
 import (
-	// "fmt"
+	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
-	"github.com/cpucycle/astrotime"
 	"time"
 )
 
@@ -13,81 +13,80 @@ type daylightCondition struct {
 	latitude  float64
 	longitude float64
 	day       bool
+
+	rise   solarEvent    // Start of day.
+	set    solarEvent    // End of day.
+	offset time.Duration // Moves both transitions.
 }
 
-func newDaylightCondition(s *status.Status, body *status.Status, day bool) (*daylightCondition, error) {
+func newDaylightCondition(s *status.Status, clk clock.Clock, body *status.Status, day bool) (*daylightCondition, error) {
 	// Look up our Latitude and Longitude
 	latitude := s.GetFloatWithDefault("status://server/latitude", 0.0)
 	longitude := s.GetFloatWithDefault("status://server/longitude", 0.0)
 
-	c := &daylightCondition{newBase(s), latitude, longitude, day}
-
-	// Start it's goroutine.
-	go c.Handler()
-
-	return c, nil
-}
-
-// Less than 24 hours to help with daylight savings, and other weirdness.
-const DAY_INCREMENT = 13 * time.Hour
-
-func findNextSunrise(now time.Time, latitude, longitude float64) time.Time {
-	// Start in the past, because astrotime sometimes returns tomorrow's result.
-	calcNow := now.Add(-24 * time.Hour)
-
-	for {
-		next := astrotime.CalcSunrise(calcNow, latitude, longitude)
-		if next.After(now) {
-			return next
+	// Day normally runs from sunrise to sunset, but may be extended to include
+	// twilight.
+	zenith := ZENITH_OFFICIAL
+	if twilight, _, e := body.GetString("status://twilight"); e == nil {
+		var ok bool
+		if zenith, ok = twilightZeniths[twilight]; !ok {
+			return nil, fmt.Errorf("Daylight condition: Invalid 'twilight': %s", twilight)
 		}
+	}
 
-		calcNow = calcNow.Add(DAY_INCREMENT)
+	var offset time.Duration
+	if offsetStr, _, e := body.GetString("status://offset"); e == nil {
+		if offset, e = time.ParseDuration(offsetStr); e != nil {
+			return nil, fmt.Errorf("Daylight condition: %s", e.Error())
+		}
 	}
-}
 
-func findNextSunset(now time.Time, latitude, longitude float64) time.Time {
-	// Start in the past, because astrotime sometimes returns tomorrow's result.
-	calcNow := now.Add(-24 * time.Hour)
+	c := &daylightCondition{newBase(s, clk), latitude, longitude, day,
+		solarEvent{zenith, true}, solarEvent{zenith, false}, offset}
 
-	for {
-		next := astrotime.CalcSunset(calcNow, latitude, longitude)
-		if next.After(now) {
-			return next
-		}
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
 
-		calcNow = calcNow.Add(DAY_INCREMENT)
-	}
+	return c, nil
 }
 
+// Solar events are absolute times, so the server's timezone doesn't matter
+// here.
 func (c *daylightCondition) findIsDayAndNextChange(now time.Time) (isDay bool, changeTime time.Time) {
-	nextSunrise := findNextSunrise(now, c.latitude, c.longitude)
-	nextSunset := findNextSunset(now, c.latitude, c.longitude)
+	// Search from now without the offset, then move the results by it.
+	nextRise := findNextSolarEvent(now.Add(-c.offset), c.rise, c.latitude, c.longitude)
+	nextSet := findNextSolarEvent(now.Add(-c.offset), c.set, c.latitude, c.longitude)
+
+	if nextRise.IsZero() && nextSet.IsZero() {
+		// Neither happens here. Check again tomorrow.
+		return false, now.Add(24 * time.Hour)
+	}
 
-	if nextSunrise.Before(nextSunset) {
+	if !nextRise.IsZero() && (nextSet.IsZero() || nextRise.Before(nextSet)) {
 		// If the next transition is sunrise, it's night.
-		isDay, changeTime = false, nextSunrise
+		isDay, changeTime = false, nextRise
 	} else {
 		// if the next transition is sunset, it's day.
-		isDay, changeTime = true, nextSunset
+		isDay, changeTime = true, nextSet
 	}
 
-	// Adjust change time to work around time calculation float slop.
-	// changeTime = changeTime.Add(5 * time.Minute)
-	return isDay, changeTime
+	return isDay, changeTime.Add(c.offset)
 }
 
 func (c *daylightCondition) Handler() {
 
 	// Set the timer to fire immediately to send the initial state.
-	timer := time.NewTimer(0)
+	timer := c.clock.NewTimer(0)
+
+	c.handled()
 
 	for {
 		select {
 		case <-timer.C:
 			// Set timer for the next firing.
-			now := time.Now()
+			now := c.clock.Now()
 			isDay, nextTransition := c.findIsDayAndNextChange(now)
-			timer.Reset(nextTransition.Sub(now))
+			c.resetTimer(timer, nextTransition.Sub(now))
 
 			// Announce new state.
 			c.sendResult(isDay == c.day)
@@ -97,5 +96,7 @@ func (c *daylightCondition) Handler() {
 			c.StopChan <- true
 			return
 		}
+
+		c.handled()
 	}
 }
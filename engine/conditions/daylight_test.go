@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"github.com/cpucycle/astrotime"
 	"gopkg.in/check.v1"
@@ -23,7 +24,7 @@ func setupDaylightCondition(c *check.C, day bool) *daylightCondition {
 
 	body := &status.Status{}
 
-	cond, e := newDaylightCondition(s, body, day)
+	cond, e := newDaylightCondition(s, clock.NewReal(), body, day)
 	c.Assert(e, check.IsNil)
 
 	return cond
@@ -82,3 +83,65 @@ func (suite *MySuite) TestFindIsDayAndChangeDelay(c *check.C) {
 
 	cond.Stop()
 }
+
+func (suite *MySuite) TestDaylightOffsetAndTwilight(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/latitude", LATITUDE, 0), check.IsNil)
+	c.Assert(s.Set("status://server/longitude", LONGITUDE, 1), check.IsNil)
+
+	body := &status.Status{}
+	c.Assert(body.Set("status://offset", "-30m", 0), check.IsNil)
+	c.Assert(body.Set("status://twilight", "civil", 1), check.IsNil)
+
+	cond, e := newDaylightCondition(s, clock.NewReal(), body, false)
+	c.Assert(e, check.IsNil)
+
+	testDay := time.Date(2014, time.June, 13, 0, 0, 00, 0, time.UTC)
+	dusk := findNextSolarEvent(testDay, solarEvents["civil_dusk"], LATITUDE, LONGITUDE)
+	change := dusk.Add(-30 * time.Minute)
+
+	// Day, until 30 minutes before dusk.
+	isDay, transitionTime := cond.findIsDayAndNextChange(change.Add(-time.Minute))
+	c.Check(isDay, check.Equals, true)
+	c.Check(transitionTime, check.Equals, change)
+
+	// Night, after that.
+	isDay, transitionTime = cond.findIsDayAndNextChange(change.Add(time.Minute))
+	c.Check(isDay, check.Equals, false)
+	dawn := findNextSolarEvent(dusk, solarEvents["civil_dawn"], LATITUDE, LONGITUDE)
+	c.Check(transitionTime, check.Equals, dawn.Add(-30*time.Minute))
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestDaylightBadOptions(c *check.C) {
+	validateConditionBadJson(c, `{"test": "day", "twilight": "dim"}`)
+	validateConditionBadJson(c, `{"test": "night", "offset": "soon"}`)
+}
+
+func (suite *MySuite) TestDaylightFakeClockWeek(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/latitude", LATITUDE, 0), check.IsNil)
+	c.Assert(s.Set("status://server/longitude", LONGITUDE, 1), check.IsNil)
+
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 0, 0, 0, 0, time.UTC))
+
+	cond, e := newDaylightCondition(s, clk, &status.Status{}, true)
+	c.Assert(e, check.IsNil)
+
+	isDay, next := cond.findIsDayAndNextChange(clk.Now())
+	validateChannelRead(c, cond, isDay)
+
+	// Two transitions a day.
+	for i := 0; i < 14; i++ {
+		waitForTimers(c, clk, 1)
+
+		clk.Set(next)
+		isDay = !isDay
+		validateChannelRead(c, cond, isDay)
+
+		_, next = cond.findIsDayAndNextChange(clk.Now())
+	}
+
+	cond.Stop()
+}
@@ -0,0 +1,118 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"time"
+)
+
+// Follows an inner condition, but only once it has been stable for a delay.
+// Changes that are undone before the delay passes are ignored.
+type debounceCondition struct {
+	base
+
+	condition Condition
+	onDelay   time.Duration // How long the inner condition must be true.
+	offDelay  time.Duration // How long the inner condition must be false.
+}
+
+func newDebounceCondition(s *status.Status, clk clock.Clock, body *status.Status) (*debounceCondition, error) {
+	// "delay" is the default for both directions.
+	var onDelay, offDelay time.Duration
+	var e error
+
+	if _, _, e = body.Get("status://delay"); e == nil {
+		if onDelay, e = durationOption(body, "delay", "Debounce"); e != nil {
+			return nil, e
+		}
+		offDelay = onDelay
+	}
+
+	if _, _, e = body.Get("status://on_delay"); e == nil {
+		if onDelay, e = durationOption(body, "on_delay", "Debounce"); e != nil {
+			return nil, e
+		}
+	}
+
+	if _, _, e = body.Get("status://off_delay"); e == nil {
+		if offDelay, e = durationOption(body, "off_delay", "Debounce"); e != nil {
+			return nil, e
+		}
+	}
+
+	if onDelay == 0 && offDelay == 0 {
+		return nil, fmt.Errorf("Debounce condition: No 'delay'.")
+	}
+
+	condition, e := newInnerCondition(s, clk, body, "Debounce")
+	if e != nil {
+		return nil, e
+	}
+
+	// Create our condition.
+	c := &debounceCondition{newBase(s, clk), condition, onDelay, offDelay}
+	c.setChildren(condition)
+
+	c.start()
+	return c, nil
+}
+
+func (c *debounceCondition) start() {
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+}
+
+func (c *debounceCondition) Stop() {
+	// Shut down inner condition before stopping ourselves. This means we
+	// can react to final result updates from them, and avoid deadlocks.
+	c.condition.Stop()
+	c.base.Stop()
+}
+
+func (c *debounceCondition) Handler() {
+	// Create the timer with a long timeout, then stop it.
+	// We'll reset, when we're ready to really start it.
+	timer := c.clock.NewTimer(time.Hour)
+	timer.Stop()
+
+	var pending bool
+
+	c.handled()
+
+	for {
+		select {
+		case condValue := <-c.condition.Result():
+			// The initial value is passed along right away.
+			if !c.initialSent {
+				c.sendResult(condValue)
+				break
+			}
+
+			pending = condValue
+
+			// If the inner condition returns to our value, cancel the change.
+			if condValue == c.lastSent {
+				c.stopTimer(timer)
+				break
+			}
+
+			delay := c.offDelay
+			if condValue {
+				delay = c.onDelay
+			}
+			c.resetTimer(timer, delay)
+
+		case <-timer.C:
+			c.setNextChange(time.Time{})
+			c.sendResult(pending)
+
+		case <-c.StopChan:
+			timer.Stop()
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
@@ -0,0 +1,72 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestDebounceParsing(c *check.C) {
+	validateConditionJson(c, "{}", `{"test": "debounce", "delay": "1s", "condition": {"test": "true"}}`, true)
+	validateConditionJson(c, "{}", `{"test": "debounce", "off_delay": "1s", "condition": {"test": "false"}}`, false)
+
+	validateConditionBadJson(c, `{"test": "debounce", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "debounce", "delay": "bogus", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "debounce", "delay": "-1s", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "debounce", "delay": "1s"}`)
+	validateConditionBadJson(c, `{"test": "debounce", "delay": "1s", "condition": {"test": "bogus"}}`)
+}
+
+func (suite *MySuite) TestDebounceMock(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+
+	cond := &debounceCondition{newBase(s, clock.NewReal()), mockCond, 2 * EMPTY_DELAY, 4 * EMPTY_DELAY}
+	cond.start()
+
+	// The initial value is sent right away.
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	// Flapping is ignored.
+	mockCond.result <- true
+	mockCond.result <- false
+	validateChannelEmpty(c, cond)
+
+	// Stable changes are sent, after the delay.
+	mockCond.result <- true
+	validateChannelEmptyInstant(c, cond)
+	validateChannelRead(c, cond, true)
+	validateChannelEmpty(c, cond)
+
+	// Flapping false is ignored too.
+	mockCond.result <- false
+	mockCond.result <- true
+	time.Sleep(cond.offDelay)
+	validateChannelEmpty(c, cond)
+
+	mockCond.result <- false
+	validateChannelEmptyInstant(c, cond)
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
+// Stopping cancels a pending change.
+func (suite *MySuite) TestDebounceStopPending(c *check.C) {
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC))
+	mockCond := &mockCondition{make(chan bool)}
+
+	cond := &debounceCondition{newBase(&status.Status{}, clk), mockCond, time.Minute, time.Minute}
+	cond.start()
+
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+
+	mockCond.result <- true
+	cond.Stop()
+	c.Check(clk.PendingTimers(), check.Equals, 0)
+}
@@ -0,0 +1,116 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"sync"
+	"time"
+)
+
+// A description of a condition's current state, and that of it's inner
+// conditions, for debugging.
+type Explanation struct {
+	Type       string                 `json:"type"`
+	Config     map[string]interface{} `json:"config,omitempty"` // Inner conditions are in Children.
+	Result     *bool                  `json:"result"`           // nil until the first result is sent.
+	LastChange *time.Time             `json:"last_change,omitempty"`
+	NextChange *time.Time             `json:"next_change,omitempty"` // When a timer will next check.
+	Children   []Explanation          `json:"children,omitempty"`
+}
+
+// Implemented by every condition, through base.
+type describer interface {
+	describe(kind string, config map[string]interface{})
+}
+
+// The parts of base which Explain may read from other goroutines.
+type explainState struct {
+	lock       sync.Mutex
+	kind       string
+	config     map[string]interface{}
+	result     *bool
+	lastChange time.Time
+	nextChange time.Time
+	children   []Condition
+}
+
+// Record the type and config a condition was created from. Inner conditions
+// are left out of the config, since they are explained as children.
+func (b *base) describe(kind string, config map[string]interface{}) {
+	trimmed := map[string]interface{}{}
+	for k, v := range config {
+		if k != "test" && k != "condition" && k != "conditions" {
+			trimmed[k] = v
+		}
+	}
+
+	b.explain.lock.Lock()
+	defer b.explain.lock.Unlock()
+
+	b.explain.kind = kind
+	b.explain.config = trimmed
+}
+
+// Record the inner conditions of a condition which wraps others.
+func (b *base) setChildren(children ...Condition) {
+	b.explain.lock.Lock()
+	defer b.explain.lock.Unlock()
+
+	b.explain.children = children
+}
+
+// Record when a timer will next check our result. The zero time means no
+// timer is pending.
+func (b *base) setNextChange(next time.Time) {
+	b.explain.lock.Lock()
+	defer b.explain.lock.Unlock()
+
+	b.explain.nextChange = next
+}
+
+// Reset a timer, and record when it will fire.
+func (b *base) resetTimer(timer *clock.Timer, d time.Duration) {
+	timer.Reset(d)
+	b.setNextChange(b.clock.Now().Add(d))
+}
+
+// Stop a timer, and record that it won't fire.
+func (b *base) stopTimer(timer *clock.Timer) {
+	timer.Stop()
+	b.setNextChange(time.Time{})
+}
+
+func (b *base) recordResult(result bool) {
+	b.explain.lock.Lock()
+	defer b.explain.lock.Unlock()
+
+	b.explain.result = &result
+	b.explain.lastChange = b.clock.Now()
+}
+
+func (b *base) Explain() Explanation {
+	b.explain.lock.Lock()
+
+	result := Explanation{Type: b.explain.kind, Config: b.explain.config}
+	if b.explain.result != nil {
+		value := *b.explain.result
+		result.Result = &value
+	}
+	if !b.explain.lastChange.IsZero() {
+		lastChange := b.explain.lastChange
+		result.LastChange = &lastChange
+	}
+	if !b.explain.nextChange.IsZero() {
+		nextChange := b.explain.nextChange
+		result.NextChange = &nextChange
+	}
+	children := b.explain.children
+
+	b.explain.lock.Unlock()
+
+	// Children have their own locks.
+	for _, child := range children {
+		result.Children = append(result.Children, child.Explain())
+	}
+
+	return result
+}
@@ -0,0 +1,70 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestExplain(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://motion", false, 0), check.IsNil)
+
+	start := time.Date(2014, time.June, 12, 0, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	body := &status.Status{}
+	e := body.SetJson("status://", []byte(`
+		{
+			"test": "after",
+			"delay": "1h",
+			"condition": {
+				"test": "compare",
+				"watch": "status://motion",
+				"op": "==",
+				"value": true
+			}
+		}`), 0)
+	c.Assert(e, check.IsNil)
+
+	cond, e := NewCondition(s, clk, body)
+	c.Assert(e, check.IsNil)
+
+	validateChannelRead(c, cond, false)
+
+	explanation := cond.Explain()
+	c.Check(explanation.Type, check.Equals, "after")
+	c.Check(explanation.Config, check.DeepEquals, map[string]interface{}{"delay": "1h"})
+	c.Assert(explanation.Result, check.NotNil)
+	c.Check(*explanation.Result, check.Equals, false)
+	c.Check(*explanation.LastChange, check.Equals, start)
+	c.Check(explanation.NextChange, check.IsNil)
+
+	c.Assert(explanation.Children, check.HasLen, 1)
+	child := explanation.Children[0]
+	c.Check(child.Type, check.Equals, "compare")
+	c.Check(child.Config, check.DeepEquals, map[string]interface{}{
+		"watch": "status://motion", "op": "==", "value": true})
+	c.Check(*child.Result, check.Equals, false)
+
+	// The inner condition passes, which starts the delay.
+	c.Assert(s.Set("status://motion", true, status.UNCHECKED_REVISION), check.IsNil)
+	waitForTimers(c, clk, 1)
+
+	explanation = cond.Explain()
+	c.Check(*explanation.Result, check.Equals, false)
+	c.Check(*explanation.Children[0].Result, check.Equals, true)
+	c.Assert(explanation.NextChange, check.NotNil)
+	c.Check(*explanation.NextChange, check.Equals, start.Add(time.Hour))
+
+	clk.Advance(time.Hour)
+	validateChannelRead(c, cond, true)
+
+	explanation = cond.Explain()
+	c.Check(*explanation.Result, check.Equals, true)
+	c.Check(*explanation.LastChange, check.Equals, start.Add(time.Hour))
+	c.Check(explanation.NextChange, check.IsNil)
+
+	cond.Stop()
+}
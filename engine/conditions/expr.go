@@ -0,0 +1,119 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/expr"
+	"github.com/DonGar/go-house/status"
+	"log"
+	"reflect"
+	"time"
+)
+
+type exprCondition struct {
+	base
+
+	expr     *expr.Expr
+	interval time.Duration // How often to recheck, if the result depends on the time.
+	watches  []<-chan status.UrlMatches
+}
+
+func newExprCondition(s *status.Status, clk clock.Clock, body *status.Status) (*exprCondition, error) {
+	source, _, e := body.GetString("status://expr")
+	if e != nil {
+		return nil, fmt.Errorf("Expr condition: No 'expr'.")
+	}
+
+	x, e := expr.Parse(source)
+	if e != nil {
+		return nil, fmt.Errorf("Expr condition: %s", e.Error())
+	}
+
+	interval := time.Minute
+	if _, _, e := body.Get("status://interval"); e == nil {
+		if interval, e = durationOption(body, "interval", "Expr"); e != nil {
+			return nil, e
+		}
+	}
+
+	c := &exprCondition{newBase(s, clk), x, interval, nil}
+
+	// Watch every value the expression reads.
+	for _, url := range x.Urls() {
+		watch, e := s.WatchForUpdate(url)
+		if e != nil {
+			c.releaseWatches()
+			return nil, fmt.Errorf("Expr condition: %s", e.Error())
+		}
+		c.watches = append(c.watches, watch)
+	}
+
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+
+	return c, nil
+}
+
+func (c *exprCondition) releaseWatches() {
+	for _, watch := range c.watches {
+		c.status.ReleaseWatch(watch)
+	}
+}
+
+// Evaluate the expression. Errors (like comparing a missing value) are
+// logged, and treated as false.
+func (c *exprCondition) update() {
+	result, e := c.expr.EvalBool(expr.Env{Status: c.status, Clock: c.clock})
+	if e != nil {
+		log.Printf("Expr condition: %s: %s", c.expr, e.Error())
+	}
+
+	c.sendResult(result)
+}
+
+func (c *exprCondition) Handler() {
+	// The number of watches isn't fixed, so select with reflect.
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.StopChan)}}
+
+	// Recheck on the interval (aligned to it, so "1m" checks on the minute), if
+	// the result depends on the time.
+	var timer *clock.Timer
+	if c.expr.UsesClock() {
+		timer = c.clock.NewTimer(0)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(timer.C)})
+	}
+
+	for _, watch := range c.watches {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(watch)})
+	}
+
+	// Send the initial result. Each watch has an initial update queued too,
+	// but repeated results aren't resent.
+	c.update()
+
+	c.handled()
+
+	for {
+		chosen, _, _ := reflect.Select(cases)
+
+		switch {
+		case chosen == 0:
+			if timer != nil {
+				c.stopTimer(timer)
+			}
+			c.releaseWatches()
+			c.StopChan <- true
+			return
+
+		case timer != nil && chosen == 1:
+			now := c.clock.Now()
+			c.resetTimer(timer, now.Truncate(c.interval).Add(c.interval).Sub(now))
+			c.update()
+
+		default:
+			c.update()
+		}
+
+		c.handled()
+	}
+}
@@ -0,0 +1,92 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestExprStartStop(c *check.C) {
+	good := []string{
+		`{"test": "expr", "expr": "a.b > 1"}`,
+		`{"test": "expr", "expr": "now() > time(\"20:00\")", "interval": "5m"}`,
+	}
+
+	for _, g := range good {
+		validateConditionJson(c, `{"a": {"b": 0}}`, g, false)
+	}
+
+	bad := []string{
+		`{"test": "expr"}`,
+		`{"test": "expr", "expr": "a.b >"}`,
+		`{"test": "expr", "expr": "bogus()"}`,
+		`{"test": "expr", "expr": "true", "interval": "0s"}`,
+	}
+
+	for _, b := range bad {
+		validateConditionBadJson(c, b)
+	}
+}
+
+func (suite *MySuite) TestExprWatches(c *check.C) {
+	s := &status.Status{}
+	e := s.SetJson("status://", []byte(`{"kitchen": {"temperature": 70}, "home": {"away": false}}`), 0)
+	c.Assert(e, check.IsNil)
+
+	body := &status.Status{}
+	e = body.SetJson("status://", []byte(`{"test": "expr", "expr": "kitchen.temperature > 75 && !home.away"}`), 0)
+	c.Assert(e, check.IsNil)
+
+	cond, e := NewCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	c.Assert(s.Set("status://kitchen/temperature", 80, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelRead(c, cond, true)
+
+	c.Assert(s.Set("status://home/away", true, status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelRead(c, cond, false)
+
+	// Missing values are null, which is false.
+	c.Assert(s.Remove("status://home/away", status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelRead(c, cond, true)
+
+	// Errors are false.
+	c.Assert(s.Set("status://kitchen/temperature", "hot", status.UNCHECKED_REVISION), check.IsNil)
+	validateChannelRead(c, cond, false)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestExprFakeClock(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/timezone", "UTC", 0), check.IsNil)
+
+	start := time.Date(2014, time.June, 12, 19, 58, 30, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	body := &status.Status{}
+	e := body.SetJson("status://", []byte(`{"test": "expr", "expr": "now() >= time(\"20:00\")"}`), 0)
+	c.Assert(e, check.IsNil)
+
+	cond, e := NewCondition(s, clk, body)
+	c.Assert(e, check.IsNil)
+
+	validateChannelRead(c, cond, false)
+
+	// Rechecked on the minute.
+	waitForTimers(c, clk, 1)
+	clk.Advance(30 * time.Second)
+	validateChannelEmpty(c, cond)
+
+	c.Check(*cond.Explain().NextChange, check.Equals, start.Add(90*time.Second))
+
+	waitForTimers(c, clk, 1)
+	clk.Advance(time.Minute)
+	validateChannelRead(c, cond, true)
+
+	cond.Stop()
+}
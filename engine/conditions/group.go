@@ -0,0 +1,113 @@
+package conditions
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+)
+
+// A condition whose result depends on how many of its subconditions are true.
+type groupCondition struct {
+	base
+	conditions []conditionValue
+	combine    func(trueCount, total int) bool
+}
+
+// True if any subcondition is true.
+func newOrCondition(s *status.Status, clk clock.Clock, body *status.Status) (*groupCondition, error) {
+	return newGroupCondition(s, clk, body, "Or", func(trueCount, total int) bool {
+		return trueCount > 0
+	})
+}
+
+// True if an odd number of subconditions are true. For two subconditions,
+// that means exactly one.
+func newXorCondition(s *status.Status, clk clock.Clock, body *status.Status) (*groupCondition, error) {
+	return newGroupCondition(s, clk, body, "Xor", func(trueCount, total int) bool {
+		return trueCount%2 == 1
+	})
+}
+
+// True if at least "min" subconditions are true.
+func newCountCondition(s *status.Status, clk clock.Clock, body *status.Status) (*groupCondition, error) {
+	min, _, e := body.GetInt("status://min")
+	if e != nil {
+		return nil, fmt.Errorf("Count condition: No 'min': %s", e.Error())
+	}
+
+	if min < 0 {
+		return nil, fmt.Errorf("Count condition: Invalid 'min': %d", min)
+	}
+
+	return newGroupCondition(s, clk, body, "Count", func(trueCount, total int) bool {
+		return trueCount >= min
+	})
+}
+
+// True if the single subcondition "condition" is false.
+func newNotCondition(s *status.Status, clk clock.Clock, body *status.Status) (*groupCondition, error) {
+	condition, e := newInnerCondition(s, clk, body, "Not")
+	if e != nil {
+		return nil, e
+	}
+
+	combine := func(trueCount, total int) bool {
+		return trueCount == 0
+	}
+
+	c := &groupCondition{newBase(s, clk), []conditionValue{{condition, false}}, combine}
+	c.setChildren(condition)
+
+	c.start()
+	return c, nil
+}
+
+// Create a group condition from the "conditions" list. kind names the
+// condition, for errors.
+func newGroupCondition(
+	s *status.Status,
+	clk clock.Clock,
+	body *status.Status,
+	kind string,
+	combine func(trueCount, total int) bool) (*groupCondition, error) {
+
+	valuesRaw, _, e := body.Get("status://conditions")
+	if e != nil {
+		return nil, e
+	}
+	conditionValues, e := parseConditionValues(s, clk, kind, valuesRaw)
+	if e != nil {
+		return nil, e
+	}
+
+	c := &groupCondition{newBase(s, clk), conditionValues, combine}
+	c.setChildren(childConditions(conditionValues)...)
+
+	c.start()
+	return c, nil
+}
+
+func (c *groupCondition) start() {
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+}
+
+func (c *groupCondition) Stop() {
+	c.stopConditions(c.conditions)
+}
+
+func (c *groupCondition) currentValue() bool {
+	trueCount := 0
+
+	for _, condValue := range c.conditions {
+		if condValue.result {
+			trueCount += 1
+		}
+	}
+
+	return c.combine(trueCount, len(c.conditions))
+}
+
+func (c *groupCondition) Handler() {
+	c.handleConditions(c.conditions, c.currentValue)
+}
@@ -0,0 +1,165 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+)
+
+func (suite *MySuite) TestGroupStartStop(c *check.C) {
+	statusJson := `{
+		"true": { "test": "true" },
+		"false": { "test": "false" }
+	}`
+
+	validateConditionJson(c, statusJson, `{"test": "or", "conditions": []}`, false)
+	validateConditionJson(c, statusJson, `{"test": "or", "conditions": ["status://false", "status://true"]}`, true)
+	validateConditionJson(c, statusJson, `{"test": "or", "conditions": ["status://false", "status://false"]}`, false)
+
+	validateConditionJson(c, statusJson, `{"test": "not", "condition": "status://true"}`, false)
+	validateConditionJson(c, statusJson, `{"test": "not", "condition": "status://false"}`, true)
+	validateConditionJson(c, statusJson, `{"test": "not", "condition": ["status://true", "status://false"]}`, true)
+
+	validateConditionJson(c, statusJson, `{"test": "xor", "conditions": ["status://true", "status://false"]}`, true)
+	validateConditionJson(c, statusJson, `{"test": "xor", "conditions": ["status://true", "status://true"]}`, false)
+	validateConditionJson(c, statusJson, `{"test": "xor", "conditions": ["status://false", "status://false"]}`, false)
+
+	validateConditionJson(c, statusJson,
+		`{"test": "count", "min": 2, "conditions": ["status://true", "status://false", "status://true"]}`, true)
+	validateConditionJson(c, statusJson,
+		`{"test": "count", "min": 3, "conditions": ["status://true", "status://false", "status://true"]}`, false)
+	validateConditionJson(c, statusJson, `{"test": "count", "min": 0, "conditions": []}`, true)
+
+	// Nested.
+	validateConditionJson(c, statusJson,
+		`{"test": "or", "conditions": [{"test": "not", "condition": "status://true"}, ["status://true"]]}`, true)
+}
+
+func (suite *MySuite) TestGroupParsingBad(c *check.C) {
+	validateConditionBadJson(c, `{"test": "or"}`)
+	validateConditionBadJson(c, `{"test": "or", "conditions": "foo"}`)
+	validateConditionBadJson(c, `{"test": "or", "conditions": [{"test": "bogus"}]}`)
+	validateConditionBadJson(c, `{"test": "xor", "conditions": {}}`)
+	validateConditionBadJson(c, `{"test": "not"}`)
+	validateConditionBadJson(c, `{"test": "not", "condition": {"test": "bogus"}}`)
+	validateConditionBadJson(c, `{"test": "count", "conditions": []}`)
+	validateConditionBadJson(c, `{"test": "count", "min": "two", "conditions": []}`)
+	validateConditionBadJson(c, `{"test": "count", "min": -1, "conditions": []}`)
+}
+
+func (suite *MySuite) TestNotParsingBadErrors(c *check.C) {
+	body := &status.Status{}
+	e := body.SetJson("status://", []byte(`{"test": "not"}`), 0)
+	c.Assert(e, check.IsNil)
+
+	_, e = NewCondition(&status.Status{}, clock.NewReal(), body)
+	c.Check(e, check.ErrorMatches, "Not condition: No 'condition'.")
+}
+
+func setupGroupMock(
+	s *status.Status,
+	count int,
+	combine func(trueCount, total int) bool) (*groupCondition, []*mockCondition) {
+
+	mockCond := make([]*mockCondition, count)
+	conditionValues := make([]conditionValue, count)
+	for i := range mockCond {
+		mockCond[i] = &mockCondition{make(chan bool)}
+		conditionValues[i] = conditionValue{mockCond[i], false}
+	}
+
+	cond := &groupCondition{newBase(s, clock.NewReal()), conditionValues, combine}
+	cond.start()
+
+	return cond, mockCond
+}
+
+func (suite *MySuite) TestOrMock(c *check.C) {
+	s := &status.Status{}
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(`{"test": "or", "conditions": []}`), 0), check.IsNil)
+
+	// Borrow the combine function from a real condition.
+	or, e := newOrCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+	validateChannelRead(c, or, false)
+	or.Stop()
+
+	cond, mockCond := setupGroupMock(s, 2, or.combine)
+
+	mockCond[0].result <- false
+	mockCond[1].result <- false
+	validateChannelRead(c, cond, false)
+
+	mockCond[1].result <- true
+	validateChannelRead(c, cond, true)
+	validateChannelEmpty(c, cond)
+
+	mockCond[0].result <- true
+	validateChannelEmpty(c, cond)
+
+	mockCond[1].result <- false
+	validateChannelEmpty(c, cond)
+
+	mockCond[0].result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestCountMock(c *check.C) {
+	s := &status.Status{}
+	atLeastTwo := func(trueCount, total int) bool { return trueCount >= 2 }
+
+	cond, mockCond := setupGroupMock(s, 3, atLeastTwo)
+
+	mockCond[0].result <- true
+	mockCond[1].result <- false
+	mockCond[2].result <- false
+	validateChannelRead(c, cond, false)
+
+	mockCond[2].result <- true
+	validateChannelRead(c, cond, true)
+
+	mockCond[1].result <- true
+	validateChannelEmpty(c, cond)
+
+	mockCond[0].result <- false
+	validateChannelEmpty(c, cond)
+
+	mockCond[2].result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
+func (suite *MySuite) TestXorMock(c *check.C) {
+	s := &status.Status{}
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(`{"test": "xor", "conditions": []}`), 0), check.IsNil)
+
+	xor, e := newXorCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+	validateChannelRead(c, xor, false)
+	xor.Stop()
+
+	cond, mockCond := setupGroupMock(s, 2, xor.combine)
+
+	mockCond[0].result <- false
+	mockCond[1].result <- false
+	validateChannelRead(c, cond, false)
+
+	mockCond[0].result <- true
+	validateChannelRead(c, cond, true)
+
+	mockCond[1].result <- true
+	validateChannelRead(c, cond, false)
+
+	mockCond[0].result <- false
+	validateChannelRead(c, cond, true)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
@@ -0,0 +1,89 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"time"
+)
+
+// Follows an inner condition, but once true, stays true for at least a
+// minimum duration.
+type holdCondition struct {
+	base
+
+	condition Condition
+	duration  time.Duration
+}
+
+func newHoldCondition(s *status.Status, clk clock.Clock, body *status.Status) (*holdCondition, error) {
+	duration, e := durationOption(body, "duration", "Hold")
+	if e != nil {
+		return nil, e
+	}
+
+	condition, e := newInnerCondition(s, clk, body, "Hold")
+	if e != nil {
+		return nil, e
+	}
+
+	// Create our condition.
+	c := &holdCondition{newBase(s, clk), condition, duration}
+	c.setChildren(condition)
+
+	c.start()
+	return c, nil
+}
+
+func (c *holdCondition) start() {
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+}
+
+func (c *holdCondition) Stop() {
+	// Shut down inner condition before stopping ourselves. This means we
+	// can react to final result updates from them, and avoid deadlocks.
+	c.condition.Stop()
+	c.base.Stop()
+}
+
+func (c *holdCondition) Handler() {
+	// Create the timer with a long timeout, then stop it.
+	// We'll reset, when we're ready to really start it.
+	timer := c.clock.NewTimer(time.Hour)
+	timer.Stop()
+
+	var inner, holding bool
+
+	c.handled()
+
+	for {
+		select {
+		case condValue := <-c.condition.Result():
+			inner = condValue
+
+			switch {
+			case inner && !(c.initialSent && c.lastSent):
+				// Becoming true starts the hold.
+				c.sendResult(true)
+				c.resetTimer(timer, c.duration)
+				holding = true
+			case !inner && !holding:
+				c.sendResult(false)
+			}
+
+		case <-timer.C:
+			c.setNextChange(time.Time{})
+			holding = false
+			if !inner {
+				c.sendResult(false)
+			}
+
+		case <-c.StopChan:
+			timer.Stop()
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
@@ -0,0 +1,64 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestHoldParsing(c *check.C) {
+	validateConditionJson(c, "{}", `{"test": "hold", "duration": "1m", "condition": {"test": "true"}}`, true)
+	validateConditionJson(c, "{}", `{"test": "hold", "duration": "1m", "condition": {"test": "false"}}`, false)
+
+	validateConditionBadJson(c, `{"test": "hold", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "hold", "duration": "1m"}`)
+}
+
+func (suite *MySuite) TestHoldMock(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+
+	cond := &holdCondition{newBase(s, clock.NewReal()), mockCond, 4 * EMPTY_DELAY}
+	cond.start()
+
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	// A short pulse is held for the duration.
+	mockCond.result <- true
+	mockCond.result <- false
+	validateChannelRead(c, cond, true)
+	validateChannelEmptyInstant(c, cond)
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	// If the inner condition is still true at the end, we stay true.
+	mockCond.result <- true
+	validateChannelRead(c, cond, true)
+	time.Sleep(cond.duration)
+	validateChannelEmpty(c, cond)
+
+	// After the hold, false is passed along right away.
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
+// Stopping cancels a pending hold.
+func (suite *MySuite) TestHoldStopPending(c *check.C) {
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC))
+	mockCond := &mockCondition{make(chan bool)}
+
+	cond := &holdCondition{newBase(&status.Status{}, clk), mockCond, time.Minute}
+	cond.start()
+
+	mockCond.result <- true
+	validateChannelRead(c, cond, true)
+
+	cond.Stop()
+	c.Check(clk.PendingTimers(), check.Equals, 0)
+}
@@ -0,0 +1,329 @@
+package conditions
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A single VEVENT from an iCalendar file. Only simple recurrences are
+// supported: FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT and
+// UNTIL, plus EXDATE.
+type icsEvent struct {
+	summary string
+	start   time.Time
+
+	// Length of each occurrence. Days are added as calendar days, so all day
+	// events follow daylight savings changes.
+	days     int
+	duration time.Duration
+
+	freq     string // "" if the event doesn't repeat.
+	interval int
+	count    int       // 0 for no limit.
+	until    time.Time // Zero for no limit.
+	exdates  []time.Time
+}
+
+// Parse the events in an iCalendar file. Floating times, and times in unknown
+// timezones, use loc. Events which repeat in ways we don't support are logged
+// and skipped.
+func parseIcs(r io.Reader, loc *time.Location) (events []*icsEvent, e error) {
+	lines, e := unfoldIcsLines(r)
+	if e != nil {
+		return nil, e
+	}
+
+	var event *icsEvent
+	var end time.Time
+	var hasEnd bool
+	var allDay bool
+	var ruleErr error
+
+	for _, line := range lines {
+		name, params, value := splitIcsLine(line)
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &icsEvent{interval: 1}
+			end, hasEnd, allDay, ruleErr = time.Time{}, false, false, nil
+
+		case event == nil:
+			// Outside of an event.
+
+		case name == "END" && value == "VEVENT":
+			if event.start.IsZero() {
+				return nil, fmt.Errorf("Calendar: Event %q has no DTSTART", event.summary)
+			}
+			if !end.IsZero() {
+				event.days, event.duration = splitIcsLength(event.start, end)
+			}
+			if !hasEnd && allDay {
+				event.days = 1
+			}
+			if ruleErr != nil {
+				log.Printf("Calendar: Skipping event %q: %s", event.summary, ruleErr)
+			} else {
+				events = append(events, event)
+			}
+			event = nil
+
+		case name == "SUMMARY":
+			event.summary = unescapeIcsText(value)
+
+		case name == "DTSTART":
+			if event.start, allDay, e = parseIcsTime(params, value, loc); e != nil {
+				return nil, e
+			}
+
+		case name == "DTEND":
+			if end, _, e = parseIcsTime(params, value, loc); e != nil {
+				return nil, e
+			}
+			hasEnd = true
+
+		case name == "DURATION":
+			if event.days, event.duration, e = parseIcsDuration(value); e != nil {
+				return nil, e
+			}
+			hasEnd = true
+
+		case name == "RRULE":
+			ruleErr = parseIcsRule(event, value, loc)
+
+		case name == "EXDATE":
+			for _, v := range strings.Split(value, ",") {
+				exdate, _, e := parseIcsTime(params, v, loc)
+				if e != nil {
+					return nil, e
+				}
+				event.exdates = append(event.exdates, exdate)
+			}
+		}
+	}
+
+	return events, nil
+}
+
+// Read all lines, joining folded lines back together.
+func unfoldIcsLines(r io.Reader) (lines []string, e error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines, scanner.Err()
+}
+
+// Split "NAME;PARAM=X;PARAM=Y:VALUE".
+func splitIcsLine(line string) (name string, params map[string]string, value string) {
+	colon := strings.Index(line, ":")
+	if colon == -1 {
+		return strings.ToUpper(line), nil, ""
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params = map[string]string{}
+	for _, param := range parts[1:] {
+		if eq := strings.Index(param, "="); eq != -1 {
+			params[strings.ToUpper(param[:eq])] = strings.Trim(param[eq+1:], `"`)
+		}
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:]
+}
+
+func unescapeIcsText(value string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(value)
+}
+
+// Parse a DATE or DATE-TIME value.
+func parseIcsTime(params map[string]string, value string, loc *time.Location) (t time.Time, allDay bool, e error) {
+	if tzid, ok := params["TZID"]; ok {
+		if tz, e := time.LoadLocation(tzid); e == nil {
+			loc = tz
+		}
+	}
+
+	switch {
+	case params["VALUE"] == "DATE" || len(value) == 8:
+		t, e = time.ParseInLocation("20060102", value, loc)
+		allDay = true
+	case strings.HasSuffix(value, "Z"):
+		t, e = time.Parse("20060102T150405Z", value)
+	default:
+		t, e = time.ParseInLocation("20060102T150405", value, loc)
+	}
+
+	if e != nil {
+		return t, false, fmt.Errorf("Calendar: Invalid time: %s", value)
+	}
+
+	return t, allDay, nil
+}
+
+// Express the time between start and end as calendar days, plus a duration.
+func splitIcsLength(start, end time.Time) (days int, duration time.Duration) {
+	for !start.AddDate(0, 0, days+1).After(end) {
+		days += 1
+	}
+	return days, end.Sub(start.AddDate(0, 0, days))
+}
+
+var icsDuration = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// Parse a DURATION value, like "P1D" or "PT1H30M".
+func parseIcsDuration(value string) (days int, duration time.Duration, e error) {
+	parts := icsDuration.FindStringSubmatch(value)
+	if parts == nil || parts[1] == "-" {
+		return 0, 0, fmt.Errorf("Calendar: Invalid duration: %s", value)
+	}
+
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+
+	days = 7*number(parts[2]) + number(parts[3])
+	duration = time.Duration(number(parts[4]))*time.Hour +
+		time.Duration(number(parts[5]))*time.Minute +
+		time.Duration(number(parts[6]))*time.Second
+
+	return days, duration, nil
+}
+
+// Parse an RRULE value, like "FREQ=YEARLY;COUNT=10".
+func parseIcsRule(event *icsEvent, value string, loc *time.Location) (e error) {
+	for _, part := range strings.Split(value, ";") {
+		eq := strings.Index(part, "=")
+		if eq == -1 {
+			return fmt.Errorf("Calendar: Invalid RRULE: %s", value)
+		}
+
+		key, v := strings.ToUpper(part[:eq]), part[eq+1:]
+
+		switch key {
+		case "FREQ":
+			switch v {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				event.freq = v
+			default:
+				return fmt.Errorf("Calendar: Unsupported FREQ: %s", v)
+			}
+		case "INTERVAL":
+			if event.interval, e = strconv.Atoi(v); e != nil || event.interval < 1 {
+				return fmt.Errorf("Calendar: Invalid INTERVAL: %s", v)
+			}
+		case "COUNT":
+			if event.count, e = strconv.Atoi(v); e != nil || event.count < 1 {
+				return fmt.Errorf("Calendar: Invalid COUNT: %s", v)
+			}
+		case "UNTIL":
+			if event.until, _, e = parseIcsTime(nil, v, loc); e != nil {
+				return e
+			}
+		case "WKST":
+			// Only matters for rules we don't support.
+		default:
+			return fmt.Errorf("Calendar: Unsupported RRULE part: %s", key)
+		}
+	}
+
+	return nil
+}
+
+// Start of the i'th occurrence. ok is false if that occurrence falls on a
+// date which doesn't exist, like February 30th, and so is skipped.
+func (ev *icsEvent) occurrence(i int) (start time.Time, ok bool) {
+	n := i * ev.interval
+	y, m, d := ev.start.Date()
+	hour, min, sec := ev.start.Clock()
+
+	switch ev.freq {
+	case "DAILY":
+		return ev.start.AddDate(0, 0, n), true
+	case "WEEKLY":
+		return ev.start.AddDate(0, 0, 7*n), true
+	case "MONTHLY":
+		start = time.Date(y, m+time.Month(n), d, hour, min, sec, ev.start.Nanosecond(), ev.start.Location())
+	case "YEARLY":
+		start = time.Date(y+n, m, d, hour, min, sec, ev.start.Nanosecond(), ev.start.Location())
+	default:
+		return ev.start, true
+	}
+
+	// time.Date normalizes invalid dates into the next month.
+	return start, start.Day() == d
+}
+
+func (ev *icsEvent) end(start time.Time) time.Time {
+	return start.AddDate(0, 0, ev.days).Add(ev.duration)
+}
+
+// Was the occurrence starting at start removed with EXDATE?
+func (ev *icsEvent) excluded(start time.Time) bool {
+	for _, exdate := range ev.exdates {
+		if exdate.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is the event active at now? If so, activeUntil is when the current
+// occurrence ends. nextStart is the start of the next occurrence after now,
+// or the zero time if there isn't one.
+func (ev *icsEvent) state(now time.Time) (active bool, activeUntil time.Time, nextStart time.Time) {
+	// Occurrences found so far. Skipped dates don't count towards COUNT.
+	found := 0
+
+	for i := 0; ; i++ {
+		start, ok := ev.occurrence(i)
+		if !ok {
+			continue
+		}
+
+		if (ev.count > 0 && found >= ev.count) || (!ev.until.IsZero() && start.After(ev.until)) {
+			break
+		}
+		found += 1
+
+		// Excluded occurrences still count towards COUNT.
+		if ev.excluded(start) {
+			if ev.freq == "" {
+				break
+			}
+			continue
+		}
+
+		if start.After(now) {
+			nextStart = start
+			break
+		}
+
+		if end := ev.end(start); end.After(now) {
+			active = true
+			if end.After(activeUntil) {
+				activeUntil = end
+			}
+		}
+
+		if ev.freq == "" {
+			break
+		}
+	}
+
+	return active, activeUntil, nextStart
+}
@@ -0,0 +1,154 @@
+package conditions
+
+import (
+	"gopkg.in/check.v1"
+	"strings"
+	"time"
+)
+
+const testIcs = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Christmas\r\n" +
+	"DTSTART;VALUE=DATE:20141225\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Vacation\\, family\r\n" +
+	"DTSTART;VALUE=DATE:20140801\r\n" +
+	"DTEND;VALUE=DATE:20140805\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"SUMMARY:Team\r\n" +
+	"  meeting\r\n" +
+	"DTSTART:20140602T170000Z\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"RRULE:FREQ=WEEKLY;INTERVAL=2;COUNT=3\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func (suite *MySuite) TestParseIcs(c *check.C) {
+	events, e := parseIcs(strings.NewReader(testIcs), time.UTC)
+	c.Assert(e, check.IsNil)
+	c.Assert(len(events), check.Equals, 3)
+
+	c.Check(events[0].summary, check.Equals, "Christmas")
+	c.Check(events[0].start, check.Equals, time.Date(2014, time.December, 25, 0, 0, 0, 0, time.UTC))
+	c.Check(events[0].days, check.Equals, 1)
+	c.Check(events[0].freq, check.Equals, "YEARLY")
+
+	c.Check(events[1].summary, check.Equals, "Vacation, family")
+	c.Check(events[1].days, check.Equals, 4)
+	c.Check(events[1].duration, check.Equals, time.Duration(0))
+
+	c.Check(events[2].summary, check.Equals, "Team meeting")
+	c.Check(events[2].duration, check.Equals, 90*time.Minute)
+	c.Check(events[2].interval, check.Equals, 2)
+	c.Check(events[2].count, check.Equals, 3)
+}
+
+func (suite *MySuite) TestParseIcsBad(c *check.C) {
+	bad := []string{
+		"BEGIN:VEVENT\nSUMMARY:x\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:tomorrow\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:20140101T000000\nEXDATE:someday\nEND:VEVENT\n",
+		"BEGIN:VEVENT\nDTSTART:20140101T000000\nDURATION:1H\nEND:VEVENT\n",
+	}
+
+	for _, ics := range bad {
+		_, e := parseIcs(strings.NewReader(ics), time.UTC)
+		c.Check(e, check.NotNil, check.Commentf(ics))
+	}
+}
+
+func (suite *MySuite) TestParseIcsSkipsUnsupported(c *check.C) {
+	ics := "BEGIN:VEVENT\nSUMMARY:hourly\nDTSTART:20140101T000000\nRRULE:FREQ=HOURLY\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:mondays\nDTSTART:20140101T000000\nRRULE:FREQ=DAILY;BYDAY=MO\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:daily\nDTEND:20140101T010000\nDTSTART:20140101T000000\nRRULE:FREQ=DAILY\nEND:VEVENT\n"
+
+	events, e := parseIcs(strings.NewReader(ics), time.UTC)
+	c.Assert(e, check.IsNil)
+	c.Assert(len(events), check.Equals, 1)
+
+	// DTEND may come before DTSTART.
+	c.Check(events[0].summary, check.Equals, "daily")
+	c.Check(events[0].duration, check.Equals, time.Hour)
+}
+
+func (suite *MySuite) TestIcsEventExdate(c *check.C) {
+	ics := "BEGIN:VEVENT\n" +
+		"DTSTART;TZID=UTC:20140101T090000\n" +
+		"DURATION:PT1H\n" +
+		"RRULE:FREQ=DAILY;COUNT=4\n" +
+		"EXDATE;TZID=UTC:20140102T090000,20140104T090000\n" +
+		"END:VEVENT\n"
+
+	events, e := parseIcs(strings.NewReader(ics), time.UTC)
+	c.Assert(e, check.IsNil)
+	c.Assert(len(events), check.Equals, 1)
+
+	// The second occurrence is excluded.
+	active, _, next := events[0].state(time.Date(2014, time.January, 2, 9, 30, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2014, time.January, 3, 9, 0, 0, 0, time.UTC))
+
+	// So is the last, which leaves none.
+	active, _, next = events[0].state(time.Date(2014, time.January, 3, 9, 30, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+	c.Check(next.IsZero(), check.Equals, true)
+}
+
+func (suite *MySuite) TestIcsEventSkipsMissingDates(c *check.C) {
+	ics := "BEGIN:VEVENT\nSUMMARY:monthly\nDTSTART;VALUE=DATE:20140131\nRRULE:FREQ=MONTHLY;COUNT=3\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nSUMMARY:leap\nDTSTART;VALUE=DATE:20120229\nRRULE:FREQ=YEARLY\nEND:VEVENT\n"
+
+	events, e := parseIcs(strings.NewReader(ics), time.UTC)
+	c.Assert(e, check.IsNil)
+	monthly, leap := events[0], events[1]
+
+	// February has no 31st, so the next occurrence is in March.
+	active, _, next := monthly.state(time.Date(2014, time.February, 1, 0, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2014, time.March, 31, 0, 0, 0, 0, time.UTC))
+
+	// Skipped months don't count, so there are three occurrences.
+	active, _, next = monthly.state(time.Date(2014, time.April, 1, 0, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2014, time.May, 31, 0, 0, 0, 0, time.UTC))
+
+	active, _, next = leap.state(time.Date(2013, time.March, 1, 12, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2016, time.February, 29, 0, 0, 0, 0, time.UTC))
+}
+
+func (suite *MySuite) TestIcsEventState(c *check.C) {
+	events, e := parseIcs(strings.NewReader(testIcs), time.UTC)
+	c.Assert(e, check.IsNil)
+
+	christmas, meeting := events[0], events[2]
+
+	// A yearly all day event.
+	active, until, next := christmas.state(time.Date(2016, time.December, 25, 12, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+	c.Check(until, check.Equals, time.Date(2016, time.December, 26, 0, 0, 0, 0, time.UTC))
+	c.Check(next, check.Equals, time.Date(2017, time.December, 25, 0, 0, 0, 0, time.UTC))
+
+	active, _, next = christmas.state(time.Date(2016, time.December, 26, 0, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2017, time.December, 25, 0, 0, 0, 0, time.UTC))
+
+	// Before the first occurrence.
+	active, _, next = christmas.state(time.Date(2014, time.June, 1, 0, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next, check.Equals, christmas.start)
+
+	// Every other week, three times.
+	active, until, next = meeting.state(time.Date(2014, time.June, 16, 18, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+	c.Check(until, check.Equals, time.Date(2014, time.June, 16, 18, 30, 0, 0, time.UTC))
+	c.Check(next, check.Equals, time.Date(2014, time.June, 30, 17, 0, 0, 0, time.UTC))
+
+	active, _, next = meeting.state(time.Date(2014, time.June, 30, 19, 0, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+	c.Check(next.IsZero(), check.Equals, true)
+}
@@ -0,0 +1,249 @@
+package conditions
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"time"
+)
+
+// A solar event happens when the center of the sun crosses a zenith angle
+// (in degrees), while rising or setting.
+type solarEvent struct {
+	zenith float64
+	rising bool
+}
+
+// Zenith angles of the sun at sunrise/sunset, and at the start of each kind of
+// twilight.
+const (
+	ZENITH_OFFICIAL     = 90.833
+	ZENITH_CIVIL        = 96.0
+	ZENITH_NAUTICAL     = 102.0
+	ZENITH_ASTRONOMICAL = 108.0
+)
+
+var twilightZeniths = map[string]float64{
+	"civil":        ZENITH_CIVIL,
+	"nautical":     ZENITH_NAUTICAL,
+	"astronomical": ZENITH_ASTRONOMICAL,
+}
+
+// Named solar events. These are published under status://server/solar, and
+// may be used in time conditions.
+var solarEvents = map[string]solarEvent{
+	"sunrise":           {ZENITH_OFFICIAL, true},
+	"sunset":            {ZENITH_OFFICIAL, false},
+	"civil_dawn":        {ZENITH_CIVIL, true},
+	"civil_dusk":        {ZENITH_CIVIL, false},
+	"nautical_dawn":     {ZENITH_NAUTICAL, true},
+	"nautical_dusk":     {ZENITH_NAUTICAL, false},
+	"astronomical_dawn": {ZENITH_ASTRONOMICAL, true},
+	"astronomical_dusk": {ZENITH_ASTRONOMICAL, false},
+}
+
+// Short names for civil twilight.
+var solarAliases = map[string]string{
+	"dawn": "civil_dawn",
+	"dusk": "civil_dusk",
+}
+
+// "sunset", "sunrise+15m", "civil_dusk - 1h30m", etc.
+var solarExpression = regexp.MustCompile(`^\s*([a-z_]+)\s*(?:([+-])\s*(\S+))?\s*$`)
+
+// Parse a solar event name, with an optional offset. ok is false if the
+// expression doesn't name a solar event.
+func parseSolarExpression(expression string) (event solarEvent, offset time.Duration, ok bool, e error) {
+	parts := solarExpression.FindStringSubmatch(expression)
+	if parts == nil {
+		return event, 0, false, nil
+	}
+
+	name := parts[1]
+	if alias, found := solarAliases[name]; found {
+		name = alias
+	}
+
+	event, ok = solarEvents[name]
+	if !ok {
+		return event, 0, false, nil
+	}
+
+	if parts[2] != "" {
+		offset, e = time.ParseDuration(parts[3])
+		if e != nil {
+			return event, 0, true, fmt.Errorf("Solar offset: %s", e.Error())
+		}
+		if parts[2] == "-" {
+			offset = -offset
+		}
+	}
+
+	return event, offset, true, nil
+}
+
+// Less than 24 hours to help with daylight savings, and other weirdness.
+const DAY_INCREMENT = 13 * time.Hour
+
+// How far to search for an event. Near the poles, some events only happen
+// once a year.
+const SOLAR_SEARCH_LIMIT = 400 * 24 * time.Hour
+
+// Find the first occurrence of an event after now. Returns the zero time if
+// the event doesn't happen at this location.
+func findNextSolarEvent(now time.Time, event solarEvent, latitude, longitude float64) time.Time {
+	// Start in the past, because the calculation may return the next day's result.
+	for calcNow := now.Add(-24 * time.Hour); calcNow.Before(now.Add(SOLAR_SEARCH_LIMIT)); calcNow = calcNow.Add(DAY_INCREMENT) {
+		next, ok := calcSolarEvent(calcNow, event, latitude, longitude)
+		if ok && next.After(now) {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+// Find the last occurrence of an event at or before now. Returns the zero
+// time if the event doesn't happen at this location.
+func findPrevSolarEvent(now time.Time, event solarEvent, latitude, longitude float64) time.Time {
+	for calcNow := now.Add(24 * time.Hour); calcNow.After(now.Add(-SOLAR_SEARCH_LIMIT)); calcNow = calcNow.Add(-DAY_INCREMENT) {
+		prev, ok := calcSolarEvent(calcNow, event, latitude, longitude)
+		if ok && !prev.After(now) {
+			return prev
+		}
+	}
+
+	return time.Time{}
+}
+
+// The times of each named solar event during the local day containing day.
+// Events that don't happen that day are left out.
+func SolarTimes(day time.Time, latitude, longitude float64) map[string]time.Time {
+	year, month, date := day.Date()
+	start := time.Date(year, month, date, 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+
+	result := map[string]time.Time{}
+	for name, event := range solarEvents {
+		// Back up a moment so an event exactly at midnight is found.
+		next := findNextSolarEvent(start.Add(-time.Nanosecond), event, latitude, longitude)
+		if !next.IsZero() && next.Before(end) {
+			result[name] = next.In(day.Location())
+		}
+	}
+
+	return result
+}
+
+// Calculate an event for the UTC date containing t, using the NOAA
+// algorithm. Longitude is positive to the west, as with astrotime. The result
+// may fall on the next UTC day. ok is false if the sun never crosses the
+// event's zenith that day.
+func calcSolarEvent(t time.Time, event solarEvent, latitude, longitude float64) (result time.Time, ok bool) {
+	utc := t.UTC()
+	midnight := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC)
+	jd := julianDay(midnight)
+
+	// Find the approximate time from solar noon, then refine it using the sun's
+	// position at that time.
+	minutes := 720 + 4*longitude - equationOfTime(julianCentury(jd+longitude/360))
+	for i := 0; i < 2; i++ {
+		century := julianCentury(jd + minutes/1440)
+
+		hourAngle, ok := solarHourAngle(latitude, sunDeclination(century), event)
+		if !ok {
+			return time.Time{}, false
+		}
+
+		minutes = 720 + 4*(longitude-hourAngle) - equationOfTime(century)
+	}
+
+	return midnight.Add(time.Duration(minutes * float64(time.Minute))).In(t.Location()), true
+}
+
+func degToRad(deg float64) float64 { return deg * math.Pi / 180 }
+func radToDeg(rad float64) float64 { return rad * 180 / math.Pi }
+
+func julianDay(t time.Time) float64 {
+	year, month, day := t.Year(), int(t.Month()), t.Day()
+	if month <= 2 {
+		year -= 1
+		month += 12
+	}
+
+	a := math.Floor(float64(year) / 100)
+	b := 2 - a + math.Floor(a/4)
+
+	return math.Floor(365.25*float64(year+4716)) + math.Floor(30.6001*float64(month+1)) + float64(day) + b - 1524.5
+}
+
+func julianCentury(jd float64) float64 {
+	return (jd - 2451545.0) / 36525.0
+}
+
+// Hour angle of the sun (in degrees) when it crosses the event's zenith.
+// Negative for setting events.
+func solarHourAngle(latitude, declination float64, event solarEvent) (float64, bool) {
+	latRad := degToRad(latitude)
+	decRad := degToRad(declination)
+
+	cosHA := math.Cos(degToRad(event.zenith))/(math.Cos(latRad)*math.Cos(decRad)) - math.Tan(latRad)*math.Tan(decRad)
+	if math.IsNaN(cosHA) || cosHA < -1 || cosHA > 1 {
+		return 0, false
+	}
+
+	hourAngle := radToDeg(math.Acos(cosHA))
+	if !event.rising {
+		hourAngle = -hourAngle
+	}
+	return hourAngle, true
+}
+
+func geomMeanLongSun(t float64) float64 {
+	return math.Mod(280.46646+t*(36000.76983+0.0003032*t), 360)
+}
+
+func geomMeanAnomalySun(t float64) float64 {
+	return 357.52911 + t*(35999.05029-0.0001537*t)
+}
+
+func eccentricityEarthOrbit(t float64) float64 {
+	return 0.016708634 - t*(0.000042037+0.0000001267*t)
+}
+
+func obliquityCorrection(t float64) float64 {
+	seconds := 21.448 - t*(46.8150+t*(0.00059-t*0.001813))
+	meanObliquity := 23.0 + (26.0+seconds/60.0)/60.0
+	omega := 125.04 - 1934.136*t
+	return meanObliquity + 0.00256*math.Cos(degToRad(omega))
+}
+
+func sunDeclination(t float64) float64 {
+	m := degToRad(geomMeanAnomalySun(t))
+	center := math.Sin(m)*(1.914602-t*(0.004817+0.000014*t)) +
+		math.Sin(2*m)*(0.019993-0.000101*t) +
+		math.Sin(3*m)*0.000289
+
+	omega := 125.04 - 1934.136*t
+	apparentLong := geomMeanLongSun(t) + center - 0.00569 - 0.00478*math.Sin(degToRad(omega))
+
+	return radToDeg(math.Asin(math.Sin(degToRad(obliquityCorrection(t))) * math.Sin(degToRad(apparentLong))))
+}
+
+// Equation of time, in minutes.
+func equationOfTime(t float64) float64 {
+	epsilon := degToRad(obliquityCorrection(t))
+	l0 := degToRad(geomMeanLongSun(t))
+	e := eccentricityEarthOrbit(t)
+	m := degToRad(geomMeanAnomalySun(t))
+
+	y := math.Pow(math.Tan(epsilon/2), 2)
+
+	eTime := y*math.Sin(2*l0) -
+		2*e*math.Sin(m) +
+		4*e*y*math.Sin(m)*math.Cos(2*l0) -
+		0.5*y*y*math.Sin(4*l0) -
+		1.25*e*e*math.Sin(2*m)
+
+	return radToDeg(eTime) * 4
+}
@@ -0,0 +1,107 @@
+package conditions
+
+import (
+	"github.com/cpucycle/astrotime"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestParseSolarExpression(c *check.C) {
+	event, offset, ok, e := parseSolarExpression("sunset")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(event, check.Equals, solarEvents["sunset"])
+	c.Check(offset, check.Equals, time.Duration(0))
+
+	event, offset, ok, e = parseSolarExpression("sunrise+15m")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(event, check.Equals, solarEvents["sunrise"])
+	c.Check(offset, check.Equals, 15*time.Minute)
+
+	event, offset, ok, e = parseSolarExpression("dusk - 1h30m")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(event, check.Equals, solarEvents["civil_dusk"])
+	c.Check(offset, check.Equals, -90*time.Minute)
+
+	event, _, ok, e = parseSolarExpression("nautical_dawn")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, true)
+	c.Check(event, check.Equals, solarEvents["nautical_dawn"])
+
+	// Not solar expressions.
+	_, _, ok, e = parseSolarExpression("11:00")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	_, _, ok, e = parseSolarExpression("lunchtime")
+	c.Check(e, check.IsNil)
+	c.Check(ok, check.Equals, false)
+
+	// Bad offset.
+	_, _, ok, e = parseSolarExpression("sunset+soon")
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestSolarMatchesAstrotime(c *check.C) {
+	testDay := time.Date(2014, time.June, 13, 0, 0, 00, 0, time.UTC)
+
+	sunrise, ok := calcSolarEvent(testDay, solarEvents["sunrise"], LATITUDE, LONGITUDE)
+	c.Assert(ok, check.Equals, true)
+	sunset, ok := calcSolarEvent(testDay, solarEvents["sunset"], LATITUDE, LONGITUDE)
+	c.Assert(ok, check.Equals, true)
+
+	expectedSunrise := astrotime.CalcSunrise(testDay, LATITUDE, LONGITUDE)
+	expectedSunset := astrotime.CalcSunset(testDay, LATITUDE, LONGITUDE)
+
+	c.Check(sunrise.Sub(expectedSunrise) < time.Minute, check.Equals, true)
+	c.Check(expectedSunrise.Sub(sunrise) < time.Minute, check.Equals, true)
+	c.Check(sunset.Sub(expectedSunset) < time.Minute, check.Equals, true)
+	c.Check(expectedSunset.Sub(sunset) < time.Minute, check.Equals, true)
+}
+
+func (suite *MySuite) TestSolarTimes(c *check.C) {
+	pacific := time.FixedZone("PDT", -7*60*60)
+	testDay := time.Date(2014, time.June, 13, 12, 0, 00, 0, pacific)
+
+	times := SolarTimes(testDay, LATITUDE, LONGITUDE)
+	c.Assert(len(times), check.Equals, 8)
+
+	// All on the requested day.
+	for name, t := range times {
+		c.Check(t.Day(), check.Equals, 13, check.Commentf(name))
+		c.Check(t.Location(), check.Equals, pacific, check.Commentf(name))
+	}
+
+	// In the expected order.
+	order := []string{
+		"astronomical_dawn", "nautical_dawn", "civil_dawn", "sunrise",
+		"sunset", "civil_dusk", "nautical_dusk", "astronomical_dusk",
+	}
+	for i := 1; i < len(order); i++ {
+		c.Check(times[order[i-1]].Before(times[order[i]]), check.Equals, true, check.Commentf(order[i]))
+	}
+
+	// Civil twilight is about half an hour in Mountain View.
+	c.Check(times["sunrise"].Sub(times["civil_dawn"]) > 20*time.Minute, check.Equals, true)
+	c.Check(times["sunrise"].Sub(times["civil_dawn"]) < 40*time.Minute, check.Equals, true)
+
+	// Near the north pole in June, the sun never sets.
+	times = SolarTimes(testDay, 80, 0)
+	_, ok := times["sunset"]
+	c.Check(ok, check.Equals, false)
+}
+
+func (suite *MySuite) TestFindPrevSolarEvent(c *check.C) {
+	testDay := time.Date(2014, time.June, 13, 0, 0, 00, 0, time.UTC)
+	event := solarEvents["civil_dusk"]
+
+	next := findNextSolarEvent(testDay, event, LATITUDE, LONGITUDE)
+	c.Check(findPrevSolarEvent(next, event, LATITUDE, LONGITUDE), check.Equals, next)
+	c.Check(findPrevSolarEvent(next.Add(time.Hour), event, LATITUDE, LONGITUDE), check.Equals, next)
+
+	prev := findPrevSolarEvent(next.Add(-time.Second), event, LATITUDE, LONGITUDE)
+	c.Check(next.Sub(prev) > 23*time.Hour, check.Equals, true)
+	c.Check(next.Sub(prev) < 25*time.Hour, check.Equals, true)
+}
@@ -0,0 +1,78 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"time"
+)
+
+// Follows an inner condition, but becomes true at most once per interval.
+// True edges inside the interval are dropped.
+type throttleCondition struct {
+	base
+
+	condition Condition
+	interval  time.Duration
+}
+
+func newThrottleCondition(s *status.Status, clk clock.Clock, body *status.Status) (*throttleCondition, error) {
+	interval, e := durationOption(body, "interval", "Throttle")
+	if e != nil {
+		return nil, e
+	}
+
+	condition, e := newInnerCondition(s, clk, body, "Throttle")
+	if e != nil {
+		return nil, e
+	}
+
+	// Create our condition.
+	c := &throttleCondition{newBase(s, clk), condition, interval}
+	c.setChildren(condition)
+
+	c.start()
+	return c, nil
+}
+
+func (c *throttleCondition) start() {
+	// Start it's goroutine.
+	c.startHandler(c.Handler)
+}
+
+func (c *throttleCondition) Stop() {
+	// Shut down inner condition before stopping ourselves. This means we
+	// can react to final result updates from them, and avoid deadlocks.
+	c.condition.Stop()
+	c.base.Stop()
+}
+
+func (c *throttleCondition) Handler() {
+	// When we last became true.
+	var lastTrue time.Time
+
+	c.handled()
+
+	for {
+		select {
+		case condValue := <-c.condition.Result():
+			switch {
+			case !condValue:
+				c.sendResult(false)
+			case c.initialSent && c.lastSent:
+				// Already true.
+			case lastTrue.IsZero() || c.clock.Now().Sub(lastTrue) >= c.interval:
+				lastTrue = c.clock.Now()
+				c.sendResult(true)
+			default:
+				// Dropped, but we still need an initial value.
+				c.sendResult(false)
+			}
+
+		case <-c.StopChan:
+			c.StopChan <- true
+			return
+		}
+
+		c.handled()
+	}
+}
@@ -0,0 +1,53 @@
+package conditions
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func (suite *MySuite) TestThrottleParsing(c *check.C) {
+	validateConditionJson(c, "{}", `{"test": "throttle", "interval": "1m", "condition": {"test": "true"}}`, true)
+	validateConditionJson(c, "{}", `{"test": "throttle", "interval": "1m", "condition": {"test": "false"}}`, false)
+
+	validateConditionBadJson(c, `{"test": "throttle", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "throttle", "interval": "0s", "condition": {"test": "true"}}`)
+	validateConditionBadJson(c, `{"test": "throttle", "interval": "1m"}`)
+}
+
+func (suite *MySuite) TestThrottleMock(c *check.C) {
+	s := &status.Status{}
+	mockCond := &mockCondition{make(chan bool)}
+
+	interval := 10 * EMPTY_DELAY
+	cond := &throttleCondition{newBase(s, clock.NewReal()), mockCond, interval}
+	cond.start()
+
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+
+	// The first pulse passes.
+	start := time.Now()
+	mockCond.result <- true
+	mockCond.result <- false
+	validateChannelRead(c, cond, true)
+	validateChannelRead(c, cond, false)
+
+	// Pulses inside the interval are dropped.
+	mockCond.result <- true
+	mockCond.result <- false
+	validateChannelEmpty(c, cond)
+
+	// Later pulses pass.
+	time.Sleep(interval - time.Since(start))
+	mockCond.result <- true
+	validateChannelRead(c, cond, true)
+	validateChannelEmpty(c, cond)
+
+	mockCond.result <- false
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
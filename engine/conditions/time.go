@@ -1,27 +1,82 @@
 package conditions
 
 import (
-	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/options"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-timeglob/timeglob"
 	"time"
 )
 
+// When a time condition fires. Returns timeglob.UNKNOWN if there is no such
+// time.
+type timeSchedule interface {
+	Next(now time.Time) time.Time
+	Prev(now time.Time) time.Time
+}
+
 type timeCondition struct {
 	base
 
-	timeglob *timeglob.TimeGlob // Time of day at which to fire.
-	duration time.Duration      // Duration for which to fire.
+	schedule timeSchedule   // Times at which to fire.
+	duration time.Duration  // Duration for which to fire.
+	location *time.Location // Timezone of the schedule.
+}
+
+// A schedule that follows a solar event, like "sunset-30m".
+type solarSchedule struct {
+	event     solarEvent
+	offset    time.Duration
+	latitude  float64
+	longitude float64
+}
+
+func (t *solarSchedule) Next(now time.Time) time.Time {
+	next := findNextSolarEvent(now.Add(-t.offset), t.event, t.latitude, t.longitude)
+	if next.IsZero() {
+		return timeglob.UNKNOWN
+	}
+	return next.Add(t.offset)
+}
+
+func (t *solarSchedule) Prev(now time.Time) time.Time {
+	prev := findPrevSolarEvent(now.Add(-t.offset), t.event, t.latitude, t.longitude)
+	if prev.IsZero() {
+		return timeglob.UNKNOWN
+	}
+	return prev.Add(t.offset)
+}
+
+// Parse a time description into a schedule. Solar events (with optional
+// offsets) are checked first, then timeglob formats.
+func parseTimeSchedule(s *status.Status, timeDescription string) (timeSchedule, error) {
+	event, offset, ok, e := parseSolarExpression(timeDescription)
+	if e != nil {
+		return nil, e
+	}
+
+	if ok {
+		latitude := s.GetFloatWithDefault("status://server/latitude", 0.0)
+		longitude := s.GetFloatWithDefault("status://server/longitude", 0.0)
+		return &solarSchedule{event, offset, latitude, longitude}, nil
+	}
+
+	tg, e := timeglob.Parse(timeDescription)
+	if e != nil {
+		return nil, e
+	}
+
+	return tg, nil
 }
 
-func newTimeCondition(s *status.Status, body *status.Status) (*timeCondition, error) {
+func newTimeCondition(s *status.Status, clk clock.Clock, body *status.Status) (*timeCondition, error) {
 	timeDescription, _, e := body.GetString("status://time")
 	if e != nil {
 		return nil, e
 	}
 
 	// Parse time values.
-	tg, e := timeglob.Parse(timeDescription)
+	schedule, e := parseTimeSchedule(s, timeDescription)
 	if e != nil {
 		return nil, e
 	}
@@ -37,16 +92,21 @@ func newTimeCondition(s *status.Status, body *status.Status) (*timeCondition, er
 		return nil, e
 	}
 
-	c := &timeCondition{newBase(s), tg, duration}
+	location, e := options.Location(s)
+	if e != nil {
+		return nil, e
+	}
+
+	c := &timeCondition{newBase(s, clk), schedule, duration, location}
 
 	// Start it's goroutine.
-	go c.Handler()
+	c.startHandler(c.Handler)
 
 	return c, nil
 }
 
 func (c *timeCondition) findActiveState(now time.Time) (active bool, remainingActive time.Duration) {
-	prev := c.timeglob.Prev(now)
+	prev := c.schedule.Prev(now.In(c.location))
 	activeDuration := now.Sub(prev)
 	active = prev != timeglob.UNKNOWN && activeDuration < c.duration
 
@@ -58,31 +118,60 @@ func (c *timeCondition) findActiveState(now time.Time) (active bool, remainingAc
 }
 
 func (c *timeCondition) Handler() {
-	start := c.timeglob.Ticker()
-	stop := time.NewTimer(0) // Stop right away to force initial evaluation.
+	start := c.clock.NewTimer(time.Hour)
+	start.Stop()
+	stop := c.clock.NewTimer(0) // Stop right away to force initial evaluation.
+
+	// When each timer will fire, or the zero time.
+	var startAt, stopAt time.Time
+
+	resetStart := func() {
+		now := c.clock.Now()
+		startAt = time.Time{}
+		if next := c.schedule.Next(now.In(c.location)); next != timeglob.UNKNOWN {
+			start.Reset(next.Sub(now))
+			startAt = next
+		}
+	}
+	resetStart()
 
 	handleActive := func() {
-		active, remainingActive := c.findActiveState(time.Now())
+		now := c.clock.Now()
+		active, remainingActive := c.findActiveState(now)
 		c.sendResult(active)
 		if active {
 			stop.Reset(remainingActive)
+			stopAt = now.Add(remainingActive)
 		} else {
 			stop.Stop()
+			stopAt = time.Time{}
 		}
 	}
 
+	// Our next change is whichever timer fires first.
+	updateNextChange := func() {
+		if stopAt.IsZero() || (!startAt.IsZero() && startAt.Before(stopAt)) {
+			c.setNextChange(startAt)
+		} else {
+			c.setNextChange(stopAt)
+		}
+	}
+
+	c.handled()
+
 	for {
 		select {
 		case <-start.C:
-			fmt.Printf("Start event.\n")
 			// Force a true to be sent when ticker fires. Below might not since
 			// now can be slightly after tick time when we are reached.
 			c.sendResult(true)
 			handleActive()
+			resetStart()
+			updateNextChange()
 
 		case <-stop.C:
-			fmt.Printf("Stop event.\n")
 			handleActive()
+			updateNextChange()
 
 		case <-c.StopChan:
 			start.Stop()
@@ -90,5 +179,7 @@ func (c *timeCondition) Handler() {
 			c.StopChan <- true
 			return
 		}
+
+		c.handled()
 	}
 }
@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
 	"time"
@@ -18,7 +19,7 @@ func setupTimeCondition(c *check.C, time, duration string) *timeCondition {
 		c.Assert(err, check.IsNil)
 	}
 
-	cond, err := newTimeCondition(s, body)
+	cond, err := newTimeCondition(s, clock.NewReal(), body)
 	c.Assert(err, check.IsNil)
 
 	return cond
@@ -118,3 +119,107 @@ func (suite *MySuite) TestTimeFindActiveStateDuration(c *check.C) {
 
 	cond.Stop()
 }
+
+func (suite *MySuite) TestTimeSolarExpression(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/latitude", LATITUDE, 0), check.IsNil)
+	c.Assert(s.Set("status://server/longitude", LONGITUDE, 1), check.IsNil)
+
+	body := &status.Status{}
+	c.Assert(body.Set("status://time", "sunset-30m", 0), check.IsNil)
+	c.Assert(body.Set("status://duration", "1m", 1), check.IsNil)
+
+	cond, e := newTimeCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	testDay := time.Date(2014, time.June, 13, 0, 0, 00, 0, time.UTC)
+	start := findNextSolarEvent(testDay, solarEvents["sunset"], LATITUDE, LONGITUDE).Add(-30 * time.Minute)
+
+	c.Check(cond.schedule.Next(start.Add(-time.Second)), check.Equals, start)
+
+	active, _ := cond.findActiveState(start.Add(-time.Second))
+	c.Check(active, check.Equals, false)
+
+	active, delay := cond.findActiveState(start.Add(10 * time.Second))
+	c.Check(active, check.Equals, true)
+	c.Check(delay, check.Equals, 50*time.Second)
+
+	active, _ = cond.findActiveState(start.Add(time.Minute))
+	c.Check(active, check.Equals, false)
+
+	cond.Stop()
+
+	validateConditionBadJson(c, `{"test": "time", "time": "sunset+later"}`)
+}
+
+func (suite *MySuite) TestTimeTimezone(c *check.C) {
+	pacific, e := time.LoadLocation("America/Los_Angeles")
+	c.Assert(e, check.IsNil)
+
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/timezone", "America/Los_Angeles", 0), check.IsNil)
+
+	body := &status.Status{}
+	c.Assert(body.Set("status://time", "11:00", 0), check.IsNil)
+	c.Assert(body.Set("status://duration", "1h", 1), check.IsNil)
+
+	cond, e := newTimeCondition(s, clock.NewReal(), body)
+	c.Assert(e, check.IsNil)
+
+	// 11:00 Pacific, whatever timezone now is given in.
+	active, _ := cond.findActiveState(time.Date(2014, time.June, 12, 18, 30, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+
+	active, _ = cond.findActiveState(time.Date(2014, time.June, 12, 11, 30, 0, 0, time.UTC))
+	c.Check(active, check.Equals, false)
+
+	// Across daylight savings changes, it stays at 11:00 local time.
+	next := cond.schedule.Next(time.Date(2014, time.March, 8, 12, 0, 0, 0, pacific))
+	c.Check(next.Equal(time.Date(2014, time.March, 9, 18, 0, 0, 0, time.UTC)), check.Equals, true)
+
+	next = cond.schedule.Next(time.Date(2014, time.November, 1, 12, 0, 0, 0, pacific))
+	c.Check(next.Equal(time.Date(2014, time.November, 2, 19, 0, 0, 0, time.UTC)), check.Equals, true)
+
+	active, _ = cond.findActiveState(time.Date(2014, time.November, 2, 19, 30, 0, 0, time.UTC))
+	c.Check(active, check.Equals, true)
+
+	cond.Stop()
+
+	// An invalid timezone.
+	c.Assert(s.Set("status://server/timezone", "Nowhere/Special", 1), check.IsNil)
+	_, e = newTimeCondition(s, clock.NewReal(), body)
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestTimeFakeClockWeek(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://server/timezone", "America/Los_Angeles", 0), check.IsNil)
+
+	pacific, e := time.LoadLocation("America/Los_Angeles")
+	c.Assert(e, check.IsNil)
+
+	// A week across the fall daylight savings change.
+	clk := clock.NewFake(time.Date(2014, time.October, 30, 0, 0, 0, 0, pacific))
+
+	body := &status.Status{}
+	c.Assert(body.Set("status://time", "11:00", 0), check.IsNil)
+	c.Assert(body.Set("status://duration", "1h", 1), check.IsNil)
+
+	cond, e := newTimeCondition(s, clk, body)
+	c.Assert(e, check.IsNil)
+
+	validateChannelRead(c, cond, false)
+
+	for day := 30; day < 37; day++ {
+		waitForTimers(c, clk, 1)
+
+		clk.Set(time.Date(2014, time.October, day, 11, 0, 0, 0, pacific))
+		validateChannelRead(c, cond, true)
+		c.Check(clk.Now().In(pacific).Hour(), check.Equals, 11)
+
+		clk.Advance(time.Hour)
+		validateChannelRead(c, cond, false)
+	}
+
+	cond.Stop()
+}
@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"reflect"
 )
@@ -14,7 +15,7 @@ type watchCondition struct {
 	watchChan <-chan status.UrlMatches
 }
 
-func newWatchCondition(s *status.Status, body *status.Status) (*watchCondition, error) {
+func newWatchCondition(s *status.Status, clk clock.Clock, body *status.Status) (*watchCondition, error) {
 
 	watchUrl, _, e := body.GetString("status://watch")
 	if e != nil {
@@ -34,13 +35,14 @@ func newWatchCondition(s *status.Status, body *status.Status) (*watchCondition,
 	// Throw away the initial event that's always sent.
 	if !hasTrigger {
 		<-watchChan
+		clock.Handled(clk)
 	}
 
 	// Create our condition.
-	c := &watchCondition{newBase(s), hasTrigger, trigger, watchChan}
+	c := &watchCondition{newBase(s, clk), hasTrigger, trigger, watchChan}
 
 	// Start it's goroutine.
-	go c.Handler()
+	c.startHandler(c.Handler)
 
 	return c, nil
 }
@@ -62,6 +64,8 @@ func (c *watchCondition) Handler() {
 		c.sendResult(false)
 	}
 
+	c.handled()
+
 	for {
 		select {
 		case matches := <-c.watchChan:
@@ -78,5 +82,7 @@ func (c *watchCondition) Handler() {
 			c.StopChan <- true
 			return
 		}
+
+		c.handled()
 	}
 }
@@ -1,6 +1,7 @@
 package conditions
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
 )
@@ -12,7 +13,7 @@ func setupWatchCondition(c *check.C, url string) (*status.Status, *watchConditio
 	e := body.Set("status://watch", url, 0)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	return s, cond
@@ -27,7 +28,7 @@ func setupTriggerWatchCondition(c *check.C, url string, trigger interface{}) (*s
 	e = body.Set("status://trigger", trigger, 1)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	return s, cond
@@ -53,7 +54,7 @@ func (suite *MySuite) TestWatchNoUrl(c *check.C) {
 	s := &status.Status{}
 	body := &status.Status{}
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.NotNil)
 	c.Assert(cond, check.IsNil)
 }
@@ -65,7 +66,7 @@ func (suite *MySuite) TestWatchBadUrl(c *check.C) {
 	e := body.Set("status://watch", "Bad Url", 0)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.NotNil)
 	c.Assert(cond, check.IsNil)
 }
@@ -91,6 +92,25 @@ func (suite *MySuite) TestWatchWithUpdates(c *check.C) {
 	cond.Stop()
 }
 
+func (suite *MySuite) TestWatchWithPredicate(c *check.C) {
+	s, cond := setupWatchCondition(c, "status://**/*[tripped=true]")
+
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	// Unrelated and non-matching changes are ignored.
+	s.Set("status://vera/door/tripped", false, status.UNCHECKED_REVISION)
+	s.Set("status://vera/door/name", "door", status.UNCHECKED_REVISION)
+	validateChannelEmpty(c, cond)
+
+	s.Set("status://vera/door/tripped", true, status.UNCHECKED_REVISION)
+	validateChannelRead(c, cond, true)
+	validateChannelRead(c, cond, false)
+	validateChannelEmpty(c, cond)
+
+	cond.Stop()
+}
+
 func (suite *MySuite) TestWatchWithTriggersInitialTrue(c *check.C) {
 	url := "status://foo"
 	trigger := true
@@ -106,7 +126,7 @@ func (suite *MySuite) TestWatchWithTriggersInitialTrue(c *check.C) {
 	e = body.Set("status://trigger", trigger, 1)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	validateChannelRead(c, cond, true)
@@ -134,7 +154,7 @@ func (suite *MySuite) TestWatchWithTriggersInitialFalse(c *check.C) {
 	e = body.Set("status://trigger", trigger, 1)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	validateChannelRead(c, cond, false)
@@ -204,7 +224,7 @@ func (suite *MySuite) TestWatchWithPresetValue(c *check.C) {
 	e = body.Set("status://watch", "status://foo", 0)
 	c.Assert(e, check.IsNil)
 
-	cond, e := newWatchCondition(s, body)
+	cond, e := newWatchCondition(s, clock.NewReal(), body)
 	c.Assert(e, check.IsNil)
 
 	// Make sure we don't set true, if the value was set before we start.
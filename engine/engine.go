@@ -1,12 +1,17 @@
 package engine
 
 import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine/actions"
+	"github.com/DonGar/go-house/engine/conditions"
 	"github.com/DonGar/go-house/engine/properties"
 	"github.com/DonGar/go-house/engine/rules"
+	"github.com/DonGar/go-house/options"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"strings"
+	"time"
 )
 
 const rules_watch_url = "status://*/rule/*"
@@ -14,15 +19,30 @@ const properties_watch_url = "status://*/property/*"
 
 type Engine struct {
 	status     *status.Status
+	clock      clock.Clock
 	actions    *actions.Manager
 	rules      *watcher
 	properties *watcher
+	solar      *solarPublisher
+	observer   rules.Observer
 }
 
-func NewEngine(status *status.Status, actions *actions.Manager) (engine *Engine, e error) {
-	engine = &Engine{status, actions, nil, nil}
-	engine.rules = newWatcher(status, rules_watch_url, engine.newRule)
-	engine.properties = newWatcher(status, properties_watch_url, engine.newProperty)
+func NewEngine(status *status.Status, clk clock.Clock, actions *actions.Manager) (engine *Engine, e error) {
+	return NewObservedEngine(status, clk, actions, nil)
+}
+
+// Create an engine which reports every rule condition change to observer.
+func NewObservedEngine(status *status.Status, clk clock.Clock, actions *actions.Manager, observer rules.Observer) (engine *Engine, e error) {
+	// Rules and properties publish their state here, and nothing else may
+	// change it.
+	if e = status.SetReadOnly(options.ENGINE, options.ENGINE_ORIGIN); e != nil {
+		return nil, e
+	}
+
+	engine = &Engine{status, clk, actions, nil, nil, nil, observer}
+	engine.rules = newWatcher(status, clk, rules_watch_url, engine.newRule)
+	engine.properties = newWatcher(status, clk, properties_watch_url, engine.newProperty)
+	engine.solar = newSolarPublisher(status, clk)
 
 	return engine, nil
 }
@@ -30,6 +50,61 @@ func NewEngine(status *status.Status, actions *actions.Manager) (engine *Engine,
 func (e *Engine) Stop() {
 	e.rules.Stop()
 	e.properties.Stop()
+	e.solar.Stop()
+}
+
+// Find an active rule by name.
+func (e *Engine) findRule(name string) (*rules.Rule, error) {
+	active, ok := e.rules.find(name)
+	if !ok {
+		return nil, fmt.Errorf("Engine: No rule: %s", name)
+	}
+
+	return active.(*rules.Rule), nil
+}
+
+// Explain the current state of a rule's condition.
+func (e *Engine) ExplainRule(name string) (conditions.Explanation, error) {
+	rule, err := e.findRule(name)
+	if err != nil {
+		return conditions.Explanation{}, err
+	}
+
+	return rule.Explain(), nil
+}
+
+// Enable or disable a rule's actions, overriding "enabled" in it's body.
+func (e *Engine) EnableRule(origin, name string, enabled bool) error {
+	if _, err := e.findRule(name); err != nil {
+		return err
+	}
+
+	return e.status.SetFrom(origin, rules.ControlUrl(name)+"/enabled", enabled, status.UNCHECKED_REVISION)
+}
+
+// Stop a rule's actions from firing for duration, overriding "snooze_until" in
+// it's body. A duration of 0 ends any snooze.
+func (e *Engine) SnoozeRule(origin, name string, duration time.Duration) error {
+	if _, err := e.findRule(name); err != nil {
+		return err
+	}
+
+	var until interface{}
+	if duration > 0 {
+		until = options.FormatTime(e.status, e.clock.Now().Add(duration))
+	}
+
+	return e.status.SetFrom(origin, rules.ControlUrl(name)+"/snooze_until", until, status.UNCHECKED_REVISION)
+}
+
+// Fire a rule's on or off action now, even if it's disabled or snoozed.
+func (e *Engine) FireRule(name string, on bool) error {
+	rule, err := e.findRule(name)
+	if err != nil {
+		return err
+	}
+
+	return rule.Fire(on)
 }
 
 func nameFromUrl(url string) string {
@@ -39,10 +114,11 @@ func nameFromUrl(url string) string {
 	return url_parts[len(url_parts)-1]
 }
 
-func (e *Engine) newRule(url string, body *status.Status) (stoppable.Stoppable, error) {
-	return rules.NewRule(e.status, e.actions, nameFromUrl(url), body)
+func (e *Engine) newRule(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error) {
+	previousRule, _ := previous.(*rules.Rule)
+	return rules.NewRule(e.status, e.clock, e.actions, nameFromUrl(url), body, e.observer, previousRule)
 }
 
-func (e *Engine) newProperty(url string, body *status.Status) (stoppable.Stoppable, error) {
-	return properties.NewProperty(e.status, nameFromUrl(url), body)
+func (e *Engine) newProperty(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error) {
+	return properties.NewProperty(e.status, e.clock, nameFromUrl(url), body)
 }
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine/actions"
 	"github.com/DonGar/go-house/status"
 	"gopkg.in/check.v1"
@@ -15,7 +16,7 @@ type MySuite struct{}
 
 var _ = check.Suite(&MySuite{})
 
-func bogus_action(am actions.Manager, s *status.Status, action *status.Status) (e error) {
+func bogus_action(s *status.Status, origin string, action *status.Status) (e error) {
 	return nil
 }
 
@@ -59,7 +60,7 @@ func (suite *MySuite) TestEngineStartStopEmpty(c *check.C) {
 	s := &status.Status{}
 	a := actions.NewManager()
 
-	engine, e := NewEngine(s, a)
+	engine, e := NewEngine(s, clock.NewReal(), a)
 	c.Assert(e, check.IsNil)
 
 	// Stop it.
@@ -71,15 +72,55 @@ func (suite *MySuite) TestEngineStartStopPopulated(c *check.C) {
 	s := setupTestStatus(c)
 	a := actions.NewManager()
 
-	engine, e := NewEngine(s, a)
+	engine, e := NewEngine(s, clock.NewReal(), a)
 	c.Assert(e, check.IsNil)
 
 	// We give the watcher a little time to finish initializing.
 	time.Sleep(100 * time.Millisecond)
-	c.Check(len(engine.rules.active), check.Equals, 2)
+	c.Check(activeCount(engine.rules), check.Equals, 2)
+
+	// Each rule publishes it's state, which only the engine can change.
+	condition, _, e := s.Get("status://engine/rules/RuleOne/condition")
+	c.Check(e, check.IsNil)
+	c.Check(condition, check.Equals, false)
+
+	e = s.SetFrom("rule:RuleTwo", "status://engine/rules/RuleOne/condition", true, status.UNCHECKED_REVISION)
+	c.Check(e, check.NotNil)
+
+	explanation, e := engine.ExplainRule("RuleOne")
+	c.Check(e, check.IsNil)
+	c.Check(explanation.Type, check.Equals, "false")
+
+	_, e = engine.ExplainRule("Bogus")
+	c.Check(e, check.NotNil)
 
 	// Stop it.
 	engine.Stop()
 
-	c.Check(len(engine.rules.active), check.Equals, 0)
+	c.Check(activeCount(engine.rules), check.Equals, 0)
+
+	names, _, e := s.GetChildNames("status://engine/rules")
+	c.Check(e, check.IsNil)
+	c.Check(names, check.HasLen, 0)
+}
+
+func (suite *MySuite) TestEngineSolarTimes(c *check.C) {
+	s := setupTestStatus(c)
+	a := actions.NewManager()
+
+	engine, e := NewEngine(s, clock.NewReal(), a)
+	c.Assert(e, check.IsNil)
+
+	time.Sleep(100 * time.Millisecond)
+
+	sunrise, _, e := s.GetString("status://server/solar/sunrise")
+	c.Assert(e, check.IsNil)
+
+	_, e = time.Parse(time.RFC3339, sunrise)
+	c.Check(e, check.IsNil)
+
+	_, _, e = s.GetString("status://server/solar/civil_dusk")
+	c.Check(e, check.IsNil)
+
+	engine.Stop()
 }
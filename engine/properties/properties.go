@@ -2,7 +2,9 @@ package properties
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine/conditions"
+	"github.com/DonGar/go-house/options"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"log"
@@ -18,6 +20,7 @@ type conditionValue struct {
 // The base type all rules should compose with.
 type Property struct {
 	status      *status.Status
+	clock       clock.Clock
 	name        string
 	targetUrl   string
 	conditions  []conditionValue
@@ -28,6 +31,7 @@ type Property struct {
 
 func NewProperty(
 	status *status.Status,
+	clk clock.Clock,
 	name string,
 	body *status.Status) (*Property, error) {
 
@@ -42,7 +46,7 @@ func NewProperty(
 	if e != nil {
 		return nil, fmt.Errorf("No 'values' on property: %s", name)
 	}
-	conditionValues, e := parseConditionValues(status, name, valuesRaw)
+	conditionValues, e := parseConditionValues(status, clk, name, valuesRaw)
 	if e != nil {
 		return nil, e
 	}
@@ -53,6 +57,7 @@ func NewProperty(
 
 	result := &Property{
 		status,
+		clk,
 		name,
 		targetUrl,
 		conditionValues,
@@ -64,7 +69,7 @@ func NewProperty(
 	return result, nil
 }
 
-func parseConditionValues(s *status.Status, name string, valuesRaw interface{}) ([]conditionValue, error) {
+func parseConditionValues(s *status.Status, clk clock.Clock, name string, valuesRaw interface{}) ([]conditionValue, error) {
 	conditionValues := []conditionValue{}
 
 	valuesArray, ok := valuesRaw.([]interface{})
@@ -96,7 +101,7 @@ func parseConditionValues(s *status.Status, name string, valuesRaw interface{})
 		conditionBodyStatus := &status.Status{}
 		conditionBodyStatus.Set("status://", conditionBody, status.UNCHECKED_REVISION)
 
-		condition, e := conditions.NewCondition(s, conditionBodyStatus)
+		condition, e := conditions.NewCondition(s, clk, conditionBodyStatus)
 		if e != nil {
 			return nil, fmt.Errorf(
 				"Invalid 'condition' on value %d (%#v) on property: %s: %s",
@@ -112,23 +117,55 @@ func parseConditionValues(s *status.Status, name string, valuesRaw interface{})
 
 func (p *Property) start() {
 	log.Printf("Start property: %s", p.name) // url)
+
+	// Starting counts as an event, until the default is set.
+	clock.Sent(p.clock)
 	go p.Handler()
 }
 
 func (p *Property) Stop() {
 	// Stop the conditions BEFORE stopping our listener, or we will deadlock
 	// if they send a shutdown result.
-	for _, c := range p.conditions {
-		c.condition.Stop()
+	// Index, rather than copy, since the handler may be updating results.
+	for i := range p.conditions {
+		p.conditions[i].condition.Stop()
 	}
 
 	p.Base.Stop()
+
+	p.status.RemoveFrom(options.ENGINE_ORIGIN, p.stateUrl(), status.UNCHECKED_REVISION)
+}
+
+// Where this property's runtime state is published.
+func (p *Property) stateUrl() string {
+	return options.ENGINE + "/properties/" + p.name
+}
+
+// Publish which value is active (nil for the default, or none), and the
+// result of each condition.
+func (p *Property) publishState(active interface{}, value interface{}) {
+	results := []interface{}{}
+	for _, c := range p.conditions {
+		results = append(results, c.result)
+	}
+
+	state := map[string]interface{}{
+		"target":     p.targetUrl,
+		"active":     active,
+		"value":      value,
+		"conditions": results,
+	}
+
+	e := p.status.SetFrom(options.ENGINE_ORIGIN, p.stateUrl(), state, status.UNCHECKED_REVISION)
+	if e != nil {
+		log.Printf("Property (%s) state update failed: %s", p.name, e.Error())
+	}
 }
 
 func (p *Property) updateTarget() {
 
 	setTarget := func(value interface{}) {
-		e := p.status.Set(p.targetUrl, value, status.UNCHECKED_REVISION)
+		e := p.status.SetFrom("property:"+p.name, p.targetUrl, value, status.UNCHECKED_REVISION)
 		if e == nil {
 			log.Printf("Property (%s: %s) updated: %#v", p.name, p.targetUrl, value)
 		} else {
@@ -136,16 +173,21 @@ func (p *Property) updateTarget() {
 		}
 	}
 
-	for _, c := range p.conditions {
+	for i, c := range p.conditions {
 		if c.result {
+			p.publishState(i, c.value)
 			setTarget(c.value)
 			return
 		}
 	}
 
 	if p.hasDefault {
+		p.publishState(nil, p.defautValue)
 		setTarget(p.defautValue)
+		return
 	}
+
+	p.publishState(nil, nil)
 }
 
 func (p *Property) Handler() {
@@ -163,6 +205,7 @@ func (p *Property) Handler() {
 	channels[len(channels)-1] = reflect.SelectCase{
 		Dir: reflect.SelectRecv, Chan: reflect.ValueOf(p.StopChan)}
 
+	clock.Handled(p.clock)
 	for {
 		receivedOnChannel, value, _ := reflect.Select(channels)
 
@@ -170,6 +213,7 @@ func (p *Property) Handler() {
 			// One of our conditions updated it's result. Update ours accordingly.
 			p.conditions[receivedOnChannel].result = value.Bool()
 			p.updateTarget()
+			clock.Handled(p.clock)
 		} else {
 			// If it's after the conditions, it's the stop channel.
 			log.Printf("Stop property: %s", p.name) // url)
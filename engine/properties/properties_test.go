@@ -1,6 +1,8 @@
 package properties
 
 import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/engine/conditions"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"gopkg.in/check.v1"
@@ -30,6 +32,10 @@ func (m *mockCondition) Result() <-chan bool {
 func (m *mockCondition) Stop() {
 }
 
+func (m *mockCondition) Explain() conditions.Explanation {
+	return conditions.Explanation{Type: "mock"}
+}
+
 // Tests
 
 func propertyParseStartStop(c *check.C, bodyStr string) error {
@@ -39,7 +45,7 @@ func propertyParseStartStop(c *check.C, bodyStr string) error {
 	e := body.SetJson("status://", []byte(bodyStr), status.UNCHECKED_REVISION)
 	c.Assert(e, check.IsNil)
 
-	rule, e := NewProperty(s, "Test Property", body)
+	rule, e := NewProperty(s, clock.NewReal(), "Test Property", body)
 	if e != nil {
 		return e
 	}
@@ -136,6 +142,7 @@ func (suite *MySuite) TestPropertyFireDefault(c *check.C) {
 
 	p := &Property{
 		s,
+		clock.NewReal(),
 		"Test Property Default",
 		"status://target",
 		[]conditionValue{},
@@ -156,6 +163,7 @@ func (suite *MySuite) TestPropertyFireCondition(c *check.C) {
 
 	p := &Property{
 		s,
+		clock.NewReal(),
 		"Test Property Default",
 		"status://target",
 		[]conditionValue{{mockCond, false, "condition"}},
@@ -185,6 +193,7 @@ func (suite *MySuite) TestPropertyFireConditionMultiple(c *check.C) {
 
 	p := &Property{
 		s,
+		clock.NewReal(),
 		"Test Property Default",
 		"status://target",
 		[]conditionValue{
@@ -233,6 +242,7 @@ func (suite *MySuite) TestPropertyFireConditionMultipleNoDefault(c *check.C) {
 
 	p := &Property{
 		s,
+		clock.NewReal(),
 		"Test Property Default",
 		"status://target",
 		[]conditionValue{
@@ -265,3 +275,63 @@ func (suite *MySuite) TestPropertyFireConditionMultipleNoDefault(c *check.C) {
 
 	p.Stop()
 }
+
+func (suite *MySuite) TestPropertyState(c *check.C) {
+	s := &status.Status{}
+	mockCond := []*mockCondition{
+		{make(chan bool)},
+		{make(chan bool)}}
+
+	p := &Property{
+		s,
+		clock.NewReal(),
+		"state",
+		"status://target",
+		[]conditionValue{
+			{mockCond[0], false, "condition 0"},
+			{mockCond[1], false, "condition 1"},
+		},
+		true,
+		"default set",
+		stoppable.NewBase(),
+	}
+
+	validateState := func(expected map[string]interface{}) {
+		state, _, e := s.Get("status://engine/properties/state")
+		c.Check(e, check.IsNil)
+		c.Check(state, check.DeepEquals, expected)
+	}
+
+	p.start()
+	validateTarget(c, s, "default set")
+	validateState(map[string]interface{}{
+		"target":     "status://target",
+		"active":     nil,
+		"value":      "default set",
+		"conditions": []interface{}{false, false},
+	})
+
+	mockCond[1].result <- true
+	validateTarget(c, s, "condition 1")
+	validateState(map[string]interface{}{
+		"target":     "status://target",
+		"active":     1,
+		"value":      "condition 1",
+		"conditions": []interface{}{false, true},
+	})
+
+	mockCond[0].result <- true
+	validateTarget(c, s, "condition 0")
+	validateState(map[string]interface{}{
+		"target":     "status://target",
+		"active":     0,
+		"value":      "condition 0",
+		"conditions": []interface{}{true, true},
+	})
+
+	// The state goes away with the property.
+	p.Stop()
+
+	_, _, e := s.Get("status://engine/properties/state")
+	c.Check(e, check.NotNil)
+}
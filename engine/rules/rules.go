@@ -2,28 +2,98 @@ package rules
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine/actions"
 	"github.com/DonGar/go-house/engine/conditions"
+	"github.com/DonGar/go-house/options"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"log"
+	"reflect"
+	"sync"
+	"time"
 )
 
+// Notified each time a rule's condition produces a new value.
+type Observer func(name string, value bool)
+
 type Rule struct {
 	status        *status.Status
+	clock         clock.Clock
 	actionManager *actions.Manager
 	name          string // name of this rule.
 	condition     conditions.Condition
+	conditionBody interface{}    // The body condition was created from.
 	actionOn      *status.Status // Substatus of the rule's action.
 	actionOff     *status.Status // Substatus of the rule's action.
+	observer      Observer       // nil if nothing is observing.
+	fireCount     int
+	defaults      control                  // From the rule body.
+	control       control                  // In effect, including overrides.
+	controlChan   <-chan status.UrlMatches // Watches our control values.
+	last          *bool                    // Last condition value, nil until known.
+	reloaded      bool                     // Don't fire if the first value matches last.
+	lock          sync.Mutex               // Protects fireCount, actions and controls.
 	stoppable.Base
 }
 
+// Whether a rule's actions fire when it's condition changes.
+type control struct {
+	disabled    bool
+	snoozeUntil time.Time // Zero if not snoozed.
+}
+
+// Do we block actions at time now?
+func (c control) blocks(now time.Time) bool {
+	return c.disabled || now.Before(c.snoozeUntil)
+}
+
+// Read "enabled" and "snooze_until" from body, using defaults for missing
+// values. A null "snooze_until" clears a default snooze.
+func parseControl(body *status.Status, defaults control) (control, error) {
+	result := defaults
+
+	if raw, _, e := body.Get("status://enabled"); e == nil {
+		enabled, ok := raw.(bool)
+		if !ok {
+			return result, fmt.Errorf("'enabled' must be true or false: %#v", raw)
+		}
+		result.disabled = !enabled
+	}
+
+	if raw, _, e := body.Get("status://snooze_until"); e == nil {
+		switch t := raw.(type) {
+		case nil:
+			result.snoozeUntil = time.Time{}
+		case string:
+			if result.snoozeUntil, e = time.Parse(time.RFC3339, t); e != nil {
+				return result, fmt.Errorf("Invalid 'snooze_until': %s", e.Error())
+			}
+		default:
+			return result, fmt.Errorf("'snooze_until' must be a time: %#v", raw)
+		}
+	}
+
+	return result, nil
+}
+
+// Where the control values for a rule are kept. They override "enabled" and
+// "snooze_until" in the rule body, and are kept separately so that changing
+// them doesn't recreate the rule.
+func ControlUrl(name string) string {
+	return options.RULE_CONTROL + "/" + name
+}
+
+// Create a rule. previous is the stopped rule it replaces after an edit, or
+// nil.
 func NewRule(
 	status *status.Status,
+	clk clock.Clock,
 	actionManager *actions.Manager,
 	name string,
-	ruleBody *status.Status) (*Rule, error) {
+	ruleBody *status.Status,
+	observer Observer,
+	previous *Rule) (*Rule, error) {
 
 	// Find the sub-expression contents.
 	conditionBody, _, e := ruleBody.GetSubStatus("status://condition")
@@ -31,60 +101,270 @@ func NewRule(
 		return nil, fmt.Errorf("No 'condition' section.")
 	}
 
-	actionOn, _, _ := ruleBody.GetSubStatus("status://on")
-	actionOff, _, _ := ruleBody.GetSubStatus("status://off")
+	actionOn, actionOff, defaults, e := parseActionsAndControl(ruleBody)
+	if e != nil {
+		return nil, e
+	}
 
-	if actionOn == nil && actionOff == nil {
-		return nil, fmt.Errorf("No On or Off action.")
+	fireOnReload := true
+	if raw, _, e := ruleBody.Get("status://fire_on_reload"); e == nil {
+		var ok bool
+		if fireOnReload, ok = raw.(bool); !ok {
+			return nil, fmt.Errorf("'fire_on_reload' must be true or false: %#v", raw)
+		}
 	}
 
+	conditionValue, _, _ := conditionBody.Get("status://")
+
 	// Create the condition (last, because it needs Stopping on failure).
-	condition, e := conditions.NewCondition(status, conditionBody)
+	condition, e := conditions.NewCondition(status, clk, conditionBody)
 	if e != nil {
 		return nil, e
 	}
 
 	result := &Rule{
 		status,
+		clk,
 		actionManager,
 		name,
 		condition,
+		conditionValue,
 		actionOn,
 		actionOff,
+		observer,
+		0,
+		defaults,
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
+	if previous != nil && !fireOnReload {
+		result.last = previous.last
+		result.reloaded = true
+	}
+
 	result.start()
 	return result, nil
 }
 
+// Read the parts of a rule body which can be changed without recreating the
+// rule.
+func parseActionsAndControl(ruleBody *status.Status) (actionOn, actionOff *status.Status, defaults control, e error) {
+	actionOn, _, _ = ruleBody.GetSubStatus("status://on")
+	actionOff, _, _ = ruleBody.GetSubStatus("status://off")
+
+	if actionOn == nil && actionOff == nil {
+		return nil, nil, control{}, fmt.Errorf("No On or Off action.")
+	}
+
+	defaults, e = parseControl(ruleBody, control{})
+	return actionOn, actionOff, defaults, e
+}
+
+// Apply an edited rule body. If the condition is unchanged, it keeps running
+// (with any timers it has) and only the actions and controls are updated.
+// Returns false if the rule must be recreated instead.
+func (r *Rule) Update(ruleBody *status.Status) bool {
+	conditionValue, _, e := ruleBody.Get("status://condition")
+	if e != nil || !reflect.DeepEqual(conditionValue, r.conditionBody) {
+		return false
+	}
+
+	actionOn, actionOff, defaults, e := parseActionsAndControl(ruleBody)
+	if e != nil {
+		// Recreating the rule reports the error.
+		return false
+	}
+
+	log.Printf("Update rule: %s", r.name)
+
+	r.lock.Lock()
+	r.actionOn, r.actionOff, r.defaults = actionOn, actionOff, defaults
+	r.lock.Unlock()
+
+	// Apply our control values to the new defaults.
+	matches, _ := r.status.GetMatchingUrls(ControlUrl(r.name))
+	r.updateControl(matches)
+
+	return true
+}
+
 func (r *Rule) start() {
 	log.Printf("Start rule: %s", r.name) // url)
+
+	// Apply our control values before the first condition result arrives.
+	var e error
+	if r.controlChan, e = r.status.WatchForUpdate(ControlUrl(r.name)); e != nil {
+		log.Panic(e) // Rule names come from valid URLs.
+	}
+	r.updateControl(<-r.controlChan)
+	clock.Handled(r.clock)
+
+	// Nothing is known until the condition sends it's first result.
+	r.publishState(map[string]interface{}{
+		"condition":  nil,
+		"fire_count": 0,
+	})
+
 	go r.Handler()
 
 }
 
+// Where this rule's runtime state is published.
+func (r *Rule) stateUrl() string {
+	return options.ENGINE + "/rules/" + r.name
+}
+
+// Update values in our runtime state.
+func (r *Rule) publishState(values map[string]interface{}) {
+	e := r.status.UpdateFrom(options.ENGINE_ORIGIN, func(tx *status.Tx) error {
+		for name, value := range values {
+			if e := tx.Set(r.stateUrl()+"/"+name, value, status.UNCHECKED_REVISION); e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+
+	if e != nil {
+		log.Printf("Rule (%s) state update failed: %s", r.name, e.Error())
+	}
+}
+
+// Apply new control values. Invalid values are logged, and ignored.
+func (r *Rule) updateControl(matches status.UrlMatches) {
+	body := &status.Status{}
+	for _, match := range matches {
+		if e := body.Set("status://", match.Value, 0); e != nil {
+			log.Panic(e) // This is supposed to be impossible.
+		}
+	}
+
+	r.lock.Lock()
+	c, e := parseControl(body, r.defaults)
+	if e == nil {
+		r.control = c
+	}
+	r.lock.Unlock()
+
+	if e != nil {
+		log.Printf("Rule (%s) control: %s", r.name, e.Error())
+		return
+	}
+
+	var snoozeUntil interface{}
+	if !c.snoozeUntil.IsZero() {
+		snoozeUntil = options.FormatTime(r.status, c.snoozeUntil)
+	}
+
+	r.publishState(map[string]interface{}{
+		"enabled":      !c.disabled,
+		"snooze_until": snoozeUntil,
+	})
+}
+
+// Handle a new condition value. Our action is fired, unless we are disabled
+// or snoozed, or the value only comes from reloading the rule.
+func (r *Rule) update(condValue bool) {
+	state := map[string]interface{}{
+		"condition":   condValue,
+		"last_change": options.FormatTime(r.status, r.clock.Now()),
+	}
+
+	unchanged := r.reloaded && r.last != nil && *r.last == condValue
+	r.reloaded = false
+	r.last = &condValue
+
+	if unchanged {
+		log.Println("Rule reloaded, not firing: ", r.name)
+		r.publishState(state)
+		return
+	}
+
+	r.lock.Lock()
+	blocked := r.control.blocks(r.clock.Now())
+	r.lock.Unlock()
+
+	if blocked {
+		log.Println("Rule disabled or snoozed, not firing: ", r.name)
+		r.publishState(state)
+		return
+	}
+
+	r.fire(condValue, state)
+}
+
+// Fire our on or off action, if we have one, and publish the result along
+// with any other state values.
+func (r *Rule) fire(on bool, state map[string]interface{}) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	action, lastFired := r.actionOn, "last_on"
+	if on {
+		if action != nil {
+			log.Println("Firing rule On: ", r.name)
+		}
+	} else {
+		action, lastFired = r.actionOff, "last_off"
+		if action != nil {
+			log.Println("Firing rule Off: ", r.name)
+		}
+	}
+
+	if action == nil {
+		r.publishState(state)
+		return fmt.Errorf("Rule (%s): No %s action.", r.name, lastFired[len("last_"):])
+	}
+
+	r.fireCount += 1
+	state[lastFired] = options.FormatTime(r.status, r.clock.Now())
+	state["fire_count"] = r.fireCount
+
+	e := r.actionManager.FireAction(r.status, "rule:"+r.name, action)
+	if e != nil {
+		state["last_error"] = e.Error()
+	}
+
+	r.publishState(state)
+	return e
+}
+
+// Fire our on or off action now, even if we are disabled or snoozed.
+func (r *Rule) Fire(on bool) error {
+	return r.fire(on, map[string]interface{}{})
+}
+
+// Describe the current state of our condition, and it's inner conditions.
+func (r *Rule) Explain() conditions.Explanation {
+	return r.condition.Explain()
+}
+
 func (r *Rule) Handler() {
 	for {
 		select {
 		case condValue := <-r.condition.Result():
-
-			if condValue {
-				if r.actionOn != nil {
-					log.Println("Firing rule On: ", r.name)
-					r.actionManager.FireAction(r.status, r.actionOn)
-				}
-			} else {
-				if r.actionOff != nil {
-					log.Println("Firing rule Off: ", r.name)
-					r.actionManager.FireAction(r.status, r.actionOff)
-				}
+			if r.observer != nil {
+				r.observer(r.name, condValue)
 			}
 
+			r.update(condValue)
+
+		case matches := <-r.controlChan:
+			r.updateControl(matches)
+
 		case <-r.StopChan:
 			r.condition.Stop()
+			r.status.ReleaseWatch(r.controlChan)
+			r.status.RemoveFrom(options.ENGINE_ORIGIN, r.stateUrl(), status.UNCHECKED_REVISION)
 			log.Printf("Stop rule: %s", r.name) // url)
 			r.StopChan <- true
 			return
 		}
+
+		clock.Handled(r.clock)
 	}
 }
@@ -2,11 +2,15 @@ package rules
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine/actions"
+	"github.com/DonGar/go-house/engine/conditions"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"gopkg.in/check.v1"
+	"sync"
 	"testing"
+	"time"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -30,17 +34,17 @@ type mockActions struct {
 	actionErrorBody *status.Status
 }
 
-func (m *mockActions) actionOn(s *status.Status, action *status.Status) (e error) {
+func (m *mockActions) actionOn(s *status.Status, origin string, action *status.Status) (e error) {
 	m.onCount += 1
 	return nil
 }
 
-func (m *mockActions) actionOff(s *status.Status, action *status.Status) (e error) {
+func (m *mockActions) actionOff(s *status.Status, origin string, action *status.Status) (e error) {
 	m.offCount += 1
 	return nil
 }
 
-func (m *mockActions) actionError(s *status.Status, action *status.Status) (e error) {
+func (m *mockActions) actionError(s *status.Status, origin string, action *status.Status) (e error) {
 	m.errorCount += 1
 	return fmt.Errorf("Mock Error")
 }
@@ -86,6 +90,10 @@ func (m *mockCondition) Result() <-chan bool {
 func (m *mockCondition) Stop() {
 }
 
+func (m *mockCondition) Explain() conditions.Explanation {
+	return conditions.Explanation{Type: "mock"}
+}
+
 // Tests
 
 func (suite *MySuite) TestRuleStartStop(c *check.C) {
@@ -104,7 +112,7 @@ func (suite *MySuite) TestRuleStartStop(c *check.C) {
 		status.UNCHECKED_REVISION)
 	c.Assert(e, check.IsNil)
 
-	rule, e := NewRule(mockStatus, mockActions.registrar, "Test Rule", ruleBody)
+	rule, e := NewRule(mockStatus, clock.NewReal(), mockActions.registrar, "Test Rule", ruleBody, nil, nil)
 	c.Assert(e, check.IsNil)
 
 	rule.Stop()
@@ -119,11 +127,21 @@ func (suite *MySuite) TestRuleOnFireSingle(c *check.C) {
 
 	rule := &Rule{
 		s,
+		clock.NewReal(),
 		mockActions.registrar,
 		"Test Rule Single",
 		mockCondition,
+		nil,
 		mockActions.actionOnBody,
 		mockActions.actionOffBody,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
 	rule.start()
@@ -143,11 +161,21 @@ func (suite *MySuite) TestRuleOnOffFireRepeated(c *check.C) {
 
 	rule := &Rule{
 		s,
+		clock.NewReal(),
 		mockActions.registrar,
 		"Test Rule Repeated",
 		mockCondition,
+		nil,
 		mockActions.actionOnBody,
 		mockActions.actionOffBody,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
 	rule.start()
@@ -171,11 +199,21 @@ func (suite *MySuite) TestRuleOnActionOnly(c *check.C) {
 
 	rule := &Rule{
 		s,
+		clock.NewReal(),
 		mockActions.registrar,
 		"Test Rule OnActionOnly",
 		mockCondition,
+		nil,
 		mockActions.actionOnBody,
 		nil,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
 	rule.start()
@@ -199,11 +237,21 @@ func (suite *MySuite) TestRuleOffActionOnly(c *check.C) {
 
 	rule := &Rule{
 		s,
+		clock.NewReal(),
 		mockActions.registrar,
 		"Test Rule OffActionOnly",
 		mockCondition,
 		nil,
+		nil,
 		mockActions.actionOffBody,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
 	rule.start()
@@ -227,11 +275,21 @@ func (suite *MySuite) TestRuleFireError(c *check.C) {
 
 	rule := &Rule{
 		s,
+		clock.NewReal(),
 		mockActions.registrar,
 		"Test Rule Error",
 		mockCondition,
+		nil,
 		mockActions.actionErrorBody,
 		nil,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
 		stoppable.NewBase()}
 
 	rule.start()
@@ -246,3 +304,304 @@ func (suite *MySuite) TestRuleFireError(c *check.C) {
 
 	mockActions.verify(c, 0, 0, 2)
 }
+
+// Wait for a rule's published fire count to reach count.
+func waitForFireCount(c *check.C, s *status.Status, name string, count int) {
+	url := "status://engine/rules/" + name + "/fire_count"
+	for i := 0; i < 100 && s.GetIntWithDefault(url, -1) != count; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(s.GetIntWithDefault(url, -1), check.Equals, count)
+}
+
+func (suite *MySuite) TestRuleState(c *check.C) {
+	s := &status.Status{}
+	mockActions := newMockActions()
+	mockCondition := &mockCondition{make(chan bool)}
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	rule := &Rule{
+		s,
+		clk,
+		mockActions.registrar,
+		"state",
+		mockCondition,
+		nil,
+		mockActions.actionOnBody,
+		mockActions.actionErrorBody,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
+		stoppable.NewBase()}
+
+	rule.start()
+
+	state, _, e := s.Get("status://engine/rules/state")
+	c.Check(e, check.IsNil)
+	c.Check(state, check.DeepEquals, map[string]interface{}{
+		"condition":    nil,
+		"fire_count":   0,
+		"enabled":      true,
+		"snooze_until": nil,
+	})
+
+	mockCondition.result <- true
+	waitForFireCount(c, s, "state", 1)
+
+	state, _, e = s.Get("status://engine/rules/state")
+	c.Check(e, check.IsNil)
+	c.Check(state, check.DeepEquals, map[string]interface{}{
+		"condition":    true,
+		"fire_count":   1,
+		"last_change":  "2014-06-01T12:00:00Z",
+		"last_on":      "2014-06-01T12:00:00Z",
+		"enabled":      true,
+		"snooze_until": nil,
+	})
+
+	clk.Advance(time.Hour)
+	mockCondition.result <- false
+	waitForFireCount(c, s, "state", 2)
+
+	state, _, e = s.Get("status://engine/rules/state")
+	c.Check(e, check.IsNil)
+	c.Check(state, check.DeepEquals, map[string]interface{}{
+		"condition":    false,
+		"fire_count":   2,
+		"last_change":  "2014-06-01T13:00:00Z",
+		"last_on":      "2014-06-01T12:00:00Z",
+		"last_off":     "2014-06-01T13:00:00Z",
+		"last_error":   "Mock Error",
+		"enabled":      true,
+		"snooze_until": nil,
+	})
+
+	// The state goes away with the rule.
+	rule.Stop()
+
+	_, _, e = s.Get("status://engine/rules/state")
+	c.Check(e, check.NotNil)
+}
+
+// Wait for a value in a rule's published state.
+func waitForState(c *check.C, s *status.Status, name, field string, expected interface{}) {
+	url := "status://engine/rules/" + name + "/" + field
+	for i := 0; i < 100; i++ {
+		if value, _, e := s.Get(url); e == nil && value == expected {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	value, _, e := s.Get(url)
+	c.Assert(e, check.IsNil)
+	c.Assert(value, check.Equals, expected)
+}
+
+func (suite *MySuite) TestRuleControlParsing(c *check.C) {
+	body := &status.Status{}
+	e := body.SetJson("status://", []byte(`{"enabled": false, "snooze_until": "2014-06-01T12:00:00Z"}`), 0)
+	c.Assert(e, check.IsNil)
+
+	result, e := parseControl(body, control{})
+	c.Check(e, check.IsNil)
+	c.Check(result, check.Equals, control{true, time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)})
+
+	// Missing values come from the defaults, and null clears a snooze.
+	body = &status.Status{}
+	e = body.SetJson("status://", []byte(`{"snooze_until": null}`), 0)
+	c.Assert(e, check.IsNil)
+
+	result, e = parseControl(body, control{true, time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)})
+	c.Check(e, check.IsNil)
+	c.Check(result, check.Equals, control{true, time.Time{}})
+
+	bad := []string{
+		`{"enabled": "yes"}`,
+		`{"snooze_until": "tomorrow"}`,
+		`{"snooze_until": 12}`,
+	}
+
+	for _, b := range bad {
+		body = &status.Status{}
+		c.Assert(body.SetJson("status://", []byte(b), 0), check.IsNil)
+
+		_, e = parseControl(body, control{})
+		c.Check(e, check.NotNil)
+	}
+}
+
+func (suite *MySuite) TestRuleControl(c *check.C) {
+	s := &status.Status{}
+	mockActions := newMockActions()
+	mockCondition := &mockCondition{make(chan bool)}
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	// Disabled in the rule body.
+	rule := &Rule{
+		s,
+		clk,
+		mockActions.registrar,
+		"control",
+		mockCondition,
+		nil,
+		mockActions.actionOnBody,
+		mockActions.actionOffBody,
+		nil,
+		0,
+		control{disabled: true},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
+		stoppable.NewBase()}
+
+	rule.start()
+	waitForState(c, s, "control", "enabled", false)
+
+	mockCondition.result <- true
+	waitForState(c, s, "control", "condition", true)
+	waitForFireCount(c, s, "control", 0)
+
+	// Enabled by a control value.
+	e := s.Set("status://control/rules/control/enabled", true, status.UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	waitForState(c, s, "control", "enabled", true)
+
+	mockCondition.result <- false
+	waitForFireCount(c, s, "control", 1)
+
+	// Snoozed for an hour.
+	e = s.Set("status://control/rules/control/snooze_until", "2014-06-01T13:00:00Z", status.UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	waitForState(c, s, "control", "snooze_until", "2014-06-01T13:00:00Z")
+
+	mockCondition.result <- true
+	waitForState(c, s, "control", "condition", true)
+	waitForFireCount(c, s, "control", 1)
+
+	// The snooze ends.
+	clk.Advance(time.Hour)
+	mockCondition.result <- false
+	waitForFireCount(c, s, "control", 2)
+
+	// Manual firing ignores the controls.
+	e = s.Set("status://control/rules/control/enabled", false, status.UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	waitForState(c, s, "control", "enabled", false)
+
+	c.Check(rule.Fire(true), check.IsNil)
+	waitForFireCount(c, s, "control", 3)
+
+	rule.Stop()
+
+	mockActions.verify(c, 1, 2, 0)
+}
+
+func (suite *MySuite) TestRuleFireMissingAction(c *check.C) {
+	s := &status.Status{}
+	mockActions := newMockActions()
+	mockCondition := &mockCondition{make(chan bool)}
+
+	rule := &Rule{
+		s,
+		clock.NewReal(),
+		mockActions.registrar,
+		"missing",
+		mockCondition,
+		nil,
+		mockActions.actionOnBody,
+		nil,
+		nil,
+		0,
+		control{},
+		control{},
+		nil,
+		nil,
+		false,
+		sync.Mutex{},
+		stoppable.NewBase()}
+
+	rule.start()
+
+	c.Check(rule.Fire(false), check.NotNil)
+	c.Check(rule.Fire(true), check.IsNil)
+
+	rule.Stop()
+
+	mockActions.verify(c, 1, 0, 0)
+}
+
+func newRuleBody(c *check.C, bodyJson string) *status.Status {
+	body := &status.Status{}
+	c.Assert(body.SetJson("status://", []byte(bodyJson), status.UNCHECKED_REVISION), check.IsNil)
+	return body
+}
+
+func (suite *MySuite) TestRuleUpdate(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set("status://motion", false, 0), check.IsNil)
+	mockActions := newMockActions()
+
+	condition := `"condition": {"test": "compare", "watch": "status://motion", "op": "==", "value": true}`
+
+	rule, e := NewRule(s, clock.NewReal(), mockActions.registrar, "update",
+		newRuleBody(c, `{`+condition+`, "on": {"action": "on"}}`), nil, nil)
+	c.Assert(e, check.IsNil)
+	waitForState(c, s, "update", "condition", false)
+
+	// Only the action changed, so it's updated in place.
+	c.Check(rule.Update(newRuleBody(c, `{`+condition+`, "on": {"action": "off"}}`)), check.Equals, true)
+
+	c.Assert(s.Set("status://motion", true, status.UNCHECKED_REVISION), check.IsNil)
+	waitForFireCount(c, s, "update", 1)
+
+	// Controls are updated too.
+	c.Check(rule.Update(newRuleBody(c, `{`+condition+`, "on": {"action": "off"}, "enabled": false}`)), check.Equals, true)
+	waitForState(c, s, "update", "enabled", false)
+
+	// Invalid bodies, and new conditions, need the rule to be recreated.
+	c.Check(rule.Update(newRuleBody(c, `{`+condition+`}`)), check.Equals, false)
+	c.Check(rule.Update(newRuleBody(c, `{"condition": {"test": "true"}, "on": {"action": "on"}}`)), check.Equals, false)
+
+	rule.Stop()
+
+	mockActions.verify(c, 0, 1, 0)
+}
+
+func (suite *MySuite) TestRuleReload(c *check.C) {
+	s := &status.Status{}
+	mockActions := newMockActions()
+
+	newRule := func(previous *Rule, bodyJson string) *Rule {
+		rule, e := NewRule(s, clock.NewReal(), mockActions.registrar, "reload", newRuleBody(c, bodyJson), nil, previous)
+		c.Assert(e, check.IsNil)
+		waitForState(c, s, "reload", "condition", true)
+		return rule
+	}
+
+	first := newRule(nil, `{"condition": {"test": "true"}, "on": {"action": "on"}}`)
+	first.Stop()
+
+	// The reloaded rule has the same value, so doesn't fire.
+	second := newRule(first, `{"condition": {"test": "true"}, "on": {"action": "on"}, "fire_on_reload": false}`)
+	second.Stop()
+
+	// Unless asked to.
+	third := newRule(second, `{"condition": {"test": "true"}, "on": {"action": "on"}}`)
+	third.Stop()
+
+	mockActions.verify(c, 2, 0, 0)
+
+	// Invalid values are rejected.
+	body := newRuleBody(c, `{"condition": {"test": "true"}, "on": {"action": "on"}, "fire_on_reload": "no"}`)
+	_, e := NewRule(s, clock.NewReal(), mockActions.registrar, "reload", body, nil, nil)
+	c.Check(e, check.NotNil)
+}
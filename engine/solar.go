@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/engine/conditions"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"github.com/DonGar/go-house/stoppable"
+	"log"
+	"time"
+)
+
+// Keeps today's solar times (sunrise, civil_dusk, etc) up to date in
+// status://server/solar.
+type solarPublisher struct {
+	status *status.Status
+	clock  clock.Clock
+	stoppable.Base
+}
+
+func newSolarPublisher(s *status.Status, clk clock.Clock) *solarPublisher {
+	p := &solarPublisher{s, clk, stoppable.NewBase()}
+
+	// Starting counts as an event, until the timer is created.
+	clock.Sent(clk)
+	go p.Handler()
+
+	return p
+}
+
+// Write the solar times for the day containing now.
+func (p *solarPublisher) publish(now time.Time) {
+	latitude := p.status.GetFloatWithDefault("status://server/latitude", 0.0)
+	longitude := p.status.GetFloatWithDefault("status://server/longitude", 0.0)
+
+	value := map[string]interface{}{}
+	for name, t := range conditions.SolarTimes(now, latitude, longitude) {
+		value[name] = t.Format(time.RFC3339)
+	}
+
+	e := p.status.SetFrom("solar", options.SOLAR, value, status.UNCHECKED_REVISION)
+	if e != nil {
+		log.Println("Solar: ", e)
+	}
+}
+
+func (p *solarPublisher) Handler() {
+	timer := p.clock.NewTimer(0)
+	clock.Handled(p.clock)
+
+	for {
+		select {
+		case <-timer.C:
+			loc, e := options.Location(p.status)
+			if e != nil {
+				log.Println("Solar: ", e)
+				loc = time.Local
+			}
+
+			now := p.clock.Now().In(loc)
+			p.publish(now)
+
+			// Update again just after midnight.
+			year, month, day := now.Date()
+			tomorrow := time.Date(year, month, day+1, 0, 0, 1, 0, now.Location())
+			timer.Reset(tomorrow.Sub(now))
+			clock.Handled(p.clock)
+
+		case <-p.StopChan:
+			timer.Stop()
+			p.StopChan <- true
+			return
+		}
+	}
+}
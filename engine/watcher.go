@@ -1,12 +1,24 @@
 package engine
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
 	"log"
+	"sync"
 )
 
-type newWatch func(url string, body *status.Status) (stoppable.Stoppable, error)
+// Create a new value from it's body. previous is the stopped value it
+// replaces, or nil.
+type newWatch func(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error)
+
+// Implemented by values which can apply some edits to their body, without
+// being recreated.
+type updatable interface {
+	// Apply a new body, if possible. Returns false if the value must be
+	// recreated instead.
+	Update(body *status.Status) bool
+}
 
 type watched struct {
 	revision int
@@ -15,15 +27,19 @@ type watched struct {
 
 type watcher struct {
 	status  *status.Status
+	clock   clock.Clock
 	url     string
 	factory newWatch
+	lock    sync.Mutex // Protects active.
 	active  map[string]watched
 	stoppable.Base
 }
 
-func newWatcher(status *status.Status, url string, factory newWatch) *watcher {
-	result := &watcher{status, url, factory, map[string]watched{}, stoppable.NewBase()}
+func newWatcher(status *status.Status, clk clock.Clock, url string, factory newWatch) *watcher {
+	result := &watcher{status, clk, url, factory, sync.Mutex{}, map[string]watched{}, stoppable.NewBase()}
 
+	// Starting counts as an event, until the watch is created.
+	clock.Sent(clk)
 	go result.Handler()
 
 	return result
@@ -35,6 +51,7 @@ func (w *watcher) Handler() {
 	if e != nil {
 		panic("Failure watching: " + w.url)
 	}
+	clock.Handled(w.clock)
 
 	for {
 		select {
@@ -48,19 +65,38 @@ func (w *watcher) Handler() {
 			w.StopChan <- true
 			return
 		}
+
+		clock.Handled(w.clock)
 	}
 }
 
 // Remove any rules that have been removed, or updated.
 func (w *watcher) update(matches status.UrlMatches) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// Values which were stopped to be recreated.
+	replaced := map[string]stoppable.Stoppable{}
 
 	// Remove all rules that no longer exist, or which have been updated.
 	for url, active := range w.active {
 		match, ok := matches[url]
-		if !ok || match.Revision != active.revision {
-			// It's no longer valid, remove it.
-			active.value.Stop()
-			delete(w.active, url)
+		if ok && match.Revision == active.revision {
+			continue
+		}
+
+		// Try to update it in place.
+		if u, isUpdatable := active.value.(updatable); ok && isUpdatable && u.Update(bodyOf(match)) {
+			w.active[url] = watched{match.Revision, active.value}
+			continue
+		}
+
+		// It's no longer valid, remove it.
+		active.value.Stop()
+		delete(w.active, url)
+
+		if ok {
+			replaced[url] = active.value
 		}
 	}
 
@@ -71,15 +107,8 @@ func (w *watcher) update(matches status.UrlMatches) {
 			continue
 		}
 
-		// Find it's body.
-		activeBody := &status.Status{}
-		e := activeBody.Set("status://", match.Value, 0)
-		if e != nil {
-			log.Panic(e) // This is supposed to be impossible.
-		}
-
 		// Create it.
-		active, e := w.factory(url, activeBody)
+		active, e := w.factory(url, bodyOf(match), replaced[url])
 		if e != nil {
 			// Skip over invalid actives.
 			log.Printf("INVALID: %s: %s", url, e.Error())
@@ -88,3 +117,26 @@ func (w *watcher) update(matches status.UrlMatches) {
 		w.active[url] = watched{match.Revision, active}
 	}
 }
+
+// Find the body of a match.
+func bodyOf(match status.UrlMatch) *status.Status {
+	body := &status.Status{}
+	if e := body.Set("status://", match.Value, 0); e != nil {
+		log.Panic(e) // This is supposed to be impossible.
+	}
+	return body
+}
+
+// Find an active value by name (the last part of it's URL).
+func (w *watcher) find(name string) (stoppable.Stoppable, bool) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for url, active := range w.active {
+		if nameFromUrl(url) == name {
+			return active.value, true
+		}
+	}
+
+	return nil, false
+}
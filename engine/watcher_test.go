@@ -1,8 +1,10 @@
 package engine
 
 import (
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/status"
 	"github.com/DonGar/go-house/stoppable"
+	"github.com/DonGar/go-house/wait"
 	"gopkg.in/check.v1"
 	"time"
 )
@@ -10,24 +12,32 @@ import (
 type mockWatchedItem struct {
 }
 
-func newWatched(url string, body *status.Status) (stoppable.Stoppable, error) {
+func newWatched(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error) {
 	return &mockWatchedItem{}, nil
 }
 
 func (m *mockWatchedItem) Stop() {
 }
 
+// The number of active values, read under the watcher's lock.
+func activeCount(w *watcher) int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return len(w.active)
+}
+
 func (suite *MySuite) TestWatcherStartStopEmpty(c *check.C) {
 	// Setup a couple of base adapters and verify their contents.
 	s := &status.Status{}
 
-	factoryAssert := func(url string, body *status.Status) (stoppable.Stoppable, error) {
+	factoryAssert := func(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error) {
 		c.Error("Unexpected factory call: ", url)
 		return nil, nil
 	}
 
 	// Create the watcher.
-	watcher := newWatcher(s, "status://*", factoryAssert)
+	watcher := newWatcher(s, clock.NewReal(), "status://*", factoryAssert)
 
 	// We give the watcher a little time for a delayed update.
 	time.Sleep(1 * time.Millisecond)
@@ -36,58 +46,119 @@ func (suite *MySuite) TestWatcherStartStopEmpty(c *check.C) {
 	watcher.Stop()
 
 	// We verify that there are no active.
-	c.Check(len(watcher.active), check.Equals, 0)
+	c.Check(activeCount(watcher), check.Equals, 0)
 }
 
 func (suite *MySuite) TestWatcherAddRule(c *check.C) {
 	// Setup a couple of base adapters and verify their contents.
 	s := &status.Status{}
 
-	watcher := newWatcher(s, "status://active/*", newWatched)
+	watcher := newWatcher(s, clock.NewReal(), "status://active/*", newWatched)
 
 	// We give the watcher a little time for a delayed update.
 	time.Sleep(1 * time.Millisecond)
-	c.Check(len(watcher.active), check.Equals, 0)
+	c.Check(activeCount(watcher), check.Equals, 0)
 
 	// Force add a new rule.
 	s.Set("status://active/Test", "active value", status.UNCHECKED_REVISION)
 	time.Sleep(1 * time.Millisecond)
-	c.Check(len(watcher.active), check.Equals, 1)
+	c.Check(activeCount(watcher), check.Equals, 1)
 
 	// Stop it.
 	watcher.Stop()
 
 	// We verify that there are no rules.
-	c.Check(len(watcher.active), check.Equals, 0)
+	c.Check(activeCount(watcher), check.Equals, 0)
 }
 
 func (suite *MySuite) TestMgrStartEditStop(c *check.C) {
 	// Setup a couple of base adapters and verify their contents.
 	s := setupTestStatus(c)
 
-	watcher := newWatcher(s, "status://*/rule/*", newWatched)
+	watcher := newWatcher(s, clock.NewReal(), "status://*/rule/*", newWatched)
 
 	// We give the watcher a little time for a delayed update.
 	time.Sleep(1 * time.Millisecond)
-	c.Check(len(watcher.active), check.Equals, 2)
+	c.Check(activeCount(watcher), check.Equals, 2)
 
 	e := s.Set("status://testAdapter/rule/RuleThree", "Booga", 1)
 	c.Assert(e, check.IsNil)
 
 	// We give the watcher a little time to finish initializing.
 	time.Sleep(1 * time.Millisecond)
-	c.Check(len(watcher.active), check.Equals, 3)
+	c.Check(activeCount(watcher), check.Equals, 3)
 
 	e = s.Remove("status://testAdapter/rule/RuleTwo", 2)
 	c.Assert(e, check.IsNil)
 
 	// We give the watcher a little time for a delayed update.
 	time.Sleep(1 * time.Millisecond)
-	c.Check(len(watcher.active), check.Equals, 2)
+	c.Check(activeCount(watcher), check.Equals, 2)
 
 	// Stop it.
 	watcher.Stop()
 
 	// We verify that there are no rules.
-	c.Check(len(watcher.active), check.Equals, 0)
+	c.Check(activeCount(watcher), check.Equals, 0)
+}
+
+// A mock which can update it's body in place, unless the new body is "new".
+type mockUpdatableItem struct {
+	body     interface{}
+	previous stoppable.Stoppable
+}
+
+func (m *mockUpdatableItem) Update(body *status.Status) bool {
+	value, _, _ := body.Get("status://")
+	if value == "new" {
+		return false
+	}
+
+	m.body = value
+	return true
+}
+
+func (m *mockUpdatableItem) Stop() {
+}
+
+func (suite *MySuite) TestWatcherUpdate(c *check.C) {
+	s := &status.Status{}
+
+	created := make(chan *mockUpdatableItem, 10)
+	factory := func(url string, body *status.Status, previous stoppable.Stoppable) (stoppable.Stoppable, error) {
+		value, _, _ := body.Get("status://")
+		item := &mockUpdatableItem{value, previous}
+		created <- item
+		return item, nil
+	}
+
+	watcher := newWatcher(s, clock.NewReal(), "status://active/*", factory)
+
+	c.Assert(s.Set("status://active/Test", "first", status.UNCHECKED_REVISION), check.IsNil)
+	first := <-created
+	c.Check(first.previous, check.IsNil)
+
+	// Updated in place.
+	c.Assert(s.Set("status://active/Test", "second", status.UNCHECKED_REVISION), check.IsNil)
+
+	updated := func() bool {
+		watcher.lock.Lock()
+		defer watcher.lock.Unlock()
+		return first.body == "second"
+	}
+	wait.Wait(100*time.Millisecond, updated)
+	c.Check(updated(), check.Equals, true)
+
+	item, ok := watcher.find("Test")
+	c.Check(ok, check.Equals, true)
+	c.Check(item, check.Equals, first)
+
+	// Recreated.
+	c.Assert(s.Set("status://active/Test", "new", status.UNCHECKED_REVISION), check.IsNil)
+	replacement := <-created
+	c.Check(replacement.previous, check.Equals, first)
+
+	watcher.Stop()
+
+	c.Check(len(created), check.Equals, 0)
 }
@@ -0,0 +1,496 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What an expression is evaluated against.
+type Env struct {
+	Status *status.Status
+	Clock  clock.Clock
+}
+
+// Evaluate an expression. Times are returned as RFC3339 strings, and
+// durations as strings like "1h30m0s", so the result can be stored in status.
+func (x *Expr) Eval(env Env) (interface{}, error) {
+	value, e := x.root.eval(env)
+	if e != nil {
+		return nil, e
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return options.FormatTime(env.Status, v), nil
+	case time.Duration:
+		return v.String(), nil
+	default:
+		return value, nil
+	}
+}
+
+// Evaluate an expression which must be true or false. null is false.
+func (x *Expr) EvalBool(env Env) (bool, error) {
+	value, e := x.root.eval(env)
+	if e != nil {
+		return false, e
+	}
+
+	return truth(value)
+}
+
+type node interface {
+	eval(env Env) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env Env) (interface{}, error) {
+	return n.value, nil
+}
+
+// Read a status value.
+type refNode struct {
+	url string
+}
+
+func (n *refNode) eval(env Env) (interface{}, error) {
+	value, _, e := env.Status.Get(n.url)
+	if e != nil {
+		return nil, nil
+	}
+	return value, nil
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+func (n *unaryNode) eval(env Env) (interface{}, error) {
+	value, e := n.operand.eval(env)
+	if e != nil {
+		return nil, e
+	}
+
+	if n.op == "!" {
+		b, e := truth(value)
+		return !b, e
+	}
+
+	if d, ok := value.(time.Duration); ok {
+		return -d, nil
+	}
+	if f, ok := number(value); ok {
+		return -f, nil
+	}
+	return nil, fmt.Errorf("Expr: Can't negate %s.", format(value))
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+func (n *binaryNode) eval(env Env) (interface{}, error) {
+	left, e := n.left.eval(env)
+	if e != nil {
+		return nil, e
+	}
+
+	// && and || only evaluate the right side if needed.
+	if n.op == "&&" || n.op == "||" {
+		l, e := truth(left)
+		if e != nil || l == (n.op == "||") {
+			return l, e
+		}
+
+		right, e := n.right.eval(env)
+		if e != nil {
+			return nil, e
+		}
+		return truth(right)
+	}
+
+	right, e := n.right.eval(env)
+	if e != nil {
+		return nil, e
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		return compare(n.op, left, right)
+	default:
+		return arithmetic(n.op, left, right)
+	}
+}
+
+type callNode struct {
+	name string
+	f    function
+	args []node
+}
+
+func (n *callNode) eval(env Env) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		var e error
+		if args[i], e = arg.eval(env); e != nil {
+			return nil, e
+		}
+	}
+
+	return n.f.call(env, args)
+}
+
+//
+// Values
+//
+
+// Expressions work with nil, bool, float64, string, time.Time and
+// time.Duration, plus any lists or maps read from status.
+
+// Is a value true? null counts as false, so missing values are false.
+func truth(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	default:
+		return false, fmt.Errorf("Expr: %s is not true or false.", format(value))
+	}
+}
+
+// Convert a value to a number, if possible. Numeric strings (which some
+// adapters report) are converted too.
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		f, e := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, e == nil
+	default:
+		return 0, false
+	}
+}
+
+// Convert a value to a time. RFC3339 strings are converted.
+func toTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, e := time.Parse(time.RFC3339, v)
+		return t, e == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+// Convert a value to a duration. Strings like "30m" are converted.
+func toDuration(value interface{}) (time.Duration, bool) {
+	switch v := value.(type) {
+	case time.Duration:
+		return v, true
+	case string:
+		d, e := time.ParseDuration(v)
+		return d, e == nil
+	default:
+		return 0, false
+	}
+}
+
+// Describe a value, for string concatenation and errors.
+func format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	case time.Duration:
+		return v.String()
+	default:
+		valueJson, e := json.Marshal(v)
+		if e != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(valueJson)
+	}
+}
+
+// Find a pair of times or durations, if either value is one, and the other
+// can be converted.
+func timePair(left, right interface{}) (l, r time.Time, ok bool) {
+	_, lTime := left.(time.Time)
+	_, rTime := right.(time.Time)
+	if !lTime && !rTime {
+		return l, r, false
+	}
+
+	l, lOk := toTime(left)
+	r, rOk := toTime(right)
+	return l, r, lOk && rOk
+}
+
+func durationPair(left, right interface{}) (l, r time.Duration, ok bool) {
+	_, lDuration := left.(time.Duration)
+	_, rDuration := right.(time.Duration)
+	if !lDuration && !rDuration {
+		return l, r, false
+	}
+
+	l, lOk := toDuration(left)
+	r, rOk := toDuration(right)
+	return l, r, lOk && rOk
+}
+
+// Are two values equal? Numbers are compared by value, regardless of type,
+// but aren't equal to strings.
+func equal(left, right interface{}) bool {
+	if l, r, ok := timePair(left, right); ok {
+		return l.Equal(r)
+	}
+	if l, r, ok := durationPair(left, right); ok {
+		return l == r
+	}
+
+	_, leftString := left.(string)
+	_, rightString := right.(string)
+
+	if !leftString && !rightString {
+		l, lOk := number(left)
+		r, rOk := number(right)
+		if lOk && rOk {
+			return l == r
+		}
+	}
+
+	return reflect.DeepEqual(left, right)
+}
+
+// Order two times, durations, numbers or strings. A number and a numeric
+// string are compared as numbers.
+func compare(op string, left, right interface{}) (bool, error) {
+	var order int
+
+	lString, lIsString := left.(string)
+	rString, rIsString := right.(string)
+
+	if l, r, ok := timePair(left, right); ok {
+		order = int(l.Sub(r))
+	} else if l, r, ok := durationPair(left, right); ok {
+		order = int(l - r)
+	} else if lIsString && rIsString {
+		order = strings.Compare(lString, rString)
+	} else if l, r, ok := numberPair(left, right); ok {
+		switch {
+		case l < r:
+			order = -1
+		case l > r:
+			order = 1
+		}
+	} else {
+		return false, fmt.Errorf("Expr: Can't compare %s %s %s.", format(left), op, format(right))
+	}
+
+	switch op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+func numberPair(left, right interface{}) (l, r float64, ok bool) {
+	l, lOk := number(left)
+	r, rOk := number(right)
+	return l, r, lOk && rOk
+}
+
+// Apply +, -, *, / or %.
+func arithmetic(op string, left, right interface{}) (interface{}, error) {
+	if t, ok := left.(time.Time); ok && (op == "+" || op == "-") {
+		if d, ok := toDuration(right); ok {
+			if op == "-" {
+				d = -d
+			}
+			return t.Add(d), nil
+		}
+		if r, ok := toTime(right); ok && op == "-" {
+			return t.Sub(r), nil
+		}
+	}
+
+	if t, ok := right.(time.Time); ok && op == "+" {
+		if d, ok := toDuration(left); ok {
+			return t.Add(d), nil
+		}
+	}
+
+	if l, r, ok := durationPair(left, right); ok && (op == "+" || op == "-") {
+		if op == "-" {
+			r = -r
+		}
+		return l + r, nil
+	}
+
+	_, lIsString := left.(string)
+	_, rIsString := right.(string)
+	if op == "+" && (lIsString || rIsString) {
+		return format(left) + format(right), nil
+	}
+
+	l, r, ok := numberPair(left, right)
+	if !ok {
+		return nil, fmt.Errorf("Expr: Can't compute %s %s %s.", format(left), op, format(right))
+	}
+
+	switch op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	}
+
+	if r == 0 {
+		return nil, fmt.Errorf("Expr: Division by zero: %s %s %s.", format(left), op, format(right))
+	}
+
+	if op == "/" {
+		return l / r, nil
+	}
+	return math.Mod(l, r), nil
+}
+
+//
+// Functions
+//
+
+type function struct {
+	args      int
+	usesClock bool     // Does the result depend on the time?
+	urls      []string // Status URLs read.
+	call      func(env Env, args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"now": {0, true, nil, func(env Env, args []interface{}) (interface{}, error) {
+		return env.Clock.Now(), nil
+	}},
+
+	"sunrise": solarFunction("sunrise"),
+	"sunset":  solarFunction("sunset"),
+
+	// Today's time of day, like time("20:30").
+	"time": {1, true, nil, func(env Env, args []interface{}) (interface{}, error) {
+		str, _ := args[0].(string)
+		timeOfDay, e := time.Parse("15:04", str)
+		if e != nil {
+			return nil, fmt.Errorf("Expr: Invalid time of day: %s", format(args[0]))
+		}
+
+		loc, e := options.Location(env.Status)
+		if e != nil {
+			return nil, e
+		}
+
+		now := env.Clock.Now().In(loc)
+		year, month, day := now.Date()
+		return time.Date(year, month, day, timeOfDay.Hour(), timeOfDay.Minute(), 0, 0, loc), nil
+	}},
+
+	"duration": {1, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		d, ok := toDuration(args[0])
+		if !ok {
+			return nil, fmt.Errorf("Expr: Invalid duration: %s", format(args[0]))
+		}
+		return d, nil
+	}},
+
+	"number": {1, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		f, ok := number(args[0])
+		if !ok {
+			return nil, fmt.Errorf("Expr: Not a number: %s", format(args[0]))
+		}
+		return f, nil
+	}},
+
+	"contains":    stringFunction(strings.Contains),
+	"starts_with": stringFunction(strings.HasPrefix),
+	"ends_with":   stringFunction(strings.HasSuffix),
+
+	"lower": {1, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		return strings.ToLower(format(args[0])), nil
+	}},
+
+	"upper": {1, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		return strings.ToUpper(format(args[0])), nil
+	}},
+
+	"len": {1, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		switch v := args[0].(type) {
+		case string:
+			return float64(len(v)), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		default:
+			return nil, fmt.Errorf("Expr: Can't find the length of %s.", format(args[0]))
+		}
+	}},
+}
+
+// Today's time for a solar event, as published by the engine.
+func solarFunction(name string) function {
+	url := options.SOLAR + "/" + name
+	return function{0, false, []string{url}, func(env Env, args []interface{}) (interface{}, error) {
+		value, _, e := env.Status.Get(url)
+		if e != nil {
+			return nil, fmt.Errorf("Expr: No %s time.", name)
+		}
+
+		t, ok := toTime(value)
+		if !ok {
+			return nil, fmt.Errorf("Expr: Invalid %s time: %s", name, format(value))
+		}
+		return t, nil
+	}}
+}
+
+// A function testing two strings.
+func stringFunction(test func(s, substr string) bool) function {
+	return function{2, false, nil, func(env Env, args []interface{}) (interface{}, error) {
+		return test(format(args[0]), format(args[1])), nil
+	}}
+}
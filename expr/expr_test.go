@@ -0,0 +1,204 @@
+package expr
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"testing"
+	"time"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MySuite struct{}
+
+var _ = check.Suite(&MySuite{})
+
+func setupEnv(c *check.C) Env {
+	s := &status.Status{}
+	e := s.SetJson("status://", []byte(`
+		{
+			"server": {
+				"timezone": "UTC",
+				"solar": {
+					"sunrise": "2014-06-12T05:30:00Z",
+					"sunset": "2014-06-12T20:30:00Z"
+				}
+			},
+			"vera": {
+				"kitchen": {"temperature": 72, "name": "Kitchen Light"},
+				"device": {"12": {"level": "55"}}
+			},
+			"home": {"away": false, "list": [1, 2, 3]},
+			"engine": {"rules": {"porch": {"last_on": "2014-06-12T11:00:00Z"}}}
+		}`), 0)
+	c.Assert(e, check.IsNil)
+
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 12, 0, 0, 0, time.UTC))
+	return Env{s, clk}
+}
+
+func (suite *MySuite) TestParseUrls(c *check.C) {
+	x, e := Parse(`vera.kitchen.temperature > 75 && !status://home/away || sunset() < now()`)
+	c.Assert(e, check.IsNil)
+
+	c.Check(x.Urls(), check.DeepEquals, []string{
+		"status://home/away",
+		"status://server/solar/sunset",
+		"status://vera/kitchen/temperature",
+	})
+	c.Check(x.UsesClock(), check.Equals, true)
+
+	x, e = Parse(`vera.device.12.level == "55"`)
+	c.Assert(e, check.IsNil)
+	c.Check(x.Urls(), check.DeepEquals, []string{"status://vera/device/12/level"})
+	c.Check(x.UsesClock(), check.Equals, false)
+}
+
+func (suite *MySuite) TestParseBad(c *check.C) {
+	bad := []string{
+		``,
+		`1 +`,
+		`(1 + 2`,
+		`1 2`,
+		`"unterminated`,
+		`1.2.3`,
+		`a.b = 1`,
+		`a.b & c`,
+		`bogus(1)`,
+		`now(1)`,
+		`contains("a")`,
+		`#`,
+	}
+
+	for _, b := range bad {
+		_, e := Parse(b)
+		c.Check(e, check.NotNil, check.Commentf(b))
+	}
+}
+
+func (suite *MySuite) TestEval(c *check.C) {
+	env := setupEnv(c)
+
+	tests := []struct {
+		source   string
+		expected interface{}
+	}{
+		// Literals and arithmetic.
+		{`1 + 2 * 3`, 7.0},
+		{`(1 + 2) * 3`, 9.0},
+		{`-2 - -3`, 1.0},
+		{`7 % 4 / 2`, 1.5},
+		{`"a" + 'b'`, "ab"},
+		{`"it's \"quoted\""`, `it's "quoted"`},
+		{`null`, nil},
+
+		// Status values.
+		{`vera.kitchen.temperature * 1.8 + 32`, 72*1.8 + 32},
+		{`status://vera/kitchen/temperature`, 72.0},
+		{`vera.kitchen.missing`, nil},
+		{`"Level: " + vera.device.12.level`, "Level: 55"},
+		{`vera.device.12.level > 50`, true},
+		{`home.list`, []interface{}{1.0, 2.0, 3.0}},
+
+		// Comparisons and logic.
+		{`vera.kitchen.temperature > 75 && !home.away`, false},
+		{`vera.kitchen.temperature <= 72 || bogus.value`, true},
+		{`!vera.kitchen.missing`, true},
+		{`1 == 1.0`, true},
+		{`"1" == 1`, false},
+		{`"abc" < "abd"`, true},
+		{`vera.kitchen.missing == null`, true},
+		{`home.list == home.list`, true},
+
+		// Times.
+		{`now()`, "2014-06-12T12:00:00Z"},
+		{`now() + duration("90m")`, "2014-06-12T13:30:00Z"},
+		{`sunset() - "30m"`, "2014-06-12T20:00:00Z"},
+		{`now() > sunrise() && now() < sunset()`, true},
+		{`now() == "2014-06-12T12:00:00Z"`, true},
+		{`time("20:00") - now()`, "8h0m0s"},
+		{`now() - engine.rules.porch.last_on > duration("30m")`, true},
+
+		// Functions.
+		{`contains(vera.kitchen.name, "Light")`, true},
+		{`starts_with(lower(vera.kitchen.name), "kitchen")`, true},
+		{`ends_with(upper(vera.kitchen.name), "light")`, false},
+		{`len(vera.kitchen.name) + len(home.list)`, 16.0},
+		{`number(vera.device.12.level) + 1`, 56.0},
+	}
+
+	for _, t := range tests {
+		x, e := Parse(t.source)
+		if !c.Check(e, check.IsNil, check.Commentf(t.source)) {
+			continue
+		}
+
+		value, e := x.Eval(env)
+		c.Check(e, check.IsNil, check.Commentf(t.source))
+		c.Check(value, check.DeepEquals, t.expected, check.Commentf(t.source))
+	}
+}
+
+func (suite *MySuite) TestEvalErrors(c *check.C) {
+	env := setupEnv(c)
+
+	bad := []string{
+		`1 / 0`,
+		`1 % 0`,
+		`1 && true`,
+		`!"a"`,
+		`-"a"`,
+		`"a" < 1`,
+		`vera.kitchen.missing < 1`,
+		`home.list * 2`,
+		`time("noon")`,
+		`duration("soon")`,
+		`number("abc")`,
+		`len(5)`,
+	}
+
+	for _, b := range bad {
+		x, e := Parse(b)
+		if !c.Check(e, check.IsNil, check.Commentf(b)) {
+			continue
+		}
+
+		_, e = x.Eval(env)
+		c.Check(e, check.NotNil, check.Commentf(b))
+	}
+
+	// Missing solar times.
+	c.Assert(env.Status.Remove("status://server/solar", status.UNCHECKED_REVISION), check.IsNil)
+	x, e := Parse(`now() > sunset()`)
+	c.Assert(e, check.IsNil)
+	_, e = x.Eval(env)
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestEvalBool(c *check.C) {
+	env := setupEnv(c)
+
+	tests := map[string]bool{
+		`true`:                 true,
+		`home.away`:            false,
+		`vera.kitchen.missing`: false,
+		`1 < 2`:                true,
+	}
+
+	for source, expected := range tests {
+		x, e := Parse(source)
+		c.Assert(e, check.IsNil)
+
+		result, e := x.EvalBool(env)
+		c.Check(e, check.IsNil, check.Commentf(source))
+		c.Check(result, check.Equals, expected, check.Commentf(source))
+	}
+
+	x, e := Parse(`vera.kitchen.temperature`)
+	c.Assert(e, check.IsNil)
+
+	_, e = x.EvalBool(env)
+	c.Check(e, check.NotNil)
+}
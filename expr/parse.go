@@ -0,0 +1,374 @@
+// Package expr is a small expression language over status values, used by
+// conditions and actions. For example:
+//
+//	vera.kitchen.temperature > 75 && !home.away
+//
+// Dotted names (vera.kitchen.temperature) and status URLs
+// (status://vera/kitchen/temperature) read status values. Missing values are
+// null.
+package expr
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A parsed expression.
+type Expr struct {
+	source    string
+	root      node
+	urls      []string // Status URLs read, sorted.
+	usesClock bool     // Does the result depend on the time?
+}
+
+// Operators, by precedence from loosest to tightest.
+var binaryOps = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func Parse(source string) (*Expr, error) {
+	tokens, e := lex(source)
+	if e != nil {
+		return nil, e
+	}
+
+	p := &parser{tokens: tokens, urls: map[string]bool{}}
+
+	root, e := p.parseBinary(0)
+	if e != nil {
+		return nil, e
+	}
+
+	if t := p.next(); t.kind != tokEnd {
+		return nil, fmt.Errorf("Expr: Unexpected %q at %d.", t.text, t.pos)
+	}
+
+	urls := []string{}
+	for url := range p.urls {
+		urls = append(urls, url)
+	}
+	sort.Strings(urls)
+
+	return &Expr{source, root, urls, p.usesClock}, nil
+}
+
+// The status URLs an expression reads, which should be watched to notice
+// when it's value changes.
+func (x *Expr) Urls() []string {
+	return x.urls
+}
+
+// Does the result depend on the time (now(), time(), etc), as well as
+// status values?
+func (x *Expr) UsesClock() bool {
+	return x.usesClock
+}
+
+func (x *Expr) String() string {
+	return x.source
+}
+
+//
+// Lexer
+//
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokNumber
+	tokString
+	tokName // A name, or dotted path.
+	tokUrl
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{} // Numbers and strings.
+	pos   int
+}
+
+const statusPrefix = "status://"
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+func isUrlChar(c byte) bool {
+	return isNameChar(c) || c == '-' || c == '.' || c == '/'
+}
+
+func lex(source string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+			continue
+
+		case c >= '0' && c <= '9':
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			value, e := strconv.ParseFloat(source[start:i], 64)
+			if e != nil {
+				return nil, fmt.Errorf("Expr: Invalid number %q at %d.", source[start:i], start)
+			}
+			tokens = append(tokens, token{tokNumber, source[start:i], value, start})
+
+		case c == '"' || c == '\'':
+			value, end, e := lexString(source, start)
+			if e != nil {
+				return nil, e
+			}
+			i = end
+			tokens = append(tokens, token{tokString, source[start:i], value, start})
+
+		case strings.HasPrefix(source[i:], statusPrefix):
+			i += len(statusPrefix)
+			for i < len(source) && isUrlChar(source[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokUrl, source[start:i], nil, start})
+
+		case isNameStart(c):
+			// Dots join names into a path.
+			for i < len(source) && (isNameChar(source[i]) ||
+				(source[i] == '.' && i+1 < len(source) && isNameChar(source[i+1]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokName, source[start:i], nil, start})
+
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">="} {
+				if strings.HasPrefix(source[i:], candidate) {
+					op = candidate
+				}
+			}
+			if op == "" && strings.IndexByte("!<>+-*/%(),", c) != -1 {
+				op = string(c)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("Expr: Unexpected %q at %d.", c, start)
+			}
+			i += len(op)
+			tokens = append(tokens, token{tokOp, op, nil, start})
+		}
+	}
+
+	return append(tokens, token{tokEnd, "end", nil, len(source)}), nil
+}
+
+// Read a quoted string starting at start. Returns it's value, and the index
+// after the closing quote.
+func lexString(source string, start int) (string, int, error) {
+	quote := source[start]
+	result := []byte{}
+
+	for i := start + 1; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == quote:
+			return string(result), i + 1, nil
+
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				result = append(result, '\n')
+			case 't':
+				result = append(result, '\t')
+			default:
+				result = append(result, source[i])
+			}
+
+		default:
+			result = append(result, c)
+		}
+	}
+
+	return "", 0, fmt.Errorf("Expr: Unterminated string at %d.", start)
+}
+
+//
+// Parser
+//
+
+type parser struct {
+	tokens    []token
+	pos       int
+	urls      map[string]bool
+	usesClock bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEnd {
+		p.pos++
+	}
+	return t
+}
+
+// If the next token is one of ops, consume and return it.
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		return fmt.Errorf("Expr: Expected %q at %d, found %q.", op, t.pos, t.text)
+	}
+	return nil
+}
+
+// Parse operators at a precedence level, and everything tighter.
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryOps) {
+		return p.parseUnary()
+	}
+
+	left, e := p.parseBinary(level + 1)
+	if e != nil {
+		return nil, e
+	}
+
+	for {
+		op, ok := p.accept(binaryOps[level]...)
+		if !ok {
+			return left, nil
+		}
+
+		right, e := p.parseBinary(level + 1)
+		if e != nil {
+			return nil, e
+		}
+
+		left = &binaryNode{op, left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, e := p.parseUnary()
+		if e != nil {
+			return nil, e
+		}
+		return &unaryNode{op, operand}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+
+	switch t.kind {
+	case tokNumber, tokString:
+		return &literalNode{t.value}, nil
+
+	case tokUrl:
+		p.urls[t.text] = true
+		return &refNode{t.text}, nil
+
+	case tokName:
+		switch t.text {
+		case "true":
+			return &literalNode{true}, nil
+		case "false":
+			return &literalNode{false}, nil
+		case "null":
+			return &literalNode{nil}, nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+
+		url := statusPrefix + strings.Replace(t.text, ".", "/", -1)
+		p.urls[url] = true
+		return &refNode{url}, nil
+
+	case tokOp:
+		if t.text == "(" {
+			inner, e := p.parseBinary(0)
+			if e != nil {
+				return nil, e
+			}
+			return inner, p.expect(")")
+		}
+	}
+
+	return nil, fmt.Errorf("Expr: Unexpected %q at %d.", t.text, t.pos)
+}
+
+// Parse the arguments of a function call, after the "(".
+func (p *parser) parseCall(name token) (node, error) {
+	f, ok := functions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("Expr: Unknown function %s at %d.", name.text, name.pos)
+	}
+
+	args := []node{}
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, e := p.parseBinary(0)
+			if e != nil {
+				return nil, e
+			}
+			args = append(args, arg)
+
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+
+		if e := p.expect(")"); e != nil {
+			return nil, e
+		}
+	}
+
+	if len(args) != f.args {
+		return nil, fmt.Errorf("Expr: %s takes %d arguments, not %d.", name.text, f.args, len(args))
+	}
+
+	p.usesClock = p.usesClock || f.usesClock
+	for _, url := range f.urls {
+		p.urls[url] = true
+	}
+
+	return &callNode{name.text, f, args}, nil
+}
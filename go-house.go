@@ -1,16 +1,23 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
 	"github.com/DonGar/go-house/adapter"
+	"github.com/DonGar/go-house/clock"
 	"github.com/DonGar/go-house/engine"
 	"github.com/DonGar/go-house/engine/actions"
 	"github.com/DonGar/go-house/http-server"
 	"github.com/DonGar/go-house/logging"
 	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/simulate"
 	"github.com/DonGar/go-house/status"
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 )
 
 func mainWork() error {
@@ -41,12 +48,41 @@ func mainWork() error {
 		log.SetOutput(io.MultiWriter(os.Stderr, cachedLogging, logfile))
 	}
 
+	// Reject a bad timezone before anything uses it.
+	err = options.CheckTimezone(status)
+	if err != nil {
+		return err
+	}
+
+	// Restore saved values before anything starts using them.
+	err = options.InitializePersistence(status)
+	if err != nil {
+		return err
+	}
+
+	// Start recording history before values start changing.
+	err = options.InitializeHistory(status)
+	if err != nil {
+		return err
+	}
+
+	err = options.InitializeAudit(status)
+	if err != nil {
+		return err
+	}
+
+	// Validate values before adapters and rules start writing them.
+	err = options.InitializeSchemas(status)
+	if err != nil {
+		return err
+	}
+
 	// Create the action registrar
 	actionsMgr := actions.NewManager()
 	actions.RegisterStandardActions(actionsMgr)
 
 	// Start the engine (rules, properties, etc)
-	engine, err := engine.NewEngine(status, actionsMgr)
+	engine, err := engine.NewEngine(status, clock.NewReal(), actionsMgr)
 	if err != nil {
 		return err
 	}
@@ -59,11 +95,92 @@ func mainWork() error {
 	}
 	defer adapterMgr.Stop()
 
+	// Deferred last, so it runs first. Adapter shutdown shouldn't be saved.
+	defer status.StopPersistence()
+
 	// Run the web server. This normally never returns.
-	return server.RunHttpServerForever(status, adapterMgr, cachedLogging)
+	return server.RunHttpServerForever(status, adapterMgr, engine, cachedLogging)
+}
+
+// Handle "go-house simulate", which replays recorded changes against the
+// configured rules, and prints what would have happened.
+func simulateWork(arguments []string) error {
+	flagSet := flag.NewFlagSet("go-house simulate", flag.ExitOnError)
+
+	configDir := flagSet.String("config_dir", filepath.Join(filepath.Dir(os.Args[0]), "config"),
+		"Directory that holds configuration files, especially server.json.")
+	changesFile := flagSet.String("changes", "",
+		"File of recorded changes, in the format returned by /audit/.")
+	startTime := flagSet.String("start", "",
+		"RFC3339 time to start at. Defaults to the first change.")
+	endTime := flagSet.String("end", "",
+		"RFC3339 time to end at. Defaults to the last change.")
+	jsonOutput := flagSet.Bool("json", false,
+		"Print the timeline as JSON.")
+
+	if err := flagSet.Parse(arguments); err != nil {
+		return err
+	}
+
+	var start, end time.Time
+	var err error
+	if *startTime != "" {
+		if start, err = time.Parse(time.RFC3339, *startTime); err != nil {
+			return err
+		}
+	}
+	if *endTime != "" {
+		if end, err = time.Parse(time.RFC3339, *endTime); err != nil {
+			return err
+		}
+	}
+
+	s := &status.Status{}
+	if err = simulate.LoadConfig(s, *configDir); err != nil {
+		return err
+	}
+
+	changes := []status.AuditEntry{}
+	if *changesFile != "" {
+		f, err := os.Open(*changesFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if changes, err = simulate.ReadChanges(f); err != nil {
+			return err
+		}
+	}
+
+	timeline, err := simulate.Run(s, changes, start, end)
+	if err != nil {
+		return err
+	}
+
+	if *jsonOutput {
+		timelineJson, err := json.MarshalIndent(timeline, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(timelineJson))
+		return nil
+	}
+
+	for _, event := range timeline {
+		fmt.Println(event)
+	}
+	return nil
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "simulate" {
+		if err := simulateWork(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	if err := mainWork(); err != nil {
 		log.Panic(err)
 	}
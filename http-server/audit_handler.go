@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/status"
+	"net/http"
+)
+
+// Define the type used to handle audit log requests.
+type AuditHandler struct {
+	status *status.Status
+}
+
+// Handle an Audit request. Returns recent changes at or below the requested
+// URL, optionally limited by "origin" and "since".
+func (h *AuditHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statusUrl := "status://" + r.URL.Path[len("/audit/"):]
+
+	if r.Method != "GET" && r.Method != "POST" {
+		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	since, e := parseSince(h.status, r.FormValue("since"))
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, e := h.status.GetAudit(statusUrl, r.FormValue("origin"), since)
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	valueJson, e := json.MarshalIndent(map[string]interface{}{"audit": entries}, "", "  ")
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(valueJson))
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"net/http"
+)
+
+func setupAuditHandler(c *check.C) *AuditHandler {
+	s := &status.Status{}
+
+	c.Assert(s.SetFrom("rule:a", "status://kitchen/light", true, status.UNCHECKED_REVISION), check.IsNil)
+	c.Assert(s.SetFrom("adapter:vera", "status://kitchen/temp", 70, status.UNCHECKED_REVISION), check.IsNil)
+	c.Assert(s.SetFrom("rule:b", "status://porch/light", false, status.UNCHECKED_REVISION), check.IsNil)
+
+	return &AuditHandler{s}
+}
+
+func (suite *MySuite) TestAuditGet(c *check.C) {
+	h := setupAuditHandler(c)
+
+	var result struct {
+		Audit []status.AuditEntry
+	}
+
+	response := performRequest(c, h, "GET", "http://example.com/audit/kitchen?since=1h", "")
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.HeaderMap,
+		check.DeepEquals,
+		http.Header{"Content-Type": []string{"application/json"}})
+
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+	c.Assert(len(result.Audit), check.Equals, 2)
+	c.Check(result.Audit[0].Url, check.Equals, "status://kitchen/light")
+	c.Check(result.Audit[0].Origin, check.Equals, "rule:a")
+	c.Check(result.Audit[1].Value, check.Equals, 70.0)
+
+	response = performRequest(c, h, "GET", "http://example.com/audit/*/light?origin=rule:", "")
+	c.Check(response.Code, check.Equals, 200)
+
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+	c.Assert(len(result.Audit), check.Equals, 2)
+	c.Check(result.Audit[1].Url, check.Equals, "status://porch/light")
+	c.Check(result.Audit[1].Origin, check.Equals, "rule:b")
+}
+
+func (suite *MySuite) TestAuditGetErrors(c *check.C) {
+	h := setupAuditHandler(c)
+
+	response := performRequest(c, h, "GET", "http://example.com/audit/?since=bogus", "")
+	c.Check(response.Code, check.Equals, 400)
+
+	response = performRequest(c, h, "PUT", "http://example.com/audit/", "")
+	c.Check(response.Code, check.Equals, 405)
+}
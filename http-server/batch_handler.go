@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/status"
+	"io"
+	"net/http"
+)
+
+// Define the type used to handle batch update requests.
+type BatchHandler struct {
+	status *status.Status
+}
+
+// A single change in a batch request.
+type batchOp struct {
+	Op       string      `json:"op"` // "set" (default), "remove", "insert" or "append"
+	Url      string      `json:"url"`
+	Value    interface{} `json:"value"`
+	Revision *int        `json:"revision"` // Optional.
+}
+
+// Apply a list of changes as a single update. Either all succeed, or none do.
+func (b *BatchHandler) HandlePut(w http.ResponseWriter, r *http.Request) {
+	// Read the body into memory.
+	body := bytes.NewBuffer(nil)
+	_, e := io.CopyN(body, r.Body, 1*1024*1024) // Limit read size to 1M
+	switch {
+	case e == nil:
+		// We read the limit, without reaching the end of the body.
+		logAndHttpError(w, "Batch request too large", http.StatusRequestEntityTooLarge)
+		return
+	case e != io.EOF:
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ops := []batchOp{}
+	if e = json.Unmarshal(body.Bytes(), &ops); e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	e = b.status.UpdateFrom(requestOrigin(r), func(tx *status.Tx) error {
+		for _, op := range ops {
+			revision := status.UNCHECKED_REVISION
+			if op.Revision != nil {
+				revision = *op.Revision
+			}
+
+			switch op.Op {
+			case "", "set":
+				if e := tx.Set(op.Url, op.Value, revision); e != nil {
+					return e
+				}
+			case "remove":
+				if e := tx.Remove(op.Url, revision); e != nil {
+					return e
+				}
+			case "insert":
+				if e := tx.Insert(op.Url, op.Value, revision); e != nil {
+					return e
+				}
+			case "append":
+				if e := tx.Append(op.Url, op.Value, revision); e != nil {
+					return e
+				}
+			default:
+				return fmt.Errorf("Unknown batch op: %s", op.Op)
+			}
+		}
+		return nil
+	})
+
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// Handle a Batch request.
+func (b *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Dispatch the request, based on the type of request.
+	switch r.Method {
+	case "PUT", "POST":
+		b.HandlePut(w, r)
+	default:
+		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
+			http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+func performBatchRequest(c *check.C, method, body string) (*status.Status, *httptest.ResponseRecorder) {
+	s := &status.Status{}
+	e := s.SetJson("status://", []byte(`{"a": 1, "b": {"c": 2}}`), 0)
+	c.Assert(e, check.IsNil)
+
+	request, e := http.NewRequest(method, "http://example.com/batch", strings.NewReader(body))
+	c.Assert(e, check.IsNil)
+
+	response := httptest.NewRecorder()
+	(&BatchHandler{s}).ServeHTTP(response, request)
+
+	return s, response
+}
+
+func (suite *MySuite) TestBatchPut(c *check.C) {
+	s, response := performBatchRequest(c, "PUT", `[
+		{"url": "status://a", "value": 5, "revision": 1},
+		{"op": "set", "url": "status://d", "value": {"e": true}},
+		{"op": "remove", "url": "status://b/c"}
+	]`)
+
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(response.Body.String(), check.Equals, "")
+	c.Check(s.PrettyDump("status://"), check.Equals, status.NormalizeJson(
+		`{"a": 5, "b": {}, "d": {"e": true}}`))
+
+	_, revision, _ := s.Get("status://")
+	c.Check(revision, check.Equals, 2)
+}
+
+func (suite *MySuite) TestBatchArrays(c *check.C) {
+	s, response := performBatchRequest(c, "PUT", `[
+		{"url": "status://list", "value": ["b"]},
+		{"op": "insert", "url": "status://list/0", "value": "a"},
+		{"op": "append", "url": "status://list", "value": "d"},
+		{"op": "insert", "url": "status://list/2", "value": "c"},
+		{"op": "remove", "url": "status://list/3"}
+	]`)
+
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(s.PrettyDump("status://list"), check.Equals, status.NormalizeJson(`["a", "b", "c"]`))
+}
+
+func (suite *MySuite) TestBatchFailures(c *check.C) {
+	bad := []string{
+		`{"url": "status://a", "value": 5}`,
+		`[{"url": "status://a", "value": 5}, {"url": "status://b", "value": 5, "revision": 33}]`,
+		`[{"url": "status://a", "value": 5}, {"op": "bogus", "url": "status://b"}]`,
+		`[{"url": "status://a", "value": 5}, {"op": "remove", "url": "status://bogus"}]`,
+		`[{"url": "status://*/a", "value": 5}]`,
+		`[{"url": "status://a", "value": 5}, {"op": "append", "url": "status://b", "value": 5}]`,
+	}
+
+	for _, b := range bad {
+		s, response := performBatchRequest(c, "POST", b)
+		c.Check(response.Code, check.Equals, 400)
+
+		// Nothing was changed.
+		c.Check(s.PrettyDump("status://"), check.Equals, status.NormalizeJson(`{"a": 1, "b": {"c": 2}}`))
+	}
+
+	// Bodies over 1M are rejected.
+	s, response := performBatchRequest(c, "POST", `[{"url": "status://a", "value": "`+strings.Repeat("x", 1024*1024)+`"}]`)
+	c.Check(response.Code, check.Equals, 413)
+	c.Check(s.PrettyDump("status://"), check.Equals, status.NormalizeJson(`{"a": 1, "b": {"c": 2}}`))
+
+	_, response = performBatchRequest(c, "GET", "")
+	c.Check(response.Code, check.Equals, 405)
+	c.Check(response.Body.String(), check.Equals, "Method GET not supported\n")
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/engine"
+	"net/http"
+)
+
+// Define the type used to handle requests to explain rules.
+type ExplainHandler struct {
+	engine *engine.Engine
+}
+
+// Handle an Explain request. Returns the current state of the named rule's
+// condition, and all of it's inner conditions.
+func (h *ExplainHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/explain/"):]
+
+	if r.Method != "GET" && r.Method != "POST" {
+		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	explanation, e := h.engine.ExplainRule(name)
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusNotFound)
+		return
+	}
+
+	valueJson, e := json.MarshalIndent(
+		map[string]interface{}{"rule": name, "condition": explanation}, "", "  ")
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(valueJson))
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/engine"
+	"github.com/DonGar/go-house/engine/actions"
+	"github.com/DonGar/go-house/engine/conditions"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"net/http"
+	"time"
+)
+
+func (suite *MySuite) TestExplain(c *check.C) {
+	s := &status.Status{}
+	e := s.SetJson("status://", []byte(`
+		{
+			"house": {
+				"rule": {
+					"porch": {
+						"condition": {
+							"test": "and",
+							"conditions": [{"test": "true"}, {"test": "false"}]
+						},
+						"on": null
+					}
+				}
+			}
+		}`), 0)
+	c.Assert(e, check.IsNil)
+
+	eng, e := engine.NewEngine(s, clock.NewReal(), actions.NewManager())
+	c.Assert(e, check.IsNil)
+	defer eng.Stop()
+
+	// Give the engine time to create the rule.
+	time.Sleep(100 * time.Millisecond)
+
+	h := &ExplainHandler{eng}
+
+	var result struct {
+		Rule      string
+		Condition conditions.Explanation
+	}
+
+	response := performRequest(c, h, "GET", "http://example.com/explain/porch", "")
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.HeaderMap,
+		check.DeepEquals,
+		http.Header{"Content-Type": []string{"application/json"}})
+
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+	c.Check(result.Rule, check.Equals, "porch")
+	c.Check(result.Condition.Type, check.Equals, "and")
+	c.Assert(result.Condition.Children, check.HasLen, 2)
+	c.Check(result.Condition.Children[0].Type, check.Equals, "true")
+	c.Check(*result.Condition.Children[1].Result, check.Equals, false)
+
+	response = performRequest(c, h, "GET", "http://example.com/explain/bogus", "")
+	c.Check(response.Code, check.Equals, http.StatusNotFound)
+
+	response = performRequest(c, h, "PUT", "http://example.com/explain/porch", "")
+	c.Check(response.Code, check.Equals, http.StatusMethodNotAllowed)
+}
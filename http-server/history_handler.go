@@ -0,0 +1,101 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/status"
+	"net/http"
+	"time"
+)
+
+// Define the type used to handle history requests.
+type HistoryHandler struct {
+	status *status.Status
+}
+
+// Handle Get/Post History requests.
+func (h *HistoryHandler) HandleGet(
+	w http.ResponseWriter, r *http.Request,
+	statusUrl string, since time.Time, bucketSize time.Duration) {
+
+	matches, e := h.status.GetHistory(statusUrl, since)
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(matches) == 0 {
+		logAndHttpError(w, "No history for: "+statusUrl, http.StatusNotFound)
+		return
+	}
+
+	var wrapperValue map[string]interface{}
+
+	if bucketSize == 0 {
+		wrapperValue = map[string]interface{}{"history": matches}
+	} else {
+		buckets := map[string][]status.HistoryBucket{}
+		for url, entries := range matches {
+			if buckets[url], e = status.Downsample(entries, bucketSize); e != nil {
+				logAndHttpError(w, e.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		wrapperValue = map[string]interface{}{"buckets": buckets}
+	}
+
+	// We've found our result, convert to json.
+	valueJson, e := json.MarshalIndent(wrapperValue, "", "  ")
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Send final result.
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintln(w, string(valueJson))
+}
+
+// Parse the 'since' argument. It may be an RFC3339 time, or a duration
+// before now ("24h") on the status clock.
+func parseSince(s *status.Status, since string) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	if d, e := time.ParseDuration(since); e == nil {
+		return s.Clock().Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, since)
+}
+
+// Handle a History request. This parses arguments, then hands off to Method
+// specific handlers.
+func (h *HistoryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	statusUrl := "status://" + r.URL.Path[len("/history/"):]
+
+	since, e := parseSince(h.status, r.FormValue("since"))
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var bucketSize time.Duration
+	if bucketStr := r.FormValue("bucket"); bucketStr != "" {
+		bucketSize, e = time.ParseDuration(bucketStr)
+		if e != nil {
+			logAndHttpError(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Dispatch the request, based on the type of request.
+	switch r.Method {
+	case "GET", "POST":
+		h.HandleGet(w, r, statusUrl, since, bucketSize)
+	default:
+		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
+			http.StatusMethodNotAllowed)
+	}
+}
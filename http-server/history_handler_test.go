@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"net/http"
+	"time"
+)
+
+func setupHistoryHandler(c *check.C) *HistoryHandler {
+	s := &status.Status{}
+
+	c.Assert(s.RecordHistory("status://*/temp", 0, 0), check.IsNil)
+	c.Assert(s.Set("status://kitchen/temp", 70, status.UNCHECKED_REVISION), check.IsNil)
+	c.Assert(s.Set("status://kitchen/temp", 80, status.UNCHECKED_REVISION), check.IsNil)
+
+	return &HistoryHandler{s}
+}
+
+func (suite *MySuite) TestHistoryGet(c *check.C) {
+	h := setupHistoryHandler(c)
+
+	response := performRequest(c, h, "GET", "http://example.com/history/*/temp?since=1h", "")
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.HeaderMap,
+		check.DeepEquals,
+		http.Header{"Content-Type": []string{"application/json"}})
+
+	var result struct {
+		History status.HistoryMatches
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+
+	entries := result.History["status://kitchen/temp"]
+	c.Assert(len(entries), check.Equals, 2)
+	c.Check(entries[0].Value, check.Equals, 70.0)
+	c.Check(entries[1].Value, check.Equals, 80.0)
+	c.Check(entries[1].Revision, check.Equals, 2)
+}
+
+func (suite *MySuite) TestHistoryGetSinceUsesStatusClock(c *check.C) {
+	s := &status.Status{}
+	s.SetClock(clock.NewFake(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)))
+
+	c.Assert(s.RecordHistory("status://*/temp", 0, 0), check.IsNil)
+	c.Assert(s.Set("status://kitchen/temp", 70, status.UNCHECKED_REVISION), check.IsNil)
+
+	// Relative to the real clock, the value is years old.
+	response := performRequest(c, &HistoryHandler{s}, "GET", "http://example.com/history/kitchen/temp?since=1h", "")
+	c.Check(response.Code, check.Equals, 200)
+
+	var result struct {
+		History status.HistoryMatches
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+	c.Check(len(result.History["status://kitchen/temp"]), check.Equals, 1)
+}
+
+func (suite *MySuite) TestHistoryGetBuckets(c *check.C) {
+	h := setupHistoryHandler(c)
+
+	response := performRequest(c, h, "GET", "http://example.com/history/kitchen/temp?bucket=24h", "")
+	c.Check(response.Code, check.Equals, 200)
+
+	var result struct {
+		Buckets map[string][]status.HistoryBucket
+	}
+	c.Assert(json.Unmarshal(response.Body.Bytes(), &result), check.IsNil)
+
+	buckets := result.Buckets["status://kitchen/temp"]
+	c.Assert(len(buckets), check.Equals, 1)
+	c.Check(buckets[0].Count, check.Equals, 2)
+	c.Check(buckets[0].Min, check.Equals, 70.0)
+	c.Check(buckets[0].Max, check.Equals, 80.0)
+	c.Check(buckets[0].Avg, check.Equals, 75.0)
+}
+
+func (suite *MySuite) TestHistoryGetErrors(c *check.C) {
+	h := setupHistoryHandler(c)
+
+	response := performRequest(c, h, "GET", "http://example.com/history/kitchen/other", "")
+	c.Check(response.Code, check.Equals, 404)
+	c.Check(response.Body.String(), check.Equals, "No history for: status://kitchen/other\n")
+
+	response = performRequest(c, h, "GET", "http://example.com/history/kitchen/temp?since=bogus", "")
+	c.Check(response.Code, check.Equals, 400)
+
+	response = performRequest(c, h, "GET", "http://example.com/history/kitchen/temp?bucket=-1h", "")
+	c.Check(response.Code, check.Equals, 400)
+
+	response = performRequest(c, h, "PUT", "http://example.com/history/kitchen/temp", "")
+	c.Check(response.Code, check.Equals, 405)
+	c.Check(response.Body.String(), check.Equals, "Method PUT not supported\n")
+}
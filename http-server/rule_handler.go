@@ -0,0 +1,67 @@
+package server
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/engine"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Define the type used to handle requests to control rules.
+type RuleHandler struct {
+	engine *engine.Engine
+}
+
+// Handle a rule control request: /rule/<name>/<command>, where command is one
+// of:
+//
+//	enable, disable
+//	snooze?for=<duration>  (for=0 ends a snooze)
+//	fire?action=on|off
+func (h *RuleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(r.URL.Path[len("/rule/"):], "/")
+	if len(parts) != 2 {
+		logAndHttpError(w, fmt.Sprintf("Invalid rule request: %s", r.URL.Path),
+			http.StatusNotFound)
+		return
+	}
+	name, command := parts[0], parts[1]
+
+	var e error
+	switch command {
+	case "enable", "disable":
+		e = h.engine.EnableRule(requestOrigin(r), name, command == "enable")
+
+	case "snooze":
+		duration, parseErr := time.ParseDuration(r.FormValue("for"))
+		if parseErr != nil {
+			logAndHttpError(w, parseErr.Error(), http.StatusBadRequest)
+			return
+		}
+		e = h.engine.SnoozeRule(requestOrigin(r), name, duration)
+
+	case "fire":
+		action := r.FormValue("action")
+		if action != "on" && action != "off" {
+			logAndHttpError(w, fmt.Sprintf("Invalid action: %q", action), http.StatusBadRequest)
+			return
+		}
+		e = h.engine.FireRule(name, action == "on")
+
+	default:
+		logAndHttpError(w, fmt.Sprintf("Unknown rule command: %s", command), http.StatusNotFound)
+		return
+	}
+
+	if e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/engine"
+	"github.com/DonGar/go-house/engine/actions"
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"net/http"
+	"time"
+)
+
+func (suite *MySuite) TestRule(c *check.C) {
+	s := &status.Status{}
+	e := s.SetJson("status://", []byte(`
+		{
+			"porch": {"light": "off"},
+			"house": {
+				"rule": {
+					"porch": {
+						"condition": {"test": "false"},
+						"on": {"action": "set", "component": "status://porch", "dest": "light", "value": "on"}
+					}
+				}
+			}
+		}`), 0)
+	c.Assert(e, check.IsNil)
+
+	a := actions.NewManager()
+	actions.RegisterStandardActions(a)
+
+	eng, e := engine.NewEngine(s, clock.NewReal(), a)
+	c.Assert(e, check.IsNil)
+	defer eng.Stop()
+
+	// Give the engine time to create the rule.
+	time.Sleep(100 * time.Millisecond)
+
+	h := &RuleHandler{eng}
+
+	response := performRequest(c, h, "POST", "http://example.com/rule/porch/disable", "")
+	c.Check(response.Code, check.Equals, 200)
+	enabled, _, e := s.Get("status://control/rules/porch/enabled")
+	c.Check(e, check.IsNil)
+	c.Check(enabled, check.Equals, false)
+
+	response = performRequest(c, h, "POST", "http://example.com/rule/porch/enable", "")
+	c.Check(response.Code, check.Equals, 200)
+	enabled, _, e = s.Get("status://control/rules/porch/enabled")
+	c.Check(e, check.IsNil)
+	c.Check(enabled, check.Equals, true)
+
+	response = performRequest(c, h, "POST", "http://example.com/rule/porch/snooze?for=1h", "")
+	c.Check(response.Code, check.Equals, 200)
+	until, _, e := s.GetString("status://control/rules/porch/snooze_until")
+	c.Check(e, check.IsNil)
+	_, e = time.Parse(time.RFC3339, until)
+	c.Check(e, check.IsNil)
+
+	response = performRequest(c, h, "POST", "http://example.com/rule/porch/snooze?for=0", "")
+	c.Check(response.Code, check.Equals, 200)
+	snooze, _, e := s.Get("status://control/rules/porch/snooze_until")
+	c.Check(e, check.IsNil)
+	c.Check(snooze, check.IsNil)
+
+	response = performRequest(c, h, "POST", "http://example.com/rule/porch/fire?action=on", "")
+	c.Check(response.Code, check.Equals, 200)
+	light, _, e := s.Get("status://porch/light")
+	c.Check(e, check.IsNil)
+	c.Check(light, check.Equals, "on")
+
+	// Failures.
+	bad := []struct {
+		method, url string
+		code        int
+	}{
+		{"GET", "http://example.com/rule/porch/enable", http.StatusMethodNotAllowed},
+		{"POST", "http://example.com/rule/porch", http.StatusNotFound},
+		{"POST", "http://example.com/rule/porch/bogus", http.StatusNotFound},
+		{"POST", "http://example.com/rule/bogus/enable", http.StatusBadRequest},
+		{"POST", "http://example.com/rule/porch/snooze?for=bogus", http.StatusBadRequest},
+		{"POST", "http://example.com/rule/porch/fire?action=bogus", http.StatusBadRequest},
+		{"POST", "http://example.com/rule/porch/fire?action=off", http.StatusBadRequest},
+	}
+
+	for _, b := range bad {
+		response = performRequest(c, h, b.method, b.url, "")
+		c.Check(response.Code, check.Equals, b.code, check.Commentf(b.url))
+	}
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"github.com/DonGar/go-house/adapter"
+	"github.com/DonGar/go-house/engine"
 	"github.com/DonGar/go-house/logging"
 	"github.com/DonGar/go-house/options"
 	"github.com/DonGar/go-house/status"
@@ -29,6 +30,7 @@ func logAndHttpError(w http.ResponseWriter, error string, code int) {
 func RunHttpServerForever(
 	status *status.Status,
 	adapterMgr *adapter.Manager,
+	engine *engine.Engine,
 	cachedLogging *logging.CachedLogging) error {
 
 	staticDir, _, e := status.GetString(options.STATIC_DIR)
@@ -40,7 +42,12 @@ func RunHttpServerForever(
 
 	http.Handle("/", http.FileServer(http.Dir(staticDir)))
 	http.Handle("/status/", &StatusHandler{status: status, adapterMgr: adapterMgr})
+	http.Handle("/batch", &BatchHandler{status})
 	http.Handle("/log/", &LogHandler{cachedLogging})
+	http.Handle("/history/", &HistoryHandler{status})
+	http.Handle("/audit/", &AuditHandler{status})
+	http.Handle("/explain/", &ExplainHandler{engine})
+	http.Handle("/rule/", &RuleHandler{engine})
 
 	log.Printf("Starting web server on %d.", port)
 	http.ListenAndServe(fmt.Sprintf(":%d", port), Log(http.DefaultServeMux))
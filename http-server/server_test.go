@@ -2,6 +2,9 @@ package server
 
 import (
 	"gopkg.in/check.v1"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -11,3 +14,13 @@ func Test(t *testing.T) { check.TestingT(t) }
 type MySuite struct{}
 
 var _ = check.Suite(&MySuite{})
+
+// Send a single request to a handler, and return the recorded response.
+func performRequest(c *check.C, h http.Handler, method, url, body string) *httptest.ResponseRecorder {
+	request, e := http.NewRequest(method, url, strings.NewReader(body))
+	c.Assert(e, check.IsNil)
+
+	response := httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+	return response
+}
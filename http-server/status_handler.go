@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //
@@ -34,6 +35,9 @@ func (s *StatusHandler) HandleGet(
 	}
 	defer s.status.ReleaseWatch(wc)
 
+	wildcard := status.CheckForWildcard(statusUrl) != nil
+	first := true
+
 	// Fetch a close channel from http.ResponseWriter, if supported.
 	var closeChannel <-chan bool
 	if closeNotifier, ok := w.(http.CloseNotifier); ok {
@@ -43,24 +47,33 @@ func (s *StatusHandler) HandleGet(
 	for {
 		select {
 		case matches := <-wc:
-			match, ok := matches[statusUrl]
-			if !ok {
-				// If our URL isn't in the matches, it doesn't exist.
-				logAndHttpError(w, "Status url not found: "+statusUrl, http.StatusNotFound)
-				return
-			}
-
-			if match.Revision == revision {
-				// The client already has our current revision. Block until a new
-				// revision is available.
-				continue
-			}
-
-			// We tag each result with an outer dictionary that describes the
-			// URL, revision, and status
-			wrapperValue := map[string]interface{}{
-				"revision": match.Revision,
-				"status":   match.Value,
+			var wrapperValue map[string]interface{}
+
+			if wildcard {
+				// Later notifications always mean the matches changed, even if a
+				// removal leaves the newest revision the same.
+				wrapperValue = wildcardResult(matches)
+				if first && wrapperValue["revision"] == revision {
+					first = false
+					continue
+				}
+			} else {
+				match, ok := matches[statusUrl]
+				if !ok {
+					// If our URL isn't in the matches, it doesn't exist.
+					logAndHttpError(w, "Status url not found: "+statusUrl, http.StatusNotFound)
+					return
+				}
+
+				if match.Revision == revision {
+					// The client already has our current revision. Block until a new
+					// revision is available.
+					continue
+				}
+
+				// We tag each result with an outer dictionary that describes the
+				// URL, revision, and status
+				wrapperValue = matchResult(match)
 			}
 
 			// We've found our result, convert to json.
@@ -82,6 +95,45 @@ func (s *StatusHandler) HandleGet(
 	}
 }
 
+// Describe a single URL result, with the origin of the newest change if it's
+// known.
+func matchResult(match status.UrlMatch) map[string]interface{} {
+	result := map[string]interface{}{
+		"revision": match.Revision,
+		"status":   match.Value,
+	}
+
+	if match.Origin != "" {
+		result["origin"] = match.Origin
+	}
+
+	return result
+}
+
+// The origin changes from a web request are attributed to.
+func requestOrigin(r *http.Request) string {
+	return "http:" + r.RemoteAddr
+}
+
+// Wrap the results of a wildcard request. Each match is described like a
+// single URL result, and the overall revision is the newest of them.
+func wildcardResult(matches status.UrlMatches) map[string]interface{} {
+	revision := 0
+	results := map[string]interface{}{}
+
+	for url, match := range matches {
+		if match.Revision > revision {
+			revision = match.Revision
+		}
+		results[url] = matchResult(match)
+	}
+
+	return map[string]interface{}{
+		"revision": revision,
+		"matches":  results,
+	}
+}
+
 // Find out if the requested URL can be legally written too in this request.
 func (s *StatusHandler) VerfiyStatusUrlAgainstAdapters(statusUrl string) bool {
 	for _, u := range s.adapterMgr.WebAdapterStatusUrls() {
@@ -111,14 +163,46 @@ func (s *StatusHandler) HandlePut(
 		return
 	}
 
-	// Put it into the status tree.
-	e = s.status.SetJson(statusUrl, body.Bytes(), revision)
+	var value interface{}
+	if e = json.Unmarshal(body.Bytes(), &value); e != nil {
+		logAndHttpError(w, e.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Put it into the status tree, with an expiration if requested.
+	e = s.status.UpdateFrom(requestOrigin(r), func(tx *status.Tx) error {
+		ttlStr := r.FormValue("ttl")
+		if ttlStr == "" {
+			return tx.Set(statusUrl, value, revision)
+		}
+
+		ttl, e := time.ParseDuration(ttlStr)
+		if e != nil {
+			return e
+		}
+
+		if _, ok := r.Form["fallback"]; ok {
+			fallback := parseJsonOrString(r.FormValue("fallback"))
+			return tx.SetWithFallback(statusUrl, value, revision, ttl, fallback)
+		}
+		return tx.SetWithTTL(statusUrl, value, revision, ttl)
+	})
+
 	if e != nil {
 		logAndHttpError(w, e.Error(), http.StatusBadRequest)
 		return
 	}
 }
 
+// Decode a query value as Json if possible, else treat it as a plain string.
+func parseJsonOrString(data string) interface{} {
+	var value interface{}
+	if e := json.Unmarshal([]byte(data), &value); e != nil {
+		return data
+	}
+	return value
+}
+
 // Handle a Status request. This parses arguments, then hands off to Method
 // specific handlers.
 func (s *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -136,17 +220,16 @@ func (s *StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Ensure the remote user only uses queries with a simple URL.
-	if e := status.CheckForWildcard(statusUrl); e != nil {
-		logAndHttpError(w, e.Error(), http.StatusBadRequest)
-		return
-	}
-
 	// Dispatch the request, based on the type of request.
 	switch r.Method {
 	case "GET", "POST":
 		s.HandleGet(w, r, statusUrl, revision)
 	case "PUT":
+		// Ensure the remote user only writes to a simple URL.
+		if e := status.CheckForWildcard(statusUrl); e != nil {
+			logAndHttpError(w, e.Error(), http.StatusBadRequest)
+			return
+		}
 		s.HandlePut(w, r, statusUrl, revision)
 	default:
 		logAndHttpError(w, fmt.Sprintf("Method %s not supported", r.Method),
@@ -180,7 +180,11 @@ func (suite *MySuite) TestGetUnknownStatusPath(c *check.C) {
 func (suite *MySuite) TestGetWildcardStatusPath(c *check.C) {
 	statusHandler := setupStatusHandler(c)
 
-	request, e := http.NewRequest("GET", "http://example.com/status/foo/*", nil)
+	e := statusHandler.status.SetJson("status://foo",
+		[]byte(`{"a": {"tripped": true}, "b": {"tripped": false}}`), 0)
+	c.Assert(e, check.IsNil)
+
+	request, e := http.NewRequest("GET", "http://example.com/status/foo/*[tripped=true]", nil)
 	c.Assert(e, check.IsNil)
 
 	response := httptest.NewRecorder()
@@ -189,12 +193,85 @@ func (suite *MySuite) TestGetWildcardStatusPath(c *check.C) {
 	statusHandler.ServeHTTP(response, request)
 
 	// Validate the result.
-	c.Check(response.Code, check.Equals, 400)
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.HeaderMap,
+		check.DeepEquals,
+		http.Header{"Content-Type": []string{"application/json"}})
+	c.Check(
+		response.Body.String(),
+		check.Equals,
+		`{
+  "matches": {
+    "status://foo/a": {
+      "revision": 1,
+      "status": {
+        "tripped": true
+      }
+    }
+  },
+  "revision": 1
+}
+`)
+}
+
+func (suite *MySuite) TestGetWildcardRevisionMatch(c *check.C) {
+	statusHandler := setupStatusHandler(c)
+
+	e := statusHandler.status.SetJson("status://foo", []byte(`{"a": 1, "b": 2}`), 0)
+	c.Assert(e, check.IsNil)
+
+	request, e := http.NewRequest("GET", "http://example.com/status/**/b?revision=1", nil)
+	c.Assert(e, check.IsNil)
+
+	response := httptest.NewRecorder()
+
+	// Wake up after a while and cause a change to complete. The first change
+	// doesn't match.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		statusHandler.status.Set("status://foo/a", 3, 1)
+		statusHandler.status.Set("status://foo/b", 4, 2)
+	}()
+
+	// Perform the query
+	statusHandler.ServeHTTP(response, request)
+
+	// Validate that we got the updated result.
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.Body.String(),
+		check.Equals,
+		`{
+  "matches": {
+    "status://foo/b": {
+      "revision": 3,
+      "status": 4
+    }
+  },
+  "revision": 3
+}
+`)
+}
+
+func (suite *MySuite) TestGetBadWildcardStatusPath(c *check.C) {
+	statusHandler := setupStatusHandler(c)
+
+	request, e := http.NewRequest("GET", "http://example.com/status/foo/*[tripped]", nil)
+	c.Assert(e, check.IsNil)
+
+	response := httptest.NewRecorder()
+
+	// Perform the query
+	statusHandler.ServeHTTP(response, request)
+
+	// Validate the result.
+	c.Check(response.Code, check.Equals, 404)
 	c.Check(response.HeaderMap, check.DeepEquals, STD_HEADER)
 	c.Check(
 		response.Body.String(),
 		check.Equals,
-		"Status: Wildcards not allowed here: status://foo/*\n")
+		"Status: Invalid status url: status://foo/*[tripped]: bad predicate [tripped]\n")
 }
 
 //
@@ -293,6 +370,36 @@ func (suite *MySuite) TestPutMinimal(c *check.C) {
 	c.Check(response.Body.String(), check.Equals, "")
 }
 
+func (suite *MySuite) TestPutTTL(c *check.C) {
+	statusHandler := setupStatusHandlerWithAdapter(c)
+
+	put := func(url, body string) *httptest.ResponseRecorder {
+		request, e := http.NewRequest("PUT", url, strings.NewReader(body))
+		c.Assert(e, check.IsNil)
+
+		response := httptest.NewRecorder()
+		statusHandler.ServeHTTP(response, request)
+		return response
+	}
+
+	response := put("http://example.com/status/adapter/motion?ttl=10ms", `true`)
+	c.Check(response.Code, check.Equals, 200)
+
+	response = put("http://example.com/status/adapter/home?ttl=10ms&fallback=away", `"home"`)
+	c.Check(response.Code, check.Equals, 200)
+
+	response = put("http://example.com/status/adapter/bad?ttl=bogus", `true`)
+	c.Check(response.Code, check.Equals, 400)
+
+	c.Check(statusHandler.status.PrettyDump("status://adapter"), check.Equals,
+		status.NormalizeJson(`{"motion": true, "home": "home"}`))
+
+	time.Sleep(50 * time.Millisecond)
+
+	c.Check(statusHandler.status.PrettyDump("status://adapter"), check.Equals,
+		status.NormalizeJson(`{"home": "away"}`))
+}
+
 func (suite *MySuite) TestPutRevisionMismatch(c *check.C) {
 	statusHandler := setupStatusHandlerWithAdapter(c)
 
@@ -373,3 +480,27 @@ func (suite *MySuite) TestPutWildcardStatusPath(c *check.C) {
 // 		check.Equals,
 // 		"No adapter for status://foo/.\n")
 // }
+
+func (suite *MySuite) TestPutOrigin(c *check.C) {
+	statusHandler := setupStatusHandlerWithAdapter(c)
+
+	request, e := http.NewRequest("PUT", "http://example.com/status/adapter/light", strings.NewReader(`true`))
+	c.Assert(e, check.IsNil)
+	request.RemoteAddr = "10.0.0.5:4321"
+
+	response := httptest.NewRecorder()
+	statusHandler.ServeHTTP(response, request)
+	c.Check(response.Code, check.Equals, 200)
+
+	// Reads include the origin of the newest change.
+	request, e = http.NewRequest("GET", "http://example.com/status/adapter/light", nil)
+	c.Assert(e, check.IsNil)
+
+	response = httptest.NewRecorder()
+	statusHandler.ServeHTTP(response, request)
+	c.Check(response.Code, check.Equals, 200)
+	c.Check(
+		response.Body.String(),
+		check.Equals,
+		"{\n  \"origin\": \"http:10.0.0.5:4321\",\n  \"revision\": 3,\n  \"status\": true\n}\n")
+}
@@ -2,21 +2,35 @@ package options
 
 import (
 	"flag"
+	"fmt"
 	"github.com/DonGar/go-house/status"
 	"io/ioutil"
 	"log"
 	"path/filepath"
+	"sync"
+	"time"
 )
 
 const (
 	ADAPTERS      = "status://server/adapters"
+	AUDIT_SIZE    = "status://server/audit_size"
 	CONFIG_DIR    = "status://server/config"
 	DOWNLOADS_DIR = "status://server/downloads"
+	ENGINE        = "status://engine"
+	HISTORY       = "status://server/history"
 	LOG_FILE      = "status://server/logfile"
+	PERSIST       = "status://server/persist"
 	PORT          = "status://server/port"
+	RULE_CONTROL  = "status://control/rules"
+	SOLAR         = "status://server/solar"
 	STATIC_DIR    = "status://server/static"
+	TIMEZONE      = "status://server/timezone"
 )
 
+// The origin of the engine's runtime state, which it publishes under ENGINE.
+// Only the engine may change it.
+const ENGINE_ORIGIN = "engine"
+
 // Load the initial server config into our status struct.
 func IntializeServerConfig(s *status.Status, arguments []string) (e error) {
 
@@ -127,3 +141,170 @@ func parseFlags(s *status.Status, arguments []string) (configDir string, e error
 
 	return configDir, nil
 }
+
+// Restore saved status values, and start saving changes, if "persist" is
+// configured in server.json. The server config, and the contents of file
+// adapters are always excluded, since they are reloaded from files. So is the
+// engine's runtime state, which is rebuilt as rules and properties start. Rule
+// controls are always included.
+func InitializePersistence(s *status.Status) (e error) {
+	persistOptions := status.PersistOptions{}
+
+	persistOptions.Dir, _, e = s.GetString(PERSIST + "/dir")
+	if e != nil {
+		// If persistence isn't configured, there is nothing to do.
+		return nil
+	}
+
+	persistOptions.Include, e = getUrlList(s, PERSIST+"/include")
+	if e != nil {
+		return e
+	}
+
+	persistOptions.Exclude, e = getUrlList(s, PERSIST+"/exclude")
+	if e != nil {
+		return e
+	}
+
+	// Rule controls are always kept, even if they aren't included.
+	if persistOptions.Include != nil {
+		persistOptions.Include = append(persistOptions.Include, RULE_CONTROL)
+	}
+
+	persistOptions.Exclude = append(persistOptions.Exclude, "status://server", ENGINE)
+
+	fileAdapters, _, e := s.GetChildNames(ADAPTERS + "/file")
+	if e == nil {
+		for _, name := range fileAdapters {
+			persistOptions.Exclude = append(persistOptions.Exclude, "status://"+name)
+		}
+	}
+
+	persistOptions.CompactAfter = s.GetIntWithDefault(PERSIST+"/compact_after", 0)
+
+	log.Println("Persist dir:   ", persistOptions.Dir)
+	return s.StartPersistence(persistOptions)
+}
+
+// Read an optional list of strings. If not present, return nil.
+func getUrlList(s *status.Status, url string) (result []string, e error) {
+	raw, _, e := s.Get(url)
+	if e != nil {
+		return nil, nil
+	}
+
+	rawList, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a list.", url)
+	}
+
+	result = make([]string, len(rawList))
+	for i, v := range rawList {
+		if result[i], ok = v.(string); !ok {
+			return nil, fmt.Errorf("%s contains non-string %#v.", url, v)
+		}
+	}
+
+	return result, nil
+}
+
+// Start recording history for each URL listed in the "history" section of
+// server.json, if present.
+func InitializeHistory(s *status.Status) (e error) {
+	raw, _, e := s.Get(HISTORY)
+	if e != nil {
+		// If history isn't configured, there is nothing to do.
+		return nil
+	}
+
+	rawList, ok := raw.([]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not a list.", HISTORY)
+	}
+
+	for i, rawEntry := range rawList {
+		historyBody := &status.Status{}
+		if e = historyBody.Set("status://", rawEntry, 0); e != nil {
+			return e
+		}
+
+		url, _, e := historyBody.GetString("status://url")
+		if e != nil {
+			return fmt.Errorf("%s entry %d has no 'url'.", HISTORY, i)
+		}
+
+		maxCount := historyBody.GetIntWithDefault("status://max_count", 0)
+
+		var maxAge time.Duration
+		if maxAgeStr, _, e := historyBody.GetString("status://max_age"); e == nil {
+			if maxAge, e = time.ParseDuration(maxAgeStr); e != nil {
+				return e
+			}
+		}
+
+		if e = s.RecordHistory(url, maxCount, maxAge); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Resize the audit log of recent changes, if "audit_size" is set in
+// server.json.
+func InitializeAudit(s *status.Status) (e error) {
+	size := s.GetIntWithDefault(AUDIT_SIZE, status.DEFAULT_AUDIT_SIZE)
+	return s.SetAuditSize(size)
+}
+
+// The most recently loaded timezone, so it's only reloaded if the name
+// changes.
+var locationCache struct {
+	sync.Mutex
+	name string
+	loc  *time.Location
+}
+
+// The server's timezone, from "timezone" in server.json. Defaults to the
+// local timezone of the process.
+func Location(s *status.Status) (*time.Location, error) {
+	name, _, e := s.GetString(TIMEZONE)
+	if e != nil {
+		return time.Local, nil
+	}
+
+	locationCache.Lock()
+	defer locationCache.Unlock()
+
+	if locationCache.loc != nil && locationCache.name == name {
+		return locationCache.loc, nil
+	}
+
+	loc, e := time.LoadLocation(name)
+	if e != nil {
+		return nil, fmt.Errorf("Invalid timezone: %s: %s", name, e.Error())
+	}
+
+	locationCache.name, locationCache.loc = name, loc
+	return loc, nil
+}
+
+// Format a timestamp to record in status, in the server's timezone.
+func FormatTime(s *status.Status, t time.Time) string {
+	if loc, e := Location(s); e == nil {
+		t = t.In(loc)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Check the "timezone" in server.json, so a bad one is reported at startup.
+// Anything using the timezone looks it up with Location.
+func CheckTimezone(s *status.Status) (e error) {
+	loc, e := Location(s)
+	if e != nil {
+		return e
+	}
+
+	log.Println("Timezone:      ", loc)
+	return nil
+}
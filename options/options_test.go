@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -175,3 +176,50 @@ func (suite *MySuite) TestParseFlagsConfigSetArgs(c *check.C) {
 			"foo":       "bar",
 		})
 }
+
+func (suite *MySuite) TestLocation(c *check.C) {
+	s := &status.Status{}
+
+	loc, e := Location(s)
+	c.Assert(e, check.IsNil)
+	c.Check(loc, check.Equals, time.Local)
+
+	c.Assert(s.Set(TIMEZONE, "America/Los_Angeles", status.UNCHECKED_REVISION), check.IsNil)
+	loc, e = Location(s)
+	c.Assert(e, check.IsNil)
+	c.Check(loc.String(), check.Equals, "America/Los_Angeles")
+
+	// The loaded timezone is reused, until the name changes.
+	again, e := Location(s)
+	c.Assert(e, check.IsNil)
+	c.Check(again, check.Equals, loc)
+
+	c.Assert(s.Set(TIMEZONE, "Europe/Berlin", status.UNCHECKED_REVISION), check.IsNil)
+	loc, e = Location(s)
+	c.Assert(e, check.IsNil)
+	c.Check(loc.String(), check.Equals, "Europe/Berlin")
+
+	c.Assert(s.Set(TIMEZONE, "Nowhere/Special", status.UNCHECKED_REVISION), check.IsNil)
+	_, e = Location(s)
+	c.Check(e, check.NotNil)
+	c.Check(CheckTimezone(s), check.NotNil)
+}
+
+func (suite *MySuite) TestFormatTime(c *check.C) {
+	s := &status.Status{}
+	c.Assert(s.Set(TIMEZONE, "Europe/Berlin", status.UNCHECKED_REVISION), check.IsNil)
+
+	t := time.Date(2014, time.June, 12, 12, 0, 0, 0, time.UTC)
+	c.Check(FormatTime(s, t), check.Equals, "2014-06-12T14:00:00+02:00")
+}
+
+func (suite *MySuite) TestCheckTimezone(c *check.C) {
+	original := time.Local
+
+	s := &status.Status{}
+	c.Assert(s.Set(TIMEZONE, "Europe/Berlin", status.UNCHECKED_REVISION), check.IsNil)
+	c.Check(CheckTimezone(s), check.IsNil)
+
+	// The process timezone isn't changed.
+	c.Check(time.Local, check.Equals, original)
+}
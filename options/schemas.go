@@ -0,0 +1,146 @@
+package options
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/status"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+)
+
+// Directory (inside the config dir) holding user supplied schemas.
+const SCHEMAS_DIR_NAME = "schemas"
+
+// A condition is a redirect URL, a list (shorthand for "and"), or a body with
+// a "test".
+const conditionSchema = `{
+  "anyOf": [
+    {"type": "string", "pattern": "^status://"},
+    {"type": "array"},
+    {"type": "object", "required": ["test"], "properties": {"test": {"type": "string"}}}
+  ]
+}`
+
+// An action is a redirect URL, a list of actions, or a body with an "action".
+const actionSchema = `{
+  "anyOf": [
+    {"type": "string", "pattern": "^(status|http|https)://"},
+    {"type": "array"},
+    {"type": "object", "required": ["action"], "properties": {"action": {"type": "string"}}}
+  ]
+}`
+
+// Built in schemas, by the URLs they apply to. These only log problems, since
+// rejecting them would reject an entire config file.
+var builtinSchemas = map[string]string{
+	"status://*/rule/*": `{
+  "type": "object",
+  "required": ["condition"],
+  "properties": {
+    "condition": ` + conditionSchema + `,
+    "on": ` + actionSchema + `,
+    "off": ` + actionSchema + `,
+    "enabled": {"type": "boolean"},
+    "snooze_until": {"type": ["string", "null"]},
+    "fire_on_reload": {"type": "boolean"}
+  },
+  "additionalProperties": false,
+  "anyOf": [{"required": ["on"]}, {"required": ["off"]}]
+}`,
+
+	"status://*/property/*": `{
+  "type": "object",
+  "required": ["target", "values"],
+  "properties": {
+    "target": {"type": "string", "pattern": "^status://"},
+    "values": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["condition", "value"],
+        "properties": {"condition": ` + conditionSchema + `, "value": true},
+        "additionalProperties": false
+      }
+    },
+    "default": {"type": "string"}
+  },
+  "additionalProperties": false
+}`,
+
+	"status://*/rgb/*": `{
+  "type": "object",
+  "properties": {
+    "color": {"type": "string", "pattern": "^[0-9]+,[0-9]+,[0-9]+$"},
+    "color_target": {
+      "anyOf": [
+        {"type": "null"},
+        {"type": "string", "pattern": "^[0-9]+,[0-9]+,[0-9]+$"}
+      ]
+    }
+  }
+}`,
+}
+
+// A user supplied schema file.
+//
+//	{ "url": "status://...", "reject": true, "schema": { ... } }
+//
+// "reject" defaults to true.
+func loadSchemaFile(s *status.Status, filename string) (e error) {
+	contents, e := ioutil.ReadFile(filename)
+	if e != nil {
+		return e
+	}
+
+	body := &status.Status{}
+	if e = body.SetJson("status://", contents, 0); e != nil {
+		return fmt.Errorf("%s: %s", filename, e.Error())
+	}
+
+	url, _, e := body.GetString("status://url")
+	if e != nil {
+		return fmt.Errorf("%s has no 'url'.", filename)
+	}
+
+	schema, _, e := body.Get("status://schema")
+	if e != nil {
+		return fmt.Errorf("%s has no 'schema'.", filename)
+	}
+
+	reject := body.GetBoolWithDefault("status://reject", true)
+
+	if e = s.AddSchema(url, schema, reject); e != nil {
+		return fmt.Errorf("%s: %s", filename, e.Error())
+	}
+
+	return nil
+}
+
+// Register the built in schemas, and any in the schemas directory of the
+// config dir.
+func InitializeSchemas(s *status.Status) (e error) {
+	for url, schema := range builtinSchemas {
+		if e = s.AddSchemaJson(url, []byte(schema), false); e != nil {
+			return e
+		}
+	}
+
+	configDir, _, e := s.GetString(CONFIG_DIR)
+	if e != nil {
+		return e
+	}
+
+	filenames, e := filepath.Glob(filepath.Join(configDir, SCHEMAS_DIR_NAME, "*.json"))
+	if e != nil {
+		return e
+	}
+
+	for _, filename := range filenames {
+		if e = loadSchemaFile(s, filename); e != nil {
+			return e
+		}
+		log.Println("Schema:        ", filename)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package options
+
+import (
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func (suite *MySuite) TestInitializeSchemas(c *check.C) {
+	configDir := c.MkDir()
+
+	schemasDir := filepath.Join(configDir, SCHEMAS_DIR_NAME)
+	c.Assert(os.Mkdir(schemasDir, os.ModePerm), check.IsNil)
+
+	e := ioutil.WriteFile(filepath.Join(schemasDir, "phone.json"), []byte(`
+		{
+			"url": "status://*/phone/*",
+			"schema": {"type": "object", "required": ["present"]}
+		}`), os.ModePerm)
+	c.Assert(e, check.IsNil)
+
+	s := &status.Status{}
+	c.Assert(s.Set(CONFIG_DIR, configDir, 0), check.IsNil)
+	c.Assert(InitializeSchemas(s), check.IsNil)
+
+	// User schemas reject by default.
+	c.Check(s.SetJson("status://web/phone/mine", []byte(`{"present": true}`), status.UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.SetJson("status://web/phone/mine", []byte(`{"here": true}`), status.UNCHECKED_REVISION), check.NotNil)
+
+	// Built in schemas only log.
+	c.Check(s.SetJson("status://config/rule/bad", []byte(`{"conditon": {}}`), status.UNCHECKED_REVISION), check.IsNil)
+}
+
+func (suite *MySuite) TestBuiltinSchemas(c *check.C) {
+	// Check the built in schemas in rejecting mode, so we can see failures.
+	s := &status.Status{}
+	for url, schema := range builtinSchemas {
+		c.Assert(s.AddSchemaJson(url, []byte(schema), true), check.IsNil)
+	}
+
+	good := map[string]string{
+		"status://a/rule/r": `{
+			"condition": {"test": "daily", "time": "10:00"},
+			"on": {"action": "set", "component": "status://a/b", "dest": "c", "value": 1}
+		}`,
+		"status://a/rule/redirect": `{"condition": "status://a/cond", "off": "status://a/action"}`,
+		"status://a/rule/list":     `{"condition": [{"test": "a"}, {"test": "b"}], "on": []}`,
+		"status://a/rule/snoozed": `{
+			"condition": {"test": "a"},
+			"on": [],
+			"enabled": false,
+			"snooze_until": "2014-06-12T10:00:00Z",
+			"fire_on_reload": false
+		}`,
+		"status://a/property/p": `{
+			"target": "status://a/b/c",
+			"values": [{"value": 1, "condition": {"test": "a"}}],
+			"default": "x"
+		}`,
+		"status://a/rgb/strip": `{"color": "0,1,0", "color_target": null, "other": 5}`,
+	}
+
+	for url, value := range good {
+		c.Check(s.SetJson(url, []byte(value), status.UNCHECKED_REVISION), check.IsNil, check.Commentf(url))
+	}
+
+	bad := map[string]string{
+		"status://a/rule/r":      `{"condition": {"test": "a"}}`,
+		"status://a/rule/typo":   `{"conditon": {"test": "a"}, "on": []}`,
+		"status://a/rule/test":   `{"condition": {"tset": "a"}, "on": []}`,
+		"status://a/rule/action": `{"condition": {"test": "a"}, "on": {"actoin": "set"}}`,
+		"status://a/rule/url":    `{"condition": "foo", "on": []}`,
+		"status://a/rule/flag":   `{"condition": {"test": "a"}, "on": [], "enabled": "yes"}`,
+		"status://a/property/p":  `{"target": "status://a/b/c", "values": [{"value": 1}]}`,
+		"status://a/property/q":  `{"target": "status://a/b/c", "vales": []}`,
+		"status://a/rgb/strip":   `{"color": "red"}`,
+	}
+
+	for url, value := range bad {
+		c.Check(s.SetJson(url, []byte(value), status.UNCHECKED_REVISION), check.NotNil, check.Commentf(url))
+	}
+}
@@ -0,0 +1,243 @@
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"github.com/DonGar/go-house/engine"
+	"github.com/DonGar/go-house/engine/actions"
+	"github.com/DonGar/go-house/options"
+	"github.com/DonGar/go-house/status"
+	"io"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A single entry in a simulation timeline.
+type Event struct {
+	Time  time.Time   `json:"time"`
+	Kind  string      `json:"kind"`  // "rule" or "action".
+	Name  string      `json:"name"`  // The rule, or the origin of the action.
+	Value interface{} `json:"value"` // The new condition value, or the action.
+}
+
+func (ev Event) String() string {
+	valueJson, _ := json.Marshal(ev.Value)
+	return fmt.Sprintf("%s %-6s %s %s", ev.Time.Format(time.RFC3339), ev.Kind, ev.Name, valueJson)
+}
+
+// Load server.json and the contents of file adapters from configDir, which is
+// everything needed to create rules and properties. Other adapters aren't
+// started, their values come from the replayed changes.
+func LoadConfig(s *status.Status, configDir string) (e error) {
+	if e = options.IntializeServerConfig(s, []string{"go-house", "-config_dir", configDir}); e != nil {
+		return e
+	}
+
+	if e = options.InitializeSchemas(s); e != nil {
+		return e
+	}
+
+	names, e := fileAdapterNames(s)
+	if e != nil {
+		return e
+	}
+
+	for _, name := range names {
+		filename := s.GetStringWithDefault(options.ADAPTERS+"/file/"+name+"/filename", name+".json")
+		rawJson, e := ioutil.ReadFile(filepath.Join(configDir, filename))
+		if e != nil {
+			return e
+		}
+
+		e = s.UpdateFrom("adapter:"+name, func(tx *status.Tx) error {
+			return tx.SetJson("status://"+name, rawJson, status.UNCHECKED_REVISION)
+		})
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func fileAdapterNames(s *status.Status) ([]string, error) {
+	if _, _, e := s.Get(options.ADAPTERS + "/file"); e != nil {
+		// No file adapters configured.
+		return []string{}, nil
+	}
+
+	names, _, e := s.GetChildNames(options.ADAPTERS + "/file")
+	return names, e
+}
+
+// Read recorded changes. Accepts the output of the /audit/ handler, or a
+// plain list of entries.
+func ReadChanges(r io.Reader) (changes []status.AuditEntry, e error) {
+	rawJson, e := ioutil.ReadAll(r)
+	if e != nil {
+		return nil, e
+	}
+
+	if strings.HasPrefix(strings.TrimSpace(string(rawJson)), "{") {
+		audit := struct {
+			Audit []status.AuditEntry `json:"audit"`
+		}{}
+		e = json.Unmarshal(rawJson, &audit)
+		return audit.Audit, e
+	}
+
+	e = json.Unmarshal(rawJson, &changes)
+	return changes, e
+}
+
+// Run the rules and properties in s against changes, from start until end,
+// with a virtual clock. If start or end are zero, the times of the first and
+// last changes are used. Actions are recorded in the timeline, instead of
+// being performed, except for "set" actions, which only change s and so are
+// applied too. Changes before start are applied when the simulation starts.
+func Run(s *status.Status, changes []status.AuditEntry, start, end time.Time) (timeline []Event, e error) {
+	changes, e = replayable(s, changes)
+	if e != nil {
+		return nil, e
+	}
+
+	if start.IsZero() && len(changes) > 0 {
+		start = changes[0].Time
+	}
+	if end.IsZero() && len(changes) > 0 {
+		end = changes[len(changes)-1].Time
+	}
+	if start.IsZero() || end.IsZero() {
+		return nil, fmt.Errorf("Simulate: No start or end time.")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("Simulate: End %s is before start %s.", end, start)
+	}
+
+	// The clock counts the events sent to the engine's handlers, so we can wait
+	// for each change to be fully handled before moving on.
+	clk := clock.NewFake(start)
+	clk.Track()
+	s.SetClock(clk)
+	r := &recorder{clock: clk}
+
+	eng, e := engine.NewObservedEngine(s, clk, actions.NewRecordingManager(clk, r.action), r.rule)
+	if e != nil {
+		return nil, e
+	}
+	defer eng.Stop()
+
+	clk.Wait()
+
+	for _, change := range changes {
+		if change.Time.After(end) {
+			break
+		}
+
+		clk.Set(change.Time)
+		if e := applyChange(s, change); e != nil {
+			log.Printf("Simulate: Skipping change to %s: %s", change.Url, e.Error())
+			continue
+		}
+		clk.Wait()
+	}
+
+	clk.Set(end)
+
+	return r.timeline(), nil
+}
+
+// Sort changes by time, and drop changes made by things the simulation
+// recreates: rules, properties, engine state, solar times, the server config
+// and file adapters.
+func replayable(s *status.Status, changes []status.AuditEntry) ([]status.AuditEntry, error) {
+	skipOrigins := map[string]bool{"solar": true, options.ENGINE_ORIGIN: true}
+
+	names, e := fileAdapterNames(s)
+	if e != nil {
+		return nil, e
+	}
+	for _, name := range names {
+		skipOrigins["adapter:"+name] = true
+	}
+
+	result := []status.AuditEntry{}
+	for _, change := range changes {
+		skip := skipOrigins[change.Origin] ||
+			strings.HasPrefix(change.Origin, "rule:") ||
+			strings.HasPrefix(change.Origin, "property:") ||
+			change.Url == "status://server" ||
+			strings.HasPrefix(change.Url, "status://server/")
+
+		if !skip {
+			result = append(result, change)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result, nil
+}
+
+func applyChange(s *status.Status, change status.AuditEntry) error {
+	switch change.Op {
+	case "set":
+		return s.SetFrom(change.Origin, change.Url, change.Value, status.UNCHECKED_REVISION)
+	case "remove":
+		return s.RemoveFrom(change.Origin, change.Url, status.UNCHECKED_REVISION)
+	case "insert":
+		return s.UpdateFrom(change.Origin, func(tx *status.Tx) error {
+			return tx.Insert(change.Url, change.Value, status.UNCHECKED_REVISION)
+		})
+	default:
+		return fmt.Errorf("Unknown op: %s", change.Op)
+	}
+}
+
+// Collects timeline events from the engine's goroutines.
+type recorder struct {
+	lock   sync.Mutex
+	clock  clock.Clock
+	events []Event
+}
+
+func (r *recorder) add(kind, name string, value interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events = append(r.events, Event{r.clock.Now(), kind, name, value})
+}
+
+func (r *recorder) rule(name string, value bool) {
+	r.add("rule", name, value)
+}
+
+func (r *recorder) action(s *status.Status, origin string, action *status.Status) error {
+	value, _, e := action.Get("status://")
+	if e != nil {
+		return e
+	}
+
+	r.add("action", origin, value)
+	return nil
+}
+
+// The recorded events, in time order.
+func (r *recorder) timeline() []Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := append([]Event{}, r.events...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result
+}
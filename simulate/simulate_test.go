@@ -0,0 +1,189 @@
+package simulate
+
+import (
+	"github.com/DonGar/go-house/status"
+	"gopkg.in/check.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { check.TestingT(t) }
+
+type MySuite struct{}
+
+var _ = check.Suite(&MySuite{})
+
+// Write a config dir with a file adapter holding rules, and load it.
+func setupConfig(c *check.C) *status.Status {
+	configDir := c.MkDir()
+
+	files := map[string]string{
+		"server.json": `
+			{
+				"timezone": "UTC",
+				"adapters": {
+					"file": {
+						"house": {}
+					}
+				}
+			}`,
+		"house.json": `
+			{
+				"rule": {
+					"motion": {
+						"condition": {
+							"test": "compare",
+							"watch": "status://web/motion",
+							"op": "==",
+							"value": true
+						},
+						"on": {"action": "set", "component": "status://web", "dest": "light", "value": "on"},
+						"off": {"action": "set", "component": "status://web", "dest": "light", "value": "off"}
+					},
+					"lit": {
+						"condition": {
+							"test": "compare",
+							"watch": "status://web/light",
+							"op": "==",
+							"value": "on"
+						},
+						"on": "status://house/action/alert"
+					},
+					"evening": {
+						"condition": {
+							"test": "between",
+							"start": "20:00",
+							"end": "21:00"
+						},
+						"on": ["status://house/action/alert", "status://house/action/alert"]
+					}
+				},
+				"action": {
+					"alert": {"action": "email", "to": "someone@example.com"}
+				}
+			}`,
+	}
+
+	for name, contents := range files {
+		e := ioutil.WriteFile(filepath.Join(configDir, name), []byte(contents), os.ModePerm)
+		c.Assert(e, check.IsNil)
+	}
+
+	s := &status.Status{}
+	e := LoadConfig(s, configDir)
+	c.Assert(e, check.IsNil)
+
+	return s
+}
+
+// The events for a single rule, or a single action origin.
+func eventsFor(timeline []Event, kind, name string) []Event {
+	result := []Event{}
+	for _, ev := range timeline {
+		if ev.Kind == kind && ev.Name == name {
+			result = append(result, ev)
+		}
+	}
+	return result
+}
+
+func (suite *MySuite) TestLoadConfig(c *check.C) {
+	s := setupConfig(c)
+
+	names, _, e := s.GetChildNames("status://house/rule")
+	c.Check(e, check.IsNil)
+	c.Check(names, check.HasLen, 3)
+}
+
+func (suite *MySuite) TestReadChanges(c *check.C) {
+	audit := `{"audit": [{"time": "2014-06-01T12:00:00Z", "revision": 3, "origin": "adapter:web", "op": "set", "url": "status://web/motion", "value": true}]}`
+	list := `[{"time": "2014-06-01T12:00:00Z", "revision": 3, "origin": "adapter:web", "op": "set", "url": "status://web/motion", "value": true}]`
+
+	for _, input := range []string{audit, list} {
+		changes, e := ReadChanges(strings.NewReader(input))
+		c.Check(e, check.IsNil)
+		c.Check(changes, check.DeepEquals, []status.AuditEntry{
+			{Time: time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC), Revision: 3, Origin: "adapter:web", Op: "set", Url: "status://web/motion", Value: true},
+		})
+	}
+
+	_, e := ReadChanges(strings.NewReader("bogus"))
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestRun(c *check.C) {
+	s := setupConfig(c)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2014, 6, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	changes := []status.AuditEntry{
+		// Out of order, to verify sorting.
+		{Time: at(12, 10), Origin: "adapter:web", Op: "set", Url: "status://web/motion", Value: false},
+		{Time: at(12, 0), Origin: "adapter:web", Op: "set", Url: "status://web/motion", Value: true},
+		// Made by rules and the file adapter, and so skipped.
+		{Time: at(12, 5), Origin: "rule:motion", Op: "set", Url: "status://web/motion", Value: false},
+		{Time: at(12, 5), Origin: "adapter:house", Op: "set", Url: "status://house/rule", Value: nil},
+	}
+
+	timeline, e := Run(s, changes, time.Time{}, at(22, 0))
+	c.Assert(e, check.IsNil)
+
+	for i := 1; i < len(timeline); i++ {
+		c.Check(timeline[i].Time.Before(timeline[i-1].Time), check.Equals, false)
+	}
+
+	motion := eventsFor(timeline, "rule", "motion")
+	c.Assert(motion, check.HasLen, 3)
+	c.Check(motion[0].Value, check.Equals, false)
+	c.Check(motion[1], check.DeepEquals, Event{at(12, 0), "rule", "motion", true})
+	c.Check(motion[2], check.DeepEquals, Event{at(12, 10), "rule", "motion", false})
+
+	motionActions := eventsFor(timeline, "action", "rule:motion")
+	c.Assert(motionActions, check.HasLen, 3)
+	c.Check(motionActions[1].Value, check.DeepEquals, map[string]interface{}{
+		"action": "set", "component": "status://web", "dest": "light", "value": "on"})
+
+	// Sets are applied, and other rules react to them.
+	light, _, e := s.Get("status://web/light")
+	c.Check(e, check.IsNil)
+	c.Check(light, check.Equals, "off")
+
+	lit := eventsFor(timeline, "rule", "lit")
+	c.Check(lit, check.DeepEquals, []Event{
+		{at(12, 0), "rule", "lit", false},
+		{at(12, 0), "rule", "lit", true},
+		{at(12, 10), "rule", "lit", false},
+	})
+
+	evening := eventsFor(timeline, "rule", "evening")
+	c.Check(evening, check.DeepEquals, []Event{
+		{at(12, 0), "rule", "evening", false},
+		{at(20, 0), "rule", "evening", true},
+		{at(21, 0), "rule", "evening", false},
+	})
+
+	// Redirections and lists are followed.
+	alerts := eventsFor(timeline, "action", "rule:evening")
+	c.Assert(alerts, check.HasLen, 2)
+	c.Check(alerts[0].Time, check.Equals, at(20, 0))
+	c.Check(alerts[0].Value, check.DeepEquals, map[string]interface{}{
+		"action": "email", "to": "someone@example.com"})
+}
+
+func (suite *MySuite) TestRunNoTimes(c *check.C) {
+	s := setupConfig(c)
+
+	_, e := Run(s, []status.AuditEntry{}, time.Time{}, time.Time{})
+	c.Check(e, check.NotNil)
+
+	start := time.Date(2014, 6, 1, 12, 0, 0, 0, time.UTC)
+	_, e = Run(s, []status.AuditEntry{}, start, start.Add(-time.Hour))
+	c.Check(e, check.NotNil)
+}
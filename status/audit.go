@@ -0,0 +1,132 @@
+package status
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of changes kept in the audit log, unless SetAuditSize is called.
+const DEFAULT_AUDIT_SIZE = 1000
+
+// A single change recorded in the audit log.
+type AuditEntry struct {
+	Time     time.Time   `json:"time"`
+	Revision int         `json:"revision"`
+	Origin   string      `json:"origin"`
+	Op       string      `json:"op"` // "set", "remove", or "insert".
+	Url      string      `json:"url"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+type auditLog struct {
+	size    int
+	entries []AuditEntry // Oldest first.
+}
+
+var opNames = map[opKind]string{
+	opSet:    "set",
+	opRemove: "remove",
+	opInsert: "insert",
+}
+
+// Keep at most size recent changes in the audit log. 0 turns the audit log
+// off.
+func (s *Status) SetAuditSize(size int) (e error) {
+	if size < 0 {
+		return fmt.Errorf("Status: Invalid audit size: %d", size)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.audit == nil {
+		s.audit = &auditLog{}
+	}
+
+	s.audit.size = size
+	s.audit.prune()
+	return nil
+}
+
+// Fetch recorded changes at or below a wildcard URL, made at or after since,
+// oldest first. If origin isn't "", only changes from that origin are
+// returned. An origin ending in ":", like "rule:", matches every origin
+// starting with it. Predicates are checked against the current values.
+func (s *Status) GetAudit(url string, origin string, since time.Time) (entries []AuditEntry, e error) {
+	pattern, e := parsePattern(url)
+	if e != nil {
+		return nil, e
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	entries = []AuditEntry{}
+	if s.audit == nil {
+		return entries, nil
+	}
+
+	for _, entry := range s.audit.entries {
+		if entry.Time.Before(since) || !originMatch(origin, entry.Origin) {
+			continue
+		}
+
+		path, _ := parseUrl(entry.Url)
+		if !pathMatch(&s.node, pattern, path, true) {
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func originMatch(filter, origin string) bool {
+	if strings.HasSuffix(filter, ":") {
+		return strings.HasPrefix(origin, filter)
+	}
+	return filter == "" || filter == origin
+}
+
+// Record a group of applied changes. Called with the Status lock held.
+func (s *Status) recordAudit(applied []txOp, revision int, origin string) {
+	if s.audit == nil {
+		s.audit = &auditLog{size: DEFAULT_AUDIT_SIZE}
+	}
+
+	if s.audit.size == 0 {
+		return
+	}
+
+	now := s.getClock().Now()
+
+	for _, op := range applied {
+		path := op.pathParts
+		if op.kind == opInsert {
+			path = childPath(op.pathParts, strconv.Itoa(op.index))
+		}
+
+		// Copy the value, since the caller may modify it later. It's already
+		// been stored, so it's known to be valid.
+		var value interface{}
+		if op.kind != opRemove {
+			converted, _ := valueToStatusValue(op.value, 0)
+			value = statusValueToValue(converted)
+		}
+
+		s.audit.entries = append(s.audit.entries,
+			AuditEntry{now, revision, origin, opNames[op.kind], joinUrl(path), value})
+	}
+
+	s.audit.prune()
+}
+
+// Discard entries beyond our size limit.
+func (a *auditLog) prune() {
+	if len(a.entries) > a.size {
+		a.entries = a.entries[len(a.entries)-a.size:]
+	}
+}
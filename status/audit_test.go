@@ -0,0 +1,168 @@
+package status
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+func checkOrigin(c *check.C, status *Status, url string, origin string) {
+	matches, e := status.GetMatchingUrls(url)
+	c.Assert(e, check.IsNil)
+	c.Check(matches[url].Origin, check.Equals, origin, check.Commentf(url))
+}
+
+func (suite *MySuite) TestOrigins(c *check.C) {
+	status := &Status{}
+
+	c.Assert(status.SetFrom("rule:a", "status://a", map[string]interface{}{"b": 1, "c": 2}, 0), check.IsNil)
+	checkOrigin(c, status, "status://", "rule:a")
+	checkOrigin(c, status, "status://a/b", "rule:a")
+	checkOrigin(c, status, "status://a/c", "rule:a")
+
+	// Only nodes that change are attributed to the new origin.
+	c.Assert(status.SetFrom("adapter:b", "status://a", map[string]interface{}{"b": 1, "c": 3}, 1), check.IsNil)
+	checkOrigin(c, status, "status://a", "adapter:b")
+	checkOrigin(c, status, "status://a/b", "rule:a")
+	checkOrigin(c, status, "status://a/c", "adapter:b")
+
+	// Setting the same value changes nothing.
+	c.Assert(status.SetFrom("rule:c", "status://a/b", 1, UNCHECKED_REVISION), check.IsNil)
+	checkOrigin(c, status, "status://a/b", "rule:a")
+
+	// Changes without an origin clear it.
+	c.Assert(status.Set("status://a/b", 2, UNCHECKED_REVISION), check.IsNil)
+	checkOrigin(c, status, "status://a/b", "")
+	checkOrigin(c, status, "status://a/c", "adapter:b")
+
+	c.Assert(status.RemoveFrom("rule:d", "status://a/b", UNCHECKED_REVISION), check.IsNil)
+	checkOrigin(c, status, "status://a", "rule:d")
+
+	// Failed updates don't change origins.
+	e := status.UpdateFrom("rule:e", func(tx *Tx) error {
+		tx.Set("status://a/c", 5, UNCHECKED_REVISION)
+		return tx.Set("status://a/c/d", 5, UNCHECKED_REVISION)
+	})
+	c.Check(e, check.NotNil)
+	checkOrigin(c, status, "status://a", "rule:d")
+	checkOrigin(c, status, "status://a/c", "adapter:b")
+}
+
+func (suite *MySuite) TestOriginWatch(c *check.C) {
+	status := &Status{}
+
+	watch, e := status.WatchForUpdate("status://a")
+	c.Assert(e, check.IsNil)
+	checkPending(c, watch, UrlMatches{})
+
+	c.Assert(status.SetFrom("http:1.2.3.4:5", "status://a", 1, UNCHECKED_REVISION), check.IsNil)
+	checkPending(c, watch, UrlMatches{"status://a": UrlMatch{Revision: 1, Value: 1, Origin: "http:1.2.3.4:5"}})
+
+	status.ReleaseWatch(watch)
+}
+
+func (suite *MySuite) TestAudit(c *check.C) {
+	status := &Status{}
+	start := time.Now()
+
+	c.Assert(status.SetFrom("rule:a", "status://a/b", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.SetFrom("rule:b", "status://a/c", []interface{}{}, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.UpdateFrom("adapter:x", func(tx *Tx) error {
+		tx.Append("status://a/c", "x", UNCHECKED_REVISION)
+		return tx.Remove("status://a/b", UNCHECKED_REVISION)
+	}), check.IsNil)
+
+	// Unchanged values aren't recorded.
+	c.Assert(status.SetFrom("rule:a", "status://a/c/0", "x", UNCHECKED_REVISION), check.IsNil)
+
+	entries, e := status.GetAudit("status://", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Assert(len(entries), check.Equals, 4)
+
+	c.Check(entries[0].Time.Before(start), check.Equals, false)
+	for i := range entries {
+		entries[i].Time = time.Time{}
+	}
+
+	c.Check(entries, check.DeepEquals, []AuditEntry{
+		{time.Time{}, 1, "rule:a", "set", "status://a/b", 1},
+		{time.Time{}, 2, "rule:b", "set", "status://a/c", []interface{}{}},
+		{time.Time{}, 3, "adapter:x", "insert", "status://a/c/0", "x"},
+		{time.Time{}, 3, "adapter:x", "remove", "status://a/b", nil},
+	})
+
+	// Filter by url.
+	entries, e = status.GetAudit("status://a/c", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 2)
+
+	entries, e = status.GetAudit("status://*/b", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 2)
+
+	// Filter by origin.
+	entries, e = status.GetAudit("status://", "rule:b", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 1)
+
+	entries, e = status.GetAudit("status://", "rule:", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 2)
+
+	// Filter by time.
+	entries, e = status.GetAudit("status://", "", time.Now().Add(time.Hour))
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 0)
+
+	_, e = status.GetAudit("bogus", "", time.Time{})
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestAuditPatterns(c *check.C) {
+	now := time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC)
+
+	status := &Status{}
+	status.SetClock(clock.NewFake(now))
+
+	c.Assert(status.Set("status://vera/den/motion", map[string]interface{}{"tripped": true}, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.Set("status://vera/hall/motion", map[string]interface{}{"tripped": false}, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.Set("status://vera/hall/motion/tripped", true, UNCHECKED_REVISION), check.IsNil)
+
+	entries, e := status.GetAudit("status://**/tripped", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(entries, check.DeepEquals, []AuditEntry{
+		{now, 3, "", "set", "status://vera/hall/motion/tripped", true},
+	})
+
+	entries, e = status.GetAudit("status://vera/*/motion[tripped=true]", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 3)
+
+	_, e = status.GetAudit("status://*[bad", "", time.Time{})
+	c.Check(e, check.NotNil)
+}
+
+func (suite *MySuite) TestAuditSize(c *check.C) {
+	status := &Status{}
+
+	c.Check(status.SetAuditSize(-1), check.NotNil)
+	c.Assert(status.SetAuditSize(2), check.IsNil)
+
+	for i := 0; i < 5; i++ {
+		c.Assert(status.Set("status://a", i, UNCHECKED_REVISION), check.IsNil)
+	}
+
+	entries, e := status.GetAudit("status://", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Assert(len(entries), check.Equals, 2)
+	c.Check(entries[0].Value, check.Equals, 3)
+	c.Check(entries[1].Value, check.Equals, 4)
+
+	// Turn it off.
+	c.Assert(status.SetAuditSize(0), check.IsNil)
+	c.Assert(status.Set("status://a", 5, UNCHECKED_REVISION), check.IsNil)
+
+	entries, e = status.GetAudit("status://", "", time.Time{})
+	c.Assert(e, check.IsNil)
+	c.Check(len(entries), check.Equals, 0)
+}
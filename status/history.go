@@ -0,0 +1,217 @@
+package status
+
+import (
+	"fmt"
+	"time"
+)
+
+// A single recorded value for a status URL. If the URL was removed, Value is
+// nil.
+type HistoryEntry struct {
+	Time     time.Time   `json:"time"`
+	Revision int         `json:"revision"`
+	Value    interface{} `json:"value"`
+}
+
+// This is a map of status URLs to the recorded values for each, oldest first.
+type HistoryMatches map[string][]HistoryEntry
+
+// Numeric summary of the values recorded during a single period of time.
+type HistoryBucket struct {
+	Start time.Time `json:"start"`
+	Count int       `json:"count"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+}
+
+type history struct {
+	pattern  []string      // Wildcard URL path to record.
+	maxCount int           // Max entries to keep per URL. 0 for no limit.
+	maxAge   time.Duration // Max age of entries to keep. 0 for no limit.
+	lastSeen map[string]int
+	entries  HistoryMatches
+}
+
+// Start recording the values of all URLs matching a wildcard URL. At most
+// maxCount values no older than maxAge are kept for each matching URL. Zero
+// means no limit.
+func (s *Status) RecordHistory(url string, maxCount int, maxAge time.Duration) (e error) {
+	pattern, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	// Make sure any wildcards and predicates are valid.
+	if _, e := parsePattern(url); e != nil {
+		return e
+	}
+
+	if maxCount < 0 || maxAge < 0 {
+		return fmt.Errorf("Status: Invalid history limits for %s", url)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	h := &history{pattern, maxCount, maxAge, map[string]int{}, HistoryMatches{}}
+	s.histories = append(s.histories, h)
+	s.watchIndex.add(pattern, h)
+
+	// Record the current values, as the starting point.
+	h.checkForUpdate(s)
+
+	return nil
+}
+
+// Fetch the recorded values for every recorded URL matching a wildcard URL,
+// which were recorded at or after since. Predicates are checked against the
+// current values, so they don't match URLs which have been removed.
+func (s *Status) GetHistory(url string, since time.Time) (matches HistoryMatches, e error) {
+	pattern, e := parsePattern(url)
+	if e != nil {
+		return nil, e
+	}
+
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	matches = HistoryMatches{}
+
+	for _, h := range s.histories {
+		for entryUrl, entries := range h.entries {
+			// If more than one history records a URL, the first wins.
+			if _, ok := matches[entryUrl]; ok {
+				continue
+			}
+
+			entryPath, _ := parseUrl(entryUrl)
+			if !pathMatch(&s.node, pattern, entryPath, false) {
+				continue
+			}
+
+			// Copy, since the recorded slice changes after we unlock.
+			found := []HistoryEntry{}
+			for _, entry := range entries {
+				if !entry.Time.Before(since) {
+					found = append(found, entry)
+				}
+			}
+
+			matches[entryUrl] = found
+		}
+	}
+
+	return matches, nil
+}
+
+// Summarize numeric history values into buckets of a fixed duration, starting
+// with the bucket holding the first entry. Non-numeric values are skipped, and
+// buckets without any values are left out.
+func Downsample(entries []HistoryEntry, bucketSize time.Duration) (buckets []HistoryBucket, e error) {
+	if bucketSize <= 0 {
+		return nil, fmt.Errorf("Status: Invalid bucket size: %s", bucketSize)
+	}
+
+	buckets = []HistoryBucket{}
+	var total float64
+
+	for _, entry := range entries {
+		value, ok := numericValue(entry.Value)
+		if !ok {
+			continue
+		}
+
+		start := entry.Time.Truncate(bucketSize)
+
+		last := len(buckets) - 1
+		if last < 0 || !buckets[last].Start.Equal(start) {
+			buckets = append(buckets, HistoryBucket{start, 0, value, value, 0})
+			last += 1
+			total = 0
+		}
+
+		b := &buckets[last]
+		b.Count += 1
+		total += value
+		b.Avg = total / float64(b.Count)
+		if value < b.Min {
+			b.Min = value
+		}
+		if value > b.Max {
+			b.Max = value
+		}
+	}
+
+	return buckets, nil
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch t := value.(type) {
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	case float64:
+		return t, true
+	case bool:
+		if t {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Record any values that changed since we last looked. Called with the Status
+// lock held, after changes the history's URL may match.
+func (h *history) checkForUpdate(status *Status) {
+	matches, e := status.getMatchingUrls(joinUrl(h.pattern))
+	if e != nil {
+		panic(e) // This is supposed to be impossible.
+	}
+
+	now := status.getClock().Now()
+
+	for url, match := range matches {
+		if revision, ok := h.lastSeen[url]; ok && revision == match.Revision {
+			continue
+		}
+
+		h.lastSeen[url] = match.Revision
+		h.entries[url] = append(h.entries[url], HistoryEntry{now, match.Revision, match.Value})
+	}
+
+	// Record removal of values that no longer exist.
+	for url := range h.lastSeen {
+		if _, ok := matches[url]; !ok {
+			delete(h.lastSeen, url)
+			h.entries[url] = append(h.entries[url], HistoryEntry{now, status.revision, nil})
+		}
+	}
+
+	h.prune(now)
+}
+
+// Discard entries beyond our count and age limits.
+func (h *history) prune(now time.Time) {
+	for url, entries := range h.entries {
+		if h.maxCount != 0 && len(entries) > h.maxCount {
+			entries = entries[len(entries)-h.maxCount:]
+		}
+
+		if h.maxAge != 0 {
+			oldest := now.Add(-h.maxAge)
+			for len(entries) > 0 && entries[0].Time.Before(oldest) {
+				entries = entries[1:]
+			}
+		}
+
+		if len(entries) == 0 {
+			delete(h.entries, url)
+		} else {
+			h.entries[url] = entries
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package status
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+// Extract just the values from history entries, since times vary.
+func historyValues(entries []HistoryEntry) []interface{} {
+	values := []interface{}{}
+	for _, entry := range entries {
+		values = append(values, entry.Value)
+	}
+	return values
+}
+
+func (suite *MySuite) TestHistoryRecord(c *check.C) {
+	status := &Status{}
+
+	c.Check(status.RecordHistory("bogus", 0, 0), check.NotNil)
+	c.Check(status.RecordHistory("status://*/temp", -1, 0), check.NotNil)
+
+	c.Assert(status.Set("status://kitchen/temp", 70, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.RecordHistory("status://*/temp", 0, 0), check.IsNil)
+
+	// Initial values are recorded right away.
+	history, e := status.GetHistory("status://*/temp", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 1)
+	c.Check(historyValues(history["status://kitchen/temp"]), check.DeepEquals, []interface{}{70})
+
+	c.Check(status.Set("status://kitchen/temp", 71, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://kitchen/other", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://den/temp", 65, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://kitchen/temp", 72, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Remove("status://den", UNCHECKED_REVISION), check.IsNil)
+
+	history, e = status.GetHistory("status://*/temp", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 2)
+	c.Check(historyValues(history["status://kitchen/temp"]), check.DeepEquals, []interface{}{70, 71, 72})
+	c.Check(historyValues(history["status://den/temp"]), check.DeepEquals, []interface{}{65, nil})
+
+	// Revisions are recorded with values.
+	c.Check(history["status://kitchen/temp"][1].Revision, check.Equals, 2)
+
+	// Look up a single URL.
+	history, e = status.GetHistory("status://den/temp", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 1)
+
+	// Nothing is newer than the future.
+	history, e = status.GetHistory("status://kitchen/temp", time.Now().Add(time.Hour))
+	c.Check(e, check.IsNil)
+	c.Check(history["status://kitchen/temp"], check.DeepEquals, []HistoryEntry{})
+
+	// URLs not recorded have no history.
+	history, e = status.GetHistory("status://kitchen/other", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 0)
+}
+
+func (suite *MySuite) TestHistoryLimits(c *check.C) {
+	start := time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC)
+	clk := clock.NewFake(start)
+
+	status := &Status{}
+	status.SetClock(clk)
+
+	c.Assert(status.RecordHistory("status://count", 3, 0), check.IsNil)
+	c.Assert(status.RecordHistory("status://aged", 0, time.Minute), check.IsNil)
+
+	for i := 0; i < 5; i++ {
+		c.Check(status.Set("status://count", i, UNCHECKED_REVISION), check.IsNil)
+	}
+
+	history, e := status.GetHistory("status://count", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(historyValues(history["status://count"]), check.DeepEquals, []interface{}{2, 3, 4})
+
+	c.Check(status.Set("status://aged", 1, UNCHECKED_REVISION), check.IsNil)
+	clk.Advance(5 * time.Minute)
+	c.Check(status.Set("status://aged", 2, UNCHECKED_REVISION), check.IsNil)
+
+	history, e = status.GetHistory("status://aged", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(history["status://aged"], check.DeepEquals, []HistoryEntry{{start.Add(5 * time.Minute), 7, 2}})
+}
+
+func (suite *MySuite) TestHistoryPatterns(c *check.C) {
+	status := &Status{}
+
+	e := status.SetJson("status://", []byte(`
+		{
+			"house": {
+				"den": {"motion": {"tripped": true}},
+				"hall": {"motion": {"tripped": false}}
+			},
+			"other": {"motion": 1}
+		}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	c.Assert(status.RecordHistory("status://house/**/motion", 0, 0), check.IsNil)
+	c.Assert(status.RecordHistory("status://bogus/*[a=b]", 0, 0), check.IsNil)
+	c.Check(status.RecordHistory("status://house/*[bad", 0, 0), check.NotNil)
+
+	history, e := status.GetHistory("status://**", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 2)
+
+	history, e = status.GetHistory("status://house/**/motion", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 2)
+
+	// Predicates are checked against current values.
+	history, e = status.GetHistory("status://house/*/motion[tripped=true]", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 1)
+	c.Check(history["status://house/den/motion"], check.NotNil)
+
+	c.Check(status.Set("status://house/den/motion/tripped", false, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://house/hall/motion/tripped", true, UNCHECKED_REVISION), check.IsNil)
+
+	history, e = status.GetHistory("status://house/*/motion[tripped=true]", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history), check.Equals, 1)
+	c.Check(len(history["status://house/hall/motion"]), check.Equals, 2)
+
+	_, e = status.GetHistory("status://house/*[bad", time.Time{})
+	c.Check(e, check.NotNil)
+}
+
+// Histories aren't rechecked by changes to unrelated URLs.
+func (suite *MySuite) TestHistoryUnrelatedChange(c *check.C) {
+	status := &Status{}
+
+	c.Assert(status.Set("status://a/b", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.RecordHistory("status://a/*", 0, 0), check.IsNil)
+
+	// Make the history's view of the world stale, so any check would record.
+	status.histories[0].lastSeen = map[string]int{}
+
+	c.Check(status.Set("status://c/b", 1, UNCHECKED_REVISION), check.IsNil)
+
+	history, e := status.GetHistory("status://a/b", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history["status://a/b"]), check.Equals, 1)
+
+	c.Check(status.Set("status://a/d", 1, UNCHECKED_REVISION), check.IsNil)
+
+	history, e = status.GetHistory("status://a/*", time.Time{})
+	c.Check(e, check.IsNil)
+	c.Check(len(history["status://a/b"]), check.Equals, 2)
+	c.Check(len(history["status://a/d"]), check.Equals, 1)
+}
+
+func (suite *MySuite) TestHistoryDownsample(c *check.C) {
+	start := time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC)
+
+	entries := []HistoryEntry{
+		{start, 1, 10},
+		{start.Add(10 * time.Minute), 2, 20.0},
+		{start.Add(20 * time.Minute), 3, "skipped"},
+		{start.Add(30 * time.Minute), 4, 60},
+		{start.Add(3 * time.Hour), 5, 5},
+	}
+
+	buckets, e := Downsample(entries, time.Hour)
+	c.Check(e, check.IsNil)
+	c.Check(buckets, check.DeepEquals, []HistoryBucket{
+		{start, 3, 10, 60, 30},
+		{start.Add(3 * time.Hour), 1, 5, 5, 5},
+	})
+
+	buckets, e = Downsample([]HistoryEntry{}, time.Hour)
+	c.Check(e, check.IsNil)
+	c.Check(buckets, check.DeepEquals, []HistoryBucket{})
+
+	_, e = Downsample(entries, 0)
+	c.Check(e, check.NotNil)
+}
@@ -0,0 +1,235 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A single parsed element of a wildcard URL. Each element may be:
+//
+//	name      matches the child with that name.
+//	*         matches every child.
+//	**        matches any number of elements (including none).
+//
+// Elements other than ** may be followed by one or more predicates, like
+// "*[tripped=true]", which only match children that are maps holding the
+// named value.
+type segment struct {
+	name       string
+	predicates []predicate
+}
+
+// A predicate matches if the named child has a basic value which, formatted
+// as a string, equals value. nil is formatted as "null".
+type predicate struct {
+	key   string
+	value string
+}
+
+// Parse a wildcard URL into segments, validating wildcard and predicate
+// syntax.
+func parsePattern(url string) (pattern []segment, e error) {
+	pathParts, e := parseUrl(url)
+	if e != nil {
+		return nil, e
+	}
+
+	pattern = make([]segment, len(pathParts))
+	for i, part := range pathParts {
+		if pattern[i], e = parseSegment(part); e != nil {
+			return nil, fmt.Errorf("Status: Invalid status url: %s: %s", url, e.Error())
+		}
+	}
+
+	return pattern, nil
+}
+
+// Does a URL, or URL element, contain wildcards or predicates? A '[' which
+// doesn't start a well formed list of predicates is part of a name.
+func containsWildcard(url string) bool {
+	for _, part := range strings.Split(url, "/") {
+		if strings.Contains(part, "*") || predicateStart(part) != -1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Find the index of the predicates in a URL element, or -1 if there are none.
+// Predicates are a list of "[key=value]" which runs to the end of the element.
+func predicateStart(part string) int {
+	for i := range part {
+		if part[i] == '[' {
+			if _, e := parsePredicates(part[i:]); e == nil {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parsePredicates(rest string) (predicates []predicate, e error) {
+	for rest != "" {
+		end := strings.Index(rest, "]")
+		if rest[0] != '[' || end == -1 {
+			return nil, fmt.Errorf("bad predicate %s", rest)
+		}
+
+		keyValue := strings.SplitN(rest[1:end], "=", 2)
+		if len(keyValue) != 2 || keyValue[0] == "" || strings.Contains(keyValue[0], "[") {
+			return nil, fmt.Errorf("bad predicate %s", rest[:end+1])
+		}
+
+		predicates = append(predicates, predicate{keyValue[0], keyValue[1]})
+		rest = rest[end+1:]
+	}
+
+	return predicates, nil
+}
+
+func parseSegment(part string) (seg segment, e error) {
+	seg.name = part
+
+	if i := predicateStart(part); i != -1 {
+		seg.name = part[:i]
+		seg.predicates, _ = parsePredicates(part[i:])
+	}
+
+	switch {
+	case seg.name == "":
+		return seg, fmt.Errorf("predicate without a name %s", part)
+	case seg.name == "**" && seg.predicates != nil:
+		return seg, fmt.Errorf("predicates not allowed on ** %s", part)
+	case seg.name != "*" && seg.name != "**" && strings.Contains(seg.name, "*"):
+		// Explain malformed predicates on wildcards, like "*[a]".
+		if i := strings.Index(part, "["); i != -1 {
+			if _, e := parsePredicates(part[i:]); e != nil {
+				return seg, e
+			}
+		}
+		return seg, fmt.Errorf("bad wildcard %s", part)
+	}
+
+	return seg, nil
+}
+
+// Does a child node with the given name match this segment? Not valid for **.
+func (seg segment) match(name string, n *node) bool {
+	if seg.name != "*" && seg.name != name {
+		return false
+	}
+
+	for _, p := range seg.predicates {
+		if !p.match(n) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p predicate) match(n *node) bool {
+	child, ok := childNode(n, p.key)
+	if !ok {
+		return false
+	}
+
+	switch t := child.value.(type) {
+	case nil:
+		return p.value == "null"
+	case bool, float64, int, int64, string:
+		return fmt.Sprint(t) == p.value
+	default:
+		return false
+	}
+}
+
+// Add every node below current which matches pattern to matches. path is the
+// path to current. If within isn't nil, only nodes which are parents or
+// children of within are considered.
+func matchPattern(current *node, path []string, pattern []segment, within []string, matches UrlMatches) {
+	if len(pattern) == 0 {
+		value := statusValueToValue(current.value)
+		matches[joinUrl(path)] = UrlMatch{Revision: current.revision, Value: value, Origin: current.origin}
+		return
+	}
+
+	seg := pattern[0]
+
+	// ** can match nothing at all.
+	if seg.name == "**" {
+		matchPattern(current, path, pattern[1:], within, matches)
+	}
+
+	// Limit the children to consider, if we are still above within.
+	if len(path) < len(within) {
+		name := within[len(path)]
+		child, ok := childNode(current, name)
+		if !ok {
+			return
+		}
+
+		switch {
+		case seg.name == "**":
+			matchPattern(child, childPath(path, name), pattern, within, matches)
+		case seg.match(name, child):
+			matchPattern(child, childPath(path, name), pattern[1:], within, matches)
+		}
+		return
+	}
+
+	switch seg.name {
+	case "**":
+		// Or, it can match this child, and more.
+		forEachChild(current, func(k string, child *node) {
+			matchPattern(child, childPath(path, k), pattern, within, matches)
+		})
+	case "*":
+		forEachChild(current, func(k string, child *node) {
+			if seg.match(k, child) {
+				matchPattern(child, childPath(path, k), pattern[1:], within, matches)
+			}
+		})
+	default:
+		if child, ok := childNode(current, seg.name); ok && seg.match(seg.name, child) {
+			matchPattern(child, childPath(path, seg.name), pattern[1:], within, matches)
+		}
+	}
+}
+
+// Does a concrete path match pattern? Predicates are checked against the
+// current values below current, so they don't match paths which no longer
+// exist. If subtree is true, paths below a match also match.
+func pathMatch(current *node, pattern []segment, path []string, subtree bool) bool {
+	if len(pattern) == 0 {
+		return subtree || len(path) == 0
+	}
+
+	seg := pattern[0]
+
+	// ** can match nothing at all.
+	if seg.name == "**" && pathMatch(current, pattern[1:], path, subtree) {
+		return true
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	var child *node
+	if current != nil {
+		child, _ = childNode(current, path[0])
+	}
+
+	switch {
+	case seg.name == "**":
+		// Or, it can match this element, and more.
+		return pathMatch(child, pattern, path[1:], subtree)
+	case seg.name != "*" && seg.name != path[0]:
+		return false
+	case seg.predicates != nil && (child == nil || !seg.match(path[0], child)):
+		return false
+	}
+
+	return pathMatch(child, pattern[1:], path[1:], subtree)
+}
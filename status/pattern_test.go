@@ -0,0 +1,157 @@
+package status
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestParsePattern(c *check.C) {
+	good := map[string][]segment{
+		"status://":       {},
+		"status://a/*/**": {{"a", nil}, {"*", nil}, {"**", nil}},
+		"status://*[a=]":  {{"*", []predicate{{"a", ""}}}},
+		"status://b[a=1][c=x=y]": {
+			{"b", []predicate{{"a", "1"}, {"c", "x=y"}}}},
+
+		// A '[' which doesn't start predicates is part of the name.
+		"status://a]":        {{"a]", nil}},
+		"status://a[1]":      {{"a[1]", nil}},
+		"status://a[b[c=d]":  {{"a[b", []predicate{{"c", "d"}}}},
+		"status://a[1][b=2]": {{"a[1]", []predicate{{"b", "2"}}}},
+	}
+
+	for url, expected := range good {
+		pattern, e := parsePattern(url)
+		c.Check(e, check.IsNil, check.Commentf(url))
+		c.Check(pattern, check.DeepEquals, expected, check.Commentf(url))
+	}
+
+	bad := []string{
+		"bogus",
+		"status://a*",
+		"status://***",
+		"status://[a=1]",
+		"status://*[a]",
+		"status://*[=1]",
+		"status://*[a=1",
+		"status://*[a=1]b",
+		"status://**[a=1]",
+	}
+
+	for _, url := range bad {
+		_, e := parsePattern(url)
+		c.Check(e, check.NotNil, check.Commentf(url))
+	}
+}
+
+// Names may contain '[', unless it looks like a predicate.
+func (s *MySuite) TestBracketNames(c *check.C) {
+	status := Status{}
+
+	c.Check(status.Set("status://list[0]", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://a[b/c]", 2, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://a[b=c]", 3, UNCHECKED_REVISION), check.NotNil)
+
+	value, _, e := status.Get("status://list[0]")
+	c.Check(e, check.IsNil)
+	c.Check(value, check.Equals, 1)
+
+	matches, e := status.GetMatchingUrls("status://list[0]")
+	c.Check(e, check.IsNil)
+	c.Check(matches, check.DeepEquals, UrlMatches{"status://list[0]": UrlMatch{Revision: 1, Value: 1}})
+
+	wc, e := status.WatchForUpdate("status://a[b/*")
+	c.Assert(e, check.IsNil)
+	checkPending(c, wc, UrlMatches{"status://a[b/c]": UrlMatch{Revision: 2, Value: 2}})
+}
+
+func (s *MySuite) TestGetMatchingUrlsPatterns(c *check.C) {
+	status := Status{}
+
+	e := status.SetJson("status://", []byte(`{
+		"vera": {
+			"room": {
+				"door": {"tripped": true, "batterylevel": 50},
+				"window": {"tripped": false, "batterylevel": 70},
+				"light": {"level": 1.5, "name": null}
+			}
+		},
+		"particle": {"core": {"batterylevel": 20}},
+		"batterylevel": 10
+	}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	checkMatches := func(url string, expected ...string) {
+		matches, e := status.GetMatchingUrls(url)
+		c.Assert(e, check.IsNil)
+
+		c.Check(len(matches), check.Equals, len(expected), check.Commentf(url))
+		for _, u := range expected {
+			_, ok := matches[u]
+			c.Check(ok, check.Equals, true, check.Commentf("%s: %s", url, u))
+		}
+	}
+
+	checkMatches("status://**/batterylevel",
+		"status://batterylevel",
+		"status://vera/room/door/batterylevel",
+		"status://vera/room/window/batterylevel",
+		"status://particle/core/batterylevel")
+
+	checkMatches("status://vera/**/tripped",
+		"status://vera/room/door/tripped",
+		"status://vera/room/window/tripped")
+
+	checkMatches("status://vera/**",
+		"status://vera",
+		"status://vera/room",
+		"status://vera/room/door",
+		"status://vera/room/door/tripped",
+		"status://vera/room/door/batterylevel",
+		"status://vera/room/window",
+		"status://vera/room/window/tripped",
+		"status://vera/room/window/batterylevel",
+		"status://vera/room/light",
+		"status://vera/room/light/level",
+		"status://vera/room/light/name")
+
+	checkMatches("status://vera/*/*[tripped=true]", "status://vera/room/door")
+	checkMatches("status://vera/*/*[tripped=false]", "status://vera/room/window")
+	checkMatches("status://vera/*/*[tripped=true][batterylevel=70]")
+	checkMatches("status://**/*[batterylevel=20]", "status://particle/core")
+	checkMatches("status://vera/room/light[level=1.5][name=null]", "status://vera/room/light")
+	checkMatches("status://vera/room/door[tripped=false]")
+	checkMatches("status://vera/room[tripped=true]")
+
+	_, e = status.GetMatchingUrls("status://*[tripped]")
+	c.Check(e, check.NotNil)
+}
+
+func (s *MySuite) TestWatchPatterns(c *check.C) {
+	status := Status{}
+
+	e := status.SetJson("status://vera/room", []byte(`{
+		"door": {"tripped": false},
+		"window": {"tripped": false}
+	}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	_, e = status.WatchForUpdate("status://*[tripped]")
+	c.Check(e, check.NotNil)
+
+	tripped, e := status.WatchForUpdate("status://**/*[tripped=true]")
+	c.Assert(e, check.IsNil)
+	checkPending(c, tripped, UrlMatches{})
+
+	e = status.Set("status://vera/room/door/tripped", true, UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	checkPending(c, tripped, UrlMatches{
+		"status://vera/room/door": UrlMatch{Revision: 2, Value: map[string]interface{}{"tripped": true}},
+	})
+
+	e = status.Set("status://vera/room/door/tripped", false, UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	checkPending(c, tripped, UrlMatches{})
+
+	status.ReleaseWatch(tripped)
+	c.Check(len(status.watchIndex.wildChildren), check.Equals, 0)
+}
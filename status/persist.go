@@ -0,0 +1,525 @@
+package status
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Settings used to save and restore status values across restarts.
+type PersistOptions struct {
+	Dir          string   // Directory to hold the snapshot and journal.
+	Include      []string // Subtrees to persist. Wildcards allowed. Default is everything.
+	Exclude      []string // Subtrees (inside Include) not to persist. Wildcards allowed.
+	CompactAfter int      // Journal entries to write before compacting into a new snapshot.
+}
+
+const (
+	snapshotFileName    = "snapshot.json"
+	journalFileName     = "journal.json"
+	defaultCompactAfter = 1000
+)
+
+// The contents of the snapshot file. Values holds the persisted values, which
+// include every journal entry up to Revision. Expiries holds the pending
+// expirations of persisted values with a TTL, by URL.
+type snapshot struct {
+	Revision int                    `json:"revision"`
+	Values   interface{}            `json:"values"`
+	Expiries map[string]savedExpiry `json:"expiries,omitempty"`
+}
+
+// A pending expiration, as saved.
+type savedExpiry struct {
+	Time        time.Time   `json:"time"` // When the value expires.
+	Fallback    interface{} `json:"fallback,omitempty"`
+	HasFallback bool        `json:"has_fallback,omitempty"`
+}
+
+// A single journaled change. Each is written as one line of Json. Revisions
+// count journal entries, and keep counting across restarts, so entries
+// already in the snapshot can be recognized.
+type journalEntry struct {
+	Revision int          `json:"revision"`
+	Op       string       `json:"op"` // "set", "remove", "insert" or "expire"
+	Url      string       `json:"url"`
+	Value    interface{}  `json:"value,omitempty"`
+	Expire   *savedExpiry `json:"expire,omitempty"`
+}
+
+type persister struct {
+	snapshotFile string
+	journalFile  string
+	include      [][]segment
+	exclude      [][]segment
+	compactAfter int
+
+	journal  *os.File
+	entries  int
+	revision int // Revision of the newest journal entry, or snapshot.
+}
+
+// Restore any saved values, and start journaling changes. This should be
+// called before adapters and rules start reading and writing values.
+//
+// Restored values are written into their subtrees with normal Sets, so values
+// outside of the persisted subtrees (like server configuration) are left
+// alone. Values with a TTL expire at the same time they would have, or right
+// away if that time has passed.
+func (s *Status) StartPersistence(options PersistOptions) (e error) {
+	p, e := newPersister(options)
+	if e != nil {
+		return e
+	}
+
+	// Load the saved values into a scratch Status, then copy the persisted
+	// subtrees into the real one.
+	saved, expiries, found, e := p.load()
+	if e != nil {
+		return e
+	}
+
+	if found {
+		if e = p.restore(s, []string{}, saved); e != nil {
+			return e
+		}
+
+		if e = s.restoreExpiries(expiries); e != nil {
+			return e
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.persister != nil {
+		return fmt.Errorf("Status: Persistence already started.")
+	}
+
+	// Start from a clean snapshot, and an empty journal.
+	if e = p.compact(s); e != nil {
+		return e
+	}
+
+	s.persister = p
+	return nil
+}
+
+// Stop journaling changes. Call this before shutting down adapters, so that
+// their cleanup isn't recorded.
+func (s *Status) StopPersistence() (e error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.persister == nil {
+		return nil
+	}
+
+	e = s.persister.journal.Close()
+	s.persister = nil
+	return e
+}
+
+func newPersister(options PersistOptions) (p *persister, e error) {
+	if options.Dir == "" {
+		return nil, fmt.Errorf("Status: No persistence directory.")
+	}
+
+	if options.Include == nil {
+		options.Include = []string{urlBase}
+	}
+
+	if options.CompactAfter <= 0 {
+		options.CompactAfter = defaultCompactAfter
+	}
+
+	if e = os.MkdirAll(options.Dir, 0755); e != nil {
+		return nil, e
+	}
+
+	p = &persister{
+		snapshotFile: filepath.Join(options.Dir, snapshotFileName),
+		journalFile:  filepath.Join(options.Dir, journalFileName),
+		compactAfter: options.CompactAfter,
+	}
+
+	if p.include, e = parsePersistPatterns(options.Include); e != nil {
+		return nil, e
+	}
+
+	if p.exclude, e = parsePersistPatterns(options.Exclude); e != nil {
+		return nil, e
+	}
+
+	return p, nil
+}
+
+// Parse include or exclude patterns. Predicates depend on current values, so
+// they aren't allowed.
+func parsePersistPatterns(urls []string) (result [][]segment, e error) {
+	result = make([][]segment, len(urls))
+	for i, url := range urls {
+		if result[i], e = parsePattern(url); e != nil {
+			return nil, e
+		}
+
+		for _, seg := range result[i] {
+			if seg.predicates != nil {
+				return nil, fmt.Errorf("Status: Predicates not allowed in persist url: %s", url)
+			}
+		}
+	}
+	return result, nil
+}
+
+// Does pattern match path, or one of path's parents?
+func subtreeMatch(pattern []segment, path []string) bool {
+	return pathMatch(nil, pattern, path, true)
+}
+
+// Is path a parent of something pattern can match?
+func ancestorMatch(pattern []segment, path []string) bool {
+	if len(path) == 0 {
+		return len(pattern) != 0
+	}
+
+	if len(pattern) == 0 {
+		return false
+	}
+
+	seg := pattern[0]
+	switch {
+	case seg.name == "**":
+		return ancestorMatch(pattern[1:], path) || ancestorMatch(pattern, path[1:])
+	case seg.name != "*" && seg.name != path[0]:
+		return false
+	}
+
+	return ancestorMatch(pattern[1:], path[1:])
+}
+
+func (p *persister) excluded(path []string) bool {
+	for _, pattern := range p.exclude {
+		if subtreeMatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is path inside of a persisted subtree?
+func (p *persister) persisted(path []string) bool {
+	if p.excluded(path) {
+		return false
+	}
+
+	for _, pattern := range p.include {
+		if subtreeMatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Can path contain any persisted values?
+func (p *persister) relevant(path []string) bool {
+	if p.persisted(path) {
+		return true
+	}
+
+	if p.excluded(path) {
+		return false
+	}
+
+	for _, pattern := range p.include {
+		if ancestorMatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Does path contain an excluded subtree?
+func (p *persister) partial(path []string) bool {
+	for _, pattern := range p.exclude {
+		if ancestorMatch(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func childPath(path []string, name string) []string {
+	child := make([]string, len(path), len(path)+1)
+	copy(child, path)
+	return append(child, name)
+}
+
+// Strip any values that aren't persisted from a value being written to path.
+// Parents of persisted subtrees are reduced to maps holding just those
+// subtrees (or nil), so that replaying the write has the same effect on the
+// persisted values as the original write.
+func (p *persister) filter(path []string, value interface{}) interface{} {
+	if p.persisted(path) && !p.partial(path) {
+		return value
+	}
+
+	valueMap, ok := value.(map[string]interface{})
+	if !ok {
+		if p.persisted(path) {
+			return value
+		}
+		return nil
+	}
+
+	result := map[string]interface{}{}
+	for k, v := range valueMap {
+		child := childPath(path, k)
+		if p.relevant(child) {
+			result[k] = p.filter(child, v)
+		}
+	}
+
+	return result
+}
+
+// Read the snapshot, and replay the journal on top of it. expiries are the
+// pending expirations of persisted values. found is false if there was nothing
+// saved.
+func (p *persister) load() (saved interface{}, expiries map[string]savedExpiry, found bool, e error) {
+	scratch := &Status{}
+
+	// Expirations, and the revisions of their values in scratch. They are
+	// cancelled if the value changes, just like the originals.
+	pending := map[string]savedExpiry{}
+	revisions := map[string]int{}
+
+	addExpiry := func(url string, exp savedExpiry) {
+		if _, revision, e := scratch.Get(url); e == nil {
+			pending[url] = exp
+			revisions[url] = revision
+		}
+	}
+
+	snapshotJson, e := ioutil.ReadFile(p.snapshotFile)
+	if e == nil {
+		found = true
+
+		var contents snapshot
+		if e = json.Unmarshal(snapshotJson, &contents); e == nil {
+			e = scratch.Set(urlBase, contents.Values, UNCHECKED_REVISION)
+		}
+		if e != nil {
+			return nil, nil, false, fmt.Errorf("Status: Bad snapshot %s: %s", p.snapshotFile, e.Error())
+		}
+
+		p.revision = contents.Revision
+		for url, exp := range contents.Expiries {
+			addExpiry(url, exp)
+		}
+	} else if !os.IsNotExist(e) {
+		return nil, nil, false, e
+	}
+
+	journal, e := os.Open(p.journalFile)
+	if e == nil {
+		found = true
+		defer journal.Close()
+
+		scanner := bufio.NewScanner(journal)
+		scanner.Buffer(nil, 16*1024*1024)
+		for scanner.Scan() {
+			var entry journalEntry
+			if e = json.Unmarshal(scanner.Bytes(), &entry); e != nil {
+				// A partial final line is expected if we crashed mid-write.
+				log.Printf("Status: Journal %s stopped at bad entry: %s", p.journalFile, e.Error())
+				break
+			}
+
+			// If we died while compacting, the journal may hold entries which
+			// are already in the snapshot. Inserts and removes of array
+			// elements can't be applied twice.
+			if entry.Revision <= p.revision {
+				continue
+			}
+			p.revision = entry.Revision
+
+			// Only successful changes are journaled, so replaying them against
+			// the same starting point succeeds.
+			switch entry.Op {
+			case "set":
+				scratch.Set(entry.Url, entry.Value, UNCHECKED_REVISION)
+				delete(pending, entry.Url)
+			case "remove":
+				scratch.Remove(entry.Url, UNCHECKED_REVISION)
+				delete(pending, entry.Url)
+			case "insert":
+				scratch.Insert(entry.Url, entry.Value, UNCHECKED_REVISION)
+			case "expire":
+				delete(pending, entry.Url)
+				if entry.Expire != nil {
+					addExpiry(entry.Url, *entry.Expire)
+				}
+			default:
+				log.Printf("Status: Journal %s has unknown op: %s", p.journalFile, entry.Op)
+			}
+		}
+
+		if e = scanner.Err(); e != nil {
+			return nil, nil, false, e
+		}
+	} else if !os.IsNotExist(e) {
+		return nil, nil, false, e
+	}
+
+	// Keep the expirations of values which haven't changed since.
+	expiries = map[string]savedExpiry{}
+	for url, exp := range pending {
+		path, _ := parseUrl(url)
+		_, revision, e := scratch.Get(url)
+		if e == nil && revision == revisions[url] && p.persisted(path) {
+			expiries[url] = exp
+		}
+	}
+
+	saved, _, e = scratch.Get(urlBase)
+	return saved, expiries, found, e
+}
+
+// Write each persisted subtree of a saved value into s.
+func (p *persister) restore(s *Status, path []string, value interface{}) (e error) {
+	if !p.relevant(path) {
+		return nil
+	}
+
+	valueMap, ok := value.(map[string]interface{})
+
+	if p.persisted(path) && (!ok || !p.partial(path)) {
+		return s.Set(joinUrl(path), value, UNCHECKED_REVISION)
+	}
+
+	for k, v := range valueMap {
+		if e = p.restore(s, childPath(path, k), v); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Write all persisted values into a new snapshot, and empty the journal.
+// Called with the Status lock held.
+func (p *persister) compact(s *Status) (e error) {
+	values := p.filter([]string{}, statusValueToValue(s.node.value))
+
+	// Skip expirations of values which have changed since, which are
+	// about to be cancelled.
+	expiries := map[string]savedExpiry{}
+	for url, exp := range s.expiries {
+		nodes, e := s.urlPathToNodes(exp.pathParts, false)
+		if e == nil && nodes[len(nodes)-1].revision == exp.revision && p.persisted(exp.pathParts) {
+			expiries[url] = exp.saved()
+		}
+	}
+
+	snapshotJson, e := json.Marshal(snapshot{p.revision, values, expiries})
+	if e != nil {
+		return e
+	}
+
+	// Write to a temp file, and rename so the old snapshot is replaced
+	// atomically.
+	tempFile := p.snapshotFile + ".tmp"
+	if e = ioutil.WriteFile(tempFile, snapshotJson, 0644); e != nil {
+		return e
+	}
+
+	if e = os.Rename(tempFile, p.snapshotFile); e != nil {
+		return e
+	}
+
+	// If we die before this, the old journal is loaded with the new snapshot,
+	// but all of it's entries are at or below the snapshot's revision, so
+	// they are skipped.
+	if p.journal != nil {
+		p.journal.Close()
+	}
+
+	p.journal, e = os.Create(p.journalFile)
+	if e != nil {
+		return e
+	}
+
+	p.entries = 0
+	return nil
+}
+
+func (p *persister) append(entry journalEntry, s *Status) {
+	p.revision += 1
+	entry.Revision = p.revision
+
+	line, e := json.Marshal(entry)
+	if e == nil {
+		_, e = p.journal.Write(append(line, '\n'))
+	}
+
+	if e == nil {
+		p.entries += 1
+		if p.entries >= p.compactAfter {
+			e = p.compact(s)
+		}
+	}
+
+	if e != nil {
+		log.Printf("Status: Failed to persist %s: %s", entry.Url, e.Error())
+	}
+}
+
+// Record a successful Set. Called with the Status lock held.
+func (p *persister) recordSet(path []string, value interface{}, s *Status) {
+	if !p.relevant(path) {
+		return
+	}
+
+	p.append(journalEntry{0, "set", joinUrl(path), p.filter(path, value), nil}, s)
+}
+
+// Record a successful Remove. Called with the Status lock held.
+func (p *persister) recordRemove(path []string, s *Status) {
+	if !p.relevant(path) {
+		return
+	}
+
+	p.append(journalEntry{0, "remove", joinUrl(path), nil, nil}, s)
+}
+
+// Record a successful Insert. path is the new array element. Called with the
+// Status lock held.
+func (p *persister) recordInsert(path []string, value interface{}, s *Status) {
+	if !p.relevant(path) {
+		return
+	}
+
+	p.append(journalEntry{0, "insert", joinUrl(path), p.filter(path, value), nil}, s)
+}
+
+// Record a newly started expiration, or a cancelled one if exp is nil. Called
+// with the Status lock held.
+func (p *persister) recordExpiry(path []string, exp *expiry, s *Status) {
+	if !p.persisted(path) {
+		return
+	}
+
+	var saved *savedExpiry
+	if exp != nil {
+		value := exp.saved()
+		saved = &value
+	}
+
+	p.append(journalEntry{0, "expire", joinUrl(path), nil, saved}, s)
+}
@@ -0,0 +1,259 @@
+package status
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"gopkg.in/check.v1"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func setupPersistedStatus(c *check.C, options PersistOptions) *Status {
+	s := &Status{}
+
+	e := s.SetJson("status://server", []byte(`{"port": 80}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	e = s.StartPersistence(options)
+	c.Assert(e, check.IsNil)
+
+	return s
+}
+
+func (suite *MySuite) TestPersistRestore(c *check.C) {
+	options := PersistOptions{
+		Dir:     c.MkDir(),
+		Exclude: []string{"status://server", "status://*/skip"},
+	}
+
+	s := setupPersistedStatus(c, options)
+
+	c.Check(s.SetJson("status://web", []byte(`{"a": 1, "skip": 2}`), UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://web/b", "b", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://other/list", []interface{}{"x", "y"}, UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://other/gone", true, UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Remove("status://other/gone", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://server/port", 8080, UNCHECKED_REVISION), check.IsNil)
+
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	// Changes after stopping aren't saved.
+	c.Check(s.Set("status://web/a", 5, UNCHECKED_REVISION), check.IsNil)
+
+	restored := setupPersistedStatus(c, options)
+
+	c.Check(restored.PrettyDump("status://"), check.Equals, NormalizeJson(`
+		{
+			"server": {"port": 80},
+			"web": {"a": 1, "b": "b"},
+			"other": {"list": ["x", "y"]}
+		}`))
+
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistIncludeOnly(c *check.C) {
+	options := PersistOptions{
+		Dir:     c.MkDir(),
+		Include: []string{"status://*/keep"},
+	}
+
+	s := setupPersistedStatus(c, options)
+
+	// Writing a parent of a persisted subtree only saves that subtree.
+	c.Check(s.SetJson("status://", []byte(`{"one": {"keep": 1, "drop": 2}, "drop": 3}`), UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://two/keep/deep", "value", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	restored := setupPersistedStatus(c, options)
+
+	c.Check(restored.PrettyDump("status://"), check.Equals, NormalizeJson(`
+		{
+			"server": {"port": 80},
+			"one": {"keep": 1},
+			"two": {"keep": {"deep": "value"}}
+		}`))
+
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistCompact(c *check.C) {
+	options := PersistOptions{
+		Dir:          c.MkDir(),
+		Exclude:      []string{"status://server"},
+		CompactAfter: 3,
+	}
+
+	s := setupPersistedStatus(c, options)
+
+	for i := 0; i < 4; i++ {
+		c.Check(s.Set("status://count", i, UNCHECKED_REVISION), check.IsNil)
+	}
+
+	// Three writes were compacted into the snapshot, one is in the journal.
+	snapshot, e := ioutil.ReadFile(filepath.Join(options.Dir, snapshotFileName))
+	c.Check(e, check.IsNil)
+	c.Check(string(snapshot), check.Equals, `{"revision":3,"values":{"count":2}}`)
+
+	journal, e := ioutil.ReadFile(filepath.Join(options.Dir, journalFileName))
+	c.Check(e, check.IsNil)
+	c.Check(string(journal), check.Equals, `{"revision":4,"op":"set","url":"status://count","value":3}`+"\n")
+
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	restored := setupPersistedStatus(c, options)
+	CheckValue(c, restored, "status://count", 3.0, 2)
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistArrays(c *check.C) {
+	options := PersistOptions{Dir: c.MkDir(), Exclude: []string{"status://server"}}
+
+	s := setupPersistedStatus(c, options)
+
+	c.Check(s.Set("status://list", []interface{}{"b"}, UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Insert("status://list/0", "a", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Append("status://list", "x", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Set("status://list/2", "c", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Append("status://list", "d", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.Remove("status://list/3", UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	restored := setupPersistedStatus(c, options)
+	c.Check(restored.PrettyDump("status://list"), check.Equals, NormalizeJson(`["a", "b", "c"]`))
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistPartialJournal(c *check.C) {
+	options := PersistOptions{Dir: c.MkDir()}
+
+	// Simulate a crash in the middle of writing a journal entry.
+	e := ioutil.WriteFile(
+		filepath.Join(options.Dir, journalFileName),
+		[]byte(`{"revision":1,"op":"set","url":"status://a","value":1}`+"\n"+`{"revision":2,"op":"set","url":"stat`),
+		os.ModePerm)
+	c.Assert(e, check.IsNil)
+
+	s := &Status{}
+	c.Assert(s.StartPersistence(options), check.IsNil)
+
+	CheckValue(c, s, "status://", map[string]interface{}{"a": 1.0}, 1)
+	c.Check(s.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistInterruptedCompact(c *check.C) {
+	options := PersistOptions{Dir: c.MkDir()}
+
+	// Simulate a crash after a new snapshot is written, but before the journal
+	// is emptied. Replaying the insert or remove again would corrupt the list.
+	e := ioutil.WriteFile(
+		filepath.Join(options.Dir, snapshotFileName),
+		[]byte(`{"revision":3,"values":{"list":["a","c"]}}`),
+		os.ModePerm)
+	c.Assert(e, check.IsNil)
+
+	e = ioutil.WriteFile(
+		filepath.Join(options.Dir, journalFileName),
+		[]byte(`{"revision":1,"op":"set","url":"status://list","value":["b","c"]}`+"\n"+
+			`{"revision":2,"op":"insert","url":"status://list/0","value":"a"}`+"\n"+
+			`{"revision":3,"op":"remove","url":"status://list/1"}`+"\n"),
+		os.ModePerm)
+	c.Assert(e, check.IsNil)
+
+	s := &Status{}
+	c.Assert(s.StartPersistence(options), check.IsNil)
+	c.Check(s.PrettyDump("status://list"), check.Equals, NormalizeJson(`["a", "c"]`))
+
+	// New entries continue from the snapshot's revision.
+	c.Check(s.Append("status://list", "d", UNCHECKED_REVISION), check.IsNil)
+
+	journal, e := ioutil.ReadFile(filepath.Join(options.Dir, journalFileName))
+	c.Check(e, check.IsNil)
+	c.Check(string(journal), check.Equals, `{"revision":4,"op":"insert","url":"status://list/2","value":"d"}`+"\n")
+
+	c.Check(s.StopPersistence(), check.IsNil)
+}
+
+// Values with a TTL expire at the same time after a restart.
+func (suite *MySuite) TestPersistTTL(c *check.C) {
+	options := PersistOptions{Dir: c.MkDir(), CompactAfter: 4}
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC))
+
+	start := func() *Status {
+		s := &Status{}
+		s.SetClock(clk)
+		c.Assert(s.StartPersistence(options), check.IsNil)
+		return s
+	}
+
+	s := start()
+	c.Check(s.SetWithTTL("status://a", 1, UNCHECKED_REVISION, 10*time.Minute), check.IsNil)
+	c.Check(s.SetWithFallback("status://b", true, UNCHECKED_REVISION, 20*time.Minute, false), check.IsNil)
+	c.Check(s.SetWithTTL("status://c", 1, UNCHECKED_REVISION, 10*time.Minute), check.IsNil)
+	c.Check(s.Set("status://c", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.SetWithTTL("status://d", 1, UNCHECKED_REVISION, 10*time.Minute), check.IsNil)
+	c.Check(s.Set("status://d", 2, UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	// Down for a while, but not long enough for anything to expire.
+	clk.Advance(5 * time.Minute)
+
+	restored := start()
+	c.Check(restored.PrettyDump("status://"), check.Equals, NormalizeJson(`{"a": 1, "b": true, "c": 1, "d": 2}`))
+	c.Check(len(restored.expiries), check.Equals, 2)
+
+	watch, e := restored.WatchForUpdate("status://a")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	clk.Advance(5 * time.Minute)
+	waitPending(c, watch, UrlMatches{})
+	restored.ReleaseWatch(watch)
+	c.Check(restored.StopPersistence(), check.IsNil)
+
+	// Down past the time b expires.
+	clk.Advance(time.Hour)
+
+	restored = start()
+	c.Check(restored.PrettyDump("status://"), check.Equals, NormalizeJson(`{"b": false, "c": 1, "d": 2}`))
+	c.Check(len(restored.expiries), check.Equals, 0)
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistDoubleWildcard(c *check.C) {
+	options := PersistOptions{
+		Dir:     c.MkDir(),
+		Include: []string{"status://**/keep"},
+		Exclude: []string{"status://**/skip/**"},
+	}
+
+	s := setupPersistedStatus(c, options)
+
+	c.Check(s.SetJson("status://", []byte(`{"keep": 1, "one": {"two": {"keep": {"a": 2, "skip": 3}, "drop": 4}}}`), UNCHECKED_REVISION), check.IsNil)
+	c.Check(s.StopPersistence(), check.IsNil)
+
+	restored := setupPersistedStatus(c, options)
+
+	c.Check(restored.PrettyDump("status://"), check.Equals, NormalizeJson(`
+		{
+			"server": {"port": 80},
+			"keep": 1,
+			"one": {"two": {"keep": {"a": 2}}}
+		}`))
+
+	c.Check(restored.StopPersistence(), check.IsNil)
+}
+
+func (suite *MySuite) TestPersistBadOptions(c *check.C) {
+	s := &Status{}
+
+	c.Check(s.StartPersistence(PersistOptions{}), check.NotNil)
+	c.Check(s.StartPersistence(PersistOptions{Dir: c.MkDir(), Include: []string{"bogus"}}), check.NotNil)
+	c.Check(s.StartPersistence(PersistOptions{Dir: c.MkDir(), Exclude: []string{"status://*[a=1]"}}), check.NotNil)
+
+	options := PersistOptions{Dir: c.MkDir()}
+	c.Check(s.StartPersistence(options), check.IsNil)
+	c.Check(s.StartPersistence(options), check.NotNil)
+	c.Check(s.StopPersistence(), check.IsNil)
+}
@@ -0,0 +1,76 @@
+package status
+
+import (
+	"fmt"
+)
+
+// A subtree which only a single origin may change.
+type readOnlyUrl struct {
+	path  []string
+	owner string
+}
+
+// Only allow changes at or below url which are attributed to owner. Changes
+// from other origins, including changes to parents of url, are rejected. Calling again for the same url replaces
+// the owner.
+func (s *Status) SetReadOnly(url, owner string) (e error) {
+	if e = CheckForWildcard(url); e != nil {
+		return e
+	}
+
+	path, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, r := range s.readOnly {
+		if joinUrl(r.path) == joinUrl(path) {
+			r.owner = owner
+			return nil
+		}
+	}
+
+	s.readOnly = append(s.readOnly, &readOnlyUrl{path, owner})
+	return nil
+}
+
+// Verify every queued change is allowed for origin. Called with the Status
+// lock held.
+func (s *Status) checkReadOnly(ops []txOp, origin string) error {
+	for _, r := range s.readOnly {
+		if r.owner == origin {
+			continue
+		}
+
+		for _, op := range ops {
+			if isPrefix(r.path, op.pathParts) {
+				return fmt.Errorf("Status: %s is read only.", joinUrl(op.pathParts))
+			}
+
+			// Changing a parent could replace or remove the subtree.
+			if isPrefix(op.pathParts, r.path) {
+				return fmt.Errorf("Status: %s contains read only %s.", joinUrl(op.pathParts), joinUrl(r.path))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Is prefix equal to path, or one of path's parents?
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i, p := range prefix {
+		if p != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,47 @@
+package status
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (suite *MySuite) TestReadOnly(c *check.C) {
+	status := &Status{}
+
+	c.Check(status.SetReadOnly("status://engine/*", "engine"), check.NotNil)
+	c.Assert(status.SetReadOnly("status://engine", "engine"), check.IsNil)
+
+	// The owner can write.
+	c.Check(status.SetFrom("engine", "status://engine/a", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.SetFrom("engine", "status://engine/b", 2, UNCHECKED_REVISION), check.IsNil)
+
+	// Nobody else can.
+	c.Check(status.SetFrom("rule:x", "status://engine/a", 3, UNCHECKED_REVISION),
+		check.ErrorMatches, "Status: status://engine/a is read only.")
+	c.Check(status.Set("status://engine", 3, UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.RemoveFrom("http:1.2.3.4:5", "status://engine/b", UNCHECKED_REVISION), check.NotNil)
+
+	// Or change a parent, which could replace the subtree.
+	c.Check(status.SetFrom("rule:x", "status://", map[string]interface{}{}, UNCHECKED_REVISION),
+		check.ErrorMatches, "Status: status:// contains read only status://engine.")
+	c.Check(status.RemoveFrom("rule:x", "status://", UNCHECKED_REVISION), check.NotNil)
+
+	// A transaction touching the subtree fails completely.
+	e := status.UpdateFrom("rule:x", func(tx *Tx) error {
+		tx.Set("status://other", 1, UNCHECKED_REVISION)
+		return tx.Set("status://engine/a", 3, UNCHECKED_REVISION)
+	})
+	c.Check(e, check.NotNil)
+
+	value, _, e := status.Get("status://")
+	c.Check(e, check.IsNil)
+	c.Check(value, check.DeepEquals, map[string]interface{}{
+		"engine": map[string]interface{}{"a": 1, "b": 2}})
+
+	// Other values are unaffected.
+	c.Check(status.SetFrom("rule:x", "status://engines", 1, UNCHECKED_REVISION), check.IsNil)
+
+	// Changing the owner.
+	c.Assert(status.SetReadOnly("status://engine", "other"), check.IsNil)
+	c.Check(status.SetFrom("engine", "status://engine/a", 3, UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.SetFrom("other", "status://engine/a", 3, UNCHECKED_REVISION), check.IsNil)
+}
@@ -0,0 +1,410 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// A schema registered against a wildcard URL.
+type registeredSchema struct {
+	url     string
+	pattern []segment
+	schema  *schema
+	reject  bool // Reject writes that don't conform, instead of just logging.
+}
+
+// A compiled JSON Schema. Only a subset of JSON Schema is supported:
+//
+//	type, properties, required, additionalProperties, items, enum, minimum,
+//	maximum, pattern, anyOf
+//
+// plus the annotations title, description and $schema, which are ignored.
+type schema struct {
+	types                []string
+	properties           map[string]*schema
+	required             []string
+	additionalProperties *schema
+	noAdditional         bool
+	items                *schema
+	enum                 []interface{}
+	minimum              *float64
+	maximum              *float64
+	pattern              *regexp.Regexp
+	anyOf                []*schema
+	never                bool // The schema false, which accepts nothing.
+}
+
+var schemaTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// Register a JSON Schema for every value matching a wildcard URL. Later
+// writes which leave a matching value that doesn't conform are rejected with
+// an error naming the nonconforming value, or if reject is false, accepted
+// and logged. Existing values are not checked.
+func (s *Status) AddSchema(url string, schemaValue interface{}, reject bool) (e error) {
+	pattern, e := parsePattern(url)
+	if e != nil {
+		return e
+	}
+
+	compiled, e := compileSchema(schemaValue, "#")
+	if e != nil {
+		return e
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.schemas = append(s.schemas, &registeredSchema{url, pattern, compiled, reject})
+	return nil
+}
+
+// This is just like AddSchema, except it accepts the schema in Json format.
+func (s *Status) AddSchemaJson(url string, schemaJson []byte, reject bool) (e error) {
+	var schemaValue interface{}
+	if e = json.Unmarshal(schemaJson, &schemaValue); e != nil {
+		return e
+	}
+
+	return s.AddSchema(url, schemaValue, reject)
+}
+
+// Check all values matching a schema which may be affected by changes to the
+// changed paths. Returns the first error from a rejecting schema, and all
+// errors from schemas which only log. Called with the Status lock held.
+func (s *Status) checkSchemas(changed [][]string) (rejected error, flagged []error) {
+	for _, r := range s.schemas {
+		for _, path := range changed {
+			matches := UrlMatches{}
+			matchPattern(&s.node, []string{}, r.pattern, path, matches)
+
+			// Check in a stable order, so the same error is always reported.
+			urls := make([]string, 0, len(matches))
+			for url := range matches {
+				urls = append(urls, url)
+			}
+			sort.Strings(urls)
+
+			for _, url := range urls {
+				e := r.schema.validate(url, matches[url].Value)
+				if e == nil {
+					continue
+				}
+
+				if r.reject {
+					return e, flagged
+				}
+				flagged = append(flagged, e)
+			}
+		}
+	}
+
+	return nil, flagged
+}
+
+// Log schema errors that don't reject changes.
+func logSchemaErrors(flagged []error) {
+	for _, e := range flagged {
+		log.Println(e.Error())
+	}
+}
+
+func compileSchema(value interface{}, where string) (result *schema, e error) {
+	// The schema true accepts anything, and false accepts nothing.
+	if b, ok := value.(bool); ok {
+		return &schema{never: !b}, nil
+	}
+
+	body, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Status: Schema %s is not an object", where)
+	}
+
+	result = &schema{}
+
+	for key, v := range body {
+		switch key {
+		case "type":
+			if result.types, e = compileTypes(v, where); e != nil {
+				return nil, e
+			}
+
+		case "properties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("Status: Schema %s/properties is not an object", where)
+			}
+			result.properties = map[string]*schema{}
+			for name, prop := range props {
+				if result.properties[name], e = compileSchema(prop, where+"/properties/"+name); e != nil {
+					return nil, e
+				}
+			}
+
+		case "required":
+			if result.required, e = compileStrings(v, where+"/required"); e != nil {
+				return nil, e
+			}
+
+		case "additionalProperties":
+			if allowed, ok := v.(bool); ok {
+				result.noAdditional = !allowed
+			} else if result.additionalProperties, e = compileSchema(v, where+"/additionalProperties"); e != nil {
+				return nil, e
+			}
+
+		case "items":
+			if result.items, e = compileSchema(v, where+"/items"); e != nil {
+				return nil, e
+			}
+
+		case "enum":
+			if result.enum, ok = v.([]interface{}); !ok {
+				return nil, fmt.Errorf("Status: Schema %s/enum is not an array", where)
+			}
+
+		case "minimum", "maximum":
+			number, ok := numericValue(v)
+			if _, isBool := v.(bool); !ok || isBool {
+				return nil, fmt.Errorf("Status: Schema %s/%s is not a number", where, key)
+			}
+			if key == "minimum" {
+				result.minimum = &number
+			} else {
+				result.maximum = &number
+			}
+
+		case "pattern":
+			patternStr, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("Status: Schema %s/pattern is not a string", where)
+			}
+			if result.pattern, e = regexp.Compile(patternStr); e != nil {
+				return nil, fmt.Errorf("Status: Schema %s/pattern: %s", where, e.Error())
+			}
+
+		case "anyOf":
+			options, ok := v.([]interface{})
+			if !ok || len(options) == 0 {
+				return nil, fmt.Errorf("Status: Schema %s/anyOf is not a non-empty array", where)
+			}
+			for i, option := range options {
+				compiled, e := compileSchema(option, fmt.Sprintf("%s/anyOf/%d", where, i))
+				if e != nil {
+					return nil, e
+				}
+				result.anyOf = append(result.anyOf, compiled)
+			}
+
+		case "title", "description", "$schema":
+			// Annotations only.
+
+		default:
+			return nil, fmt.Errorf("Status: Schema %s has unsupported keyword: %s", where, key)
+		}
+	}
+
+	return result, nil
+}
+
+func compileTypes(value interface{}, where string) (types []string, e error) {
+	if single, ok := value.(string); ok {
+		types = []string{single}
+	} else if types, e = compileStrings(value, where+"/type"); e != nil {
+		return nil, e
+	}
+
+	for _, t := range types {
+		if !schemaTypes[t] {
+			return nil, fmt.Errorf("Status: Schema %s/type has unknown type: %s", where, t)
+		}
+	}
+
+	return types, nil
+}
+
+func compileStrings(value interface{}, where string) (result []string, e error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Status: Schema %s is not an array", where)
+	}
+
+	for _, v := range list {
+		str, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("Status: Schema %s contains a non-string", where)
+		}
+		result = append(result, str)
+	}
+
+	return result, nil
+}
+
+// The JSON Schema type name of a value.
+func schemaType(value interface{}) string {
+	switch t := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int, int64:
+		return "integer"
+	case float64:
+		if t == math.Trunc(t) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func violation(url string, format string, args ...interface{}) error {
+	return fmt.Errorf("Status: Schema violation at %s: %s", url, fmt.Sprintf(format, args...))
+}
+
+// Validate a value found at url. Errors name the URL of the nonconforming
+// value.
+func (sc *schema) validate(url string, value interface{}) error {
+	if sc.never {
+		return violation(url, "value not allowed")
+	}
+
+	valueType := schemaType(value)
+
+	if sc.types != nil {
+		found := false
+		for _, t := range sc.types {
+			if t == valueType || (t == "number" && valueType == "integer") {
+				found = true
+			}
+		}
+		if !found {
+			return violation(url, "expected %v, got %s", sc.types, valueType)
+		}
+	}
+
+	if sc.enum != nil {
+		found := false
+		for _, option := range sc.enum {
+			if valuesEqual(option, value) {
+				found = true
+			}
+		}
+		if !found {
+			return violation(url, "%v is not one of %v", value, sc.enum)
+		}
+	}
+
+	if number, ok := numericValue(value); ok && valueType != "boolean" {
+		if sc.minimum != nil && number < *sc.minimum {
+			return violation(url, "%v is less than %v", value, *sc.minimum)
+		}
+		if sc.maximum != nil && number > *sc.maximum {
+			return violation(url, "%v is greater than %v", value, *sc.maximum)
+		}
+	}
+
+	if str, ok := value.(string); ok && sc.pattern != nil && !sc.pattern.MatchString(str) {
+		return violation(url, "%q doesn't match %s", str, sc.pattern.String())
+	}
+
+	switch t := value.(type) {
+	case map[string]interface{}:
+		if e := sc.validateObject(url, t); e != nil {
+			return e
+		}
+	case []interface{}:
+		if sc.items != nil {
+			for i, item := range t {
+				if e := sc.items.validate(childUrl(url, strconv.Itoa(i)), item); e != nil {
+					return e
+				}
+			}
+		}
+	}
+
+	if sc.anyOf != nil {
+		var first error
+		for _, option := range sc.anyOf {
+			e := option.validate(url, value)
+			if e == nil {
+				return nil
+			}
+			if first == nil {
+				first = e
+			}
+		}
+		return violation(url, "matches no allowed schema (first failure: %s)", first.Error())
+	}
+
+	return nil
+}
+
+func (sc *schema) validateObject(url string, value map[string]interface{}) error {
+	for _, name := range sc.required {
+		if _, ok := value[name]; !ok {
+			return violation(childUrl(url, name), "required value missing")
+		}
+	}
+
+	// Check children in a stable order, so the same error is always reported.
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child, ok := sc.properties[name]
+		switch {
+		case ok:
+		case sc.additionalProperties != nil:
+			child = sc.additionalProperties
+		case sc.noAdditional:
+			return violation(childUrl(url, name), "value not allowed")
+		default:
+			continue
+		}
+
+		if e := child.validate(childUrl(url, name), value[name]); e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+func childUrl(url, name string) string {
+	if url == urlBase {
+		return url + name
+	}
+	return url + "/" + name
+}
+
+// Compare values from Json, treating all numbers the same.
+func valuesEqual(left, right interface{}) bool {
+	l, lOk := numericValue(left)
+	r, rOk := numericValue(right)
+	_, lBool := left.(bool)
+	_, rBool := right.(bool)
+
+	if lOk && rOk && !lBool && !rBool {
+		return l == r
+	}
+
+	return reflect.DeepEqual(left, right)
+}
@@ -0,0 +1,136 @@
+package status
+
+import (
+	"gopkg.in/check.v1"
+	"regexp"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "pattern": "^[a-z]+$"},
+    "level": {"type": "number", "minimum": 0, "maximum": 10},
+    "mode": {"enum": ["on", "off", 3]},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "target": {"anyOf": [{"type": "null"}, {"type": "integer"}]}
+  },
+  "additionalProperties": false
+}`
+
+func (s *MySuite) TestSchemaValidate(c *check.C) {
+	status := &Status{}
+	c.Assert(status.AddSchemaJson("status://*/light/*", []byte(testSchema), true), check.IsNil)
+
+	// Values that conform.
+	good := []string{
+		`{"name": "a"}`,
+		`{"name": "abc", "level": 2.5, "mode": "on", "tags": ["x"], "target": null}`,
+		`{"name": "abc", "level": 10, "mode": 3.0, "tags": [], "target": 4}`,
+	}
+
+	for _, g := range good {
+		e := status.SetJson("status://web/light/kitchen", []byte(g), UNCHECKED_REVISION)
+		c.Check(e, check.IsNil, check.Commentf(g))
+	}
+
+	// Values that don't, and the expected errors.
+	bad := map[string]string{
+		`"a"`:                          "status://web/light/kitchen: expected [object], got string",
+		`{}`:                           "status://web/light/kitchen/name: required value missing",
+		`{"name": "A"}`:                `status://web/light/kitchen/name: "A" doesn't match ^[a-z]+$`,
+		`{"name": "a", "level": -1}`:   "status://web/light/kitchen/level: -1 is less than 0",
+		`{"name": "a", "level": 11}`:   "status://web/light/kitchen/level: 11 is greater than 10",
+		`{"name": "a", "level": true}`: "status://web/light/kitchen/level: expected [number], got boolean",
+		`{"name": "a", "mode": "dim"}`: "status://web/light/kitchen/mode: dim is not one of [on off 3]",
+		`{"name": "a", "tags": [1]}`:   "status://web/light/kitchen/tags/0: expected [string], got integer",
+		`{"name": "a", "levle": 1}`:    "status://web/light/kitchen/levle: value not allowed",
+		`{"name": "a", "target": 1.5}`: "status://web/light/kitchen/target: matches no allowed schema",
+	}
+
+	for b, expected := range bad {
+		e := status.SetJson("status://web/light/kitchen", []byte(b), UNCHECKED_REVISION)
+		c.Assert(e, check.NotNil, check.Commentf(b))
+		c.Check(e, check.ErrorMatches, "Status: Schema violation at "+regexp.QuoteMeta(expected)+".*", check.Commentf(b))
+	}
+
+	// Nothing bad was written.
+	CheckValue(c, status, "status://web/light/kitchen/level", 10.0, 3)
+
+	// Writes inside of a matching value are checked too.
+	c.Check(status.Set("status://web/light/kitchen/level", 20, UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Remove("status://web/light/kitchen/name", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Set("status://web/light/kitchen/level", 5, UNCHECKED_REVISION), check.IsNil)
+
+	// And writes to parents.
+	e := status.SetJson("status://web", []byte(`{"light": {"a": {}}}`), UNCHECKED_REVISION)
+	c.Check(e, check.ErrorMatches, ".*status://web/light/a/name: required value missing")
+
+	// Values that don't match the URL aren't checked.
+	c.Check(status.Set("status://web/dark/kitchen", "a", UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://web/light", "a", UNCHECKED_REVISION), check.IsNil)
+}
+
+func (s *MySuite) TestSchemaFlag(c *check.C) {
+	status := &Status{}
+	c.Assert(status.AddSchemaJson("status://*/light/*", []byte(testSchema), false), check.IsNil)
+
+	// The write isn't rejected.
+	c.Check(status.Set("status://web/light/kitchen", "bogus", UNCHECKED_REVISION), check.IsNil)
+	CheckValue(c, status, "status://web/light/kitchen", "bogus", 1)
+}
+
+func (s *MySuite) TestSchemaWithTx(c *check.C) {
+	status := &Status{}
+	c.Assert(status.AddSchemaJson("status://*/light/*", []byte(testSchema), true), check.IsNil)
+
+	// Intermediate states don't matter, only the final one.
+	e := status.Update(func(tx *Tx) error {
+		c.Check(tx.Set("status://web/light/a/level", 1, UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Set("status://web/light/a/name", "a", UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+	c.Check(e, check.IsNil)
+
+	// A violation rolls back all changes.
+	e = status.Update(func(tx *Tx) error {
+		c.Check(tx.Set("status://other", 1, UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Set("status://web/light/a/level", 100, UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+	c.Check(e, check.NotNil)
+	CheckGetFailure(c, status, "status://other")
+	CheckValue(c, status, "status://web/light/a/level", 1, 1)
+}
+
+func (s *MySuite) TestSchemaBad(c *check.C) {
+	status := &Status{}
+
+	bad := []string{
+		`5`,
+		`{"type": "bogus"}`,
+		`{"type": ["string", 5]}`,
+		`{"properties": []}`,
+		`{"properties": {"a": 5}}`,
+		`{"required": "a"}`,
+		`{"minimum": "a"}`,
+		`{"pattern": "("}`,
+		`{"anyOf": []}`,
+		`{"enum": 5}`,
+		`{"bogus": 5}`,
+		`{"items": {"type": "bogus"}}`,
+	}
+
+	for _, b := range bad {
+		c.Check(status.AddSchemaJson("status://a", []byte(b), true), check.NotNil, check.Commentf(b))
+	}
+
+	c.Check(status.AddSchemaJson("status://a*", []byte(`{}`), true), check.NotNil)
+	c.Check(status.AddSchemaJson("status://a", []byte(`{`), true), check.NotNil)
+
+	// The true and false schemas.
+	c.Check(status.AddSchemaJson("status://any", []byte(`true`), true), check.IsNil)
+	c.Check(status.AddSchemaJson("status://none", []byte(`false`), true), check.IsNil)
+	c.Check(status.Set("status://any", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://none", 1, UNCHECKED_REVISION), check.NotNil)
+}
@@ -2,6 +2,9 @@ package status
 
 import (
 	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -13,22 +16,32 @@ const NONEXISTENT = -2
 // The status structure.
 type Status struct {
 	node
-	lock     sync.RWMutex
-	watchers []*watcher
+	lock       sync.RWMutex
+	watchers   []*watcher
+	watchIndex watchNode  // Index of watchers, by URL.
+	persister  *persister // nil unless StartPersistence was called.
+	histories  []*history
+	expiries   map[string]*expiry // Values with a TTL, by URL.
+	schemas    []*registeredSchema
+	audit      *auditLog // nil until the first change is recorded.
+	readOnly   []*readOnlyUrl
+	clock      clock.Clock // nil for the real clock.
 }
 
 // Structure used at every node in a Status tree.
 type node struct {
 	revision int
 	value    statusValue
+	origin   string // Origin of the change which set revision.
 }
 
-// Internal type used as the value of Status nodes with children.
+// Internal types used as the value of Status nodes with children.
 type statusMap map[string]*node
+type statusArray []*node
 
 // Internal type used for the value stored in a Status. May be any of:
 //   bool, float64, int, int64, string, nil, for basic values.
-//   []statusValue, for JSON arrays
+//   statusArray, for JSON arrays
 //   statusMap, for JSON objects
 type statusValue interface{}
 
@@ -36,6 +49,7 @@ type statusValue interface{}
 type UrlMatch struct {
 	Revision int
 	Value    interface{}
+	Origin   string // Origin of the newest change, or "" if unknown.
 }
 
 // This is a map of status URLs to values, used when wildcard URLs are expanded.
@@ -43,11 +57,85 @@ type UrlMatches map[string]UrlMatch
 
 const urlBase = "status://"
 
+// Use clk to timestamp history and TTLs, instead of the real clock.
+func (s *Status) SetClock(clk clock.Clock) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.clock = clk
+}
+
+// The clock used to timestamp history and TTLs.
+func (s *Status) Clock() clock.Clock {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.getClock()
+}
+
+// The clock in use. Called with the lock held.
+func (s *Status) getClock() clock.Clock {
+	if s.clock == nil {
+		return clock.NewReal()
+	}
+	return s.clock
+}
+
 // Get a value from the status as described by the URL.
 func (s *Status) Get(url string) (value interface{}, revision int, e error) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
+	return s.get(url)
+}
+
+// Set a value from the status as described by the URL. Revision numbers are
+// updated as needed.
+func (s *Status) Set(url string, value interface{}, revision int) (e error) {
+	return s.Update(func(tx *Tx) error {
+		return tx.Set(url, value, revision)
+	})
+}
+
+// This is just like Set, except the change is attributed to origin.
+func (s *Status) SetFrom(origin, url string, value interface{}, revision int) (e error) {
+	return s.UpdateFrom(origin, func(tx *Tx) error {
+		return tx.Set(url, value, revision)
+	})
+}
+
+// Remove a named child from a node, or an element from an array.
+func (s *Status) Remove(url string, revision int) (e error) {
+	return s.Update(func(tx *Tx) error {
+		return tx.Remove(url, revision)
+	})
+}
+
+// This is just like Remove, except the change is attributed to origin.
+func (s *Status) RemoveFrom(origin, url string, revision int) (e error) {
+	return s.UpdateFrom(origin, func(tx *Tx) error {
+		return tx.Remove(url, revision)
+	})
+}
+
+// Insert a value into an array. The URL names the new element, such as
+// "status://list/2" to insert before the current third element. The revision
+// is checked against the array.
+func (s *Status) Insert(url string, value interface{}, revision int) (e error) {
+	return s.Update(func(tx *Tx) error {
+		return tx.Insert(url, value, revision)
+	})
+}
+
+// Append a value to the end of the array at URL.
+func (s *Status) Append(url string, value interface{}, revision int) (e error) {
+	return s.Update(func(tx *Tx) error {
+		return tx.Append(url, value, revision)
+	})
+}
+
+// The internal version of Get, which requires the lock to be held.
+func (s *Status) get(url string) (value interface{}, revision int, e error) {
 	pathParts, e := parseUrl(url)
 	if e != nil {
 		return nil, 0, e
@@ -66,40 +154,41 @@ func (s *Status) Get(url string) (value interface{}, revision int, e error) {
 	return
 }
 
-// Set a value from the status as described by the URL. Revision numbers are
-// updated as needed.
-func (s *Status) Set(url string, value interface{}, revision int) (e error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-
-	pathParts, e := parseUrl(url)
-	if e != nil {
-		return e
-	}
-
-	if e := s.validRevision(pathParts, revision); e != nil {
-		return e
+// Set the value of a node, creating it if needed. The revision of all nodes
+// updated is set to newRevision. Changes are recorded in undo. Returns false
+// if the value didn't change.
+//
+// Complex values are compared against the existing value, and only the nodes
+// that actually change receive the new revision.
+func (s *Status) setNode(
+	pathParts []string, value interface{}, newRevision int, undo *undoLog) (changed bool, e error) {
+
+	// If the node already exists, merge the new value into the old one.
+	var oldValue statusValue
+	existing, e := s.urlPathToNodes(pathParts, false)
+	if e == nil {
+		oldValue = existing[len(existing)-1].value
 	}
 
-	// Look up the new revision to update.
-	newRevision := s.revision + 1
-
 	// Covert the new value into internal format.
-	newValue, e := valueToStatusValue(value, newRevision)
+	newValue, changed, e := mergeStatusValue(oldValue, value, newRevision)
 	if e != nil {
-		return e
+		return false, e
+	}
+
+	// If the value isn't changing, no need to update revisions.
+	if existing != nil && !changed {
+		return false, nil
 	}
 
 	// Locate/Create the parent nodes. THIS IS FIRST TREE MODIFICATION.
-	nodes, e := s.urlPathToNodes(pathParts, true)
+	nodes, e := s.walkUrlPath(pathParts, true, undo)
 	if e != nil {
-		return e
+		return false, e
 	}
 
-	// TODO: Handle complex values correctly.
-	// If the value isn't changing, no need to update revisions.
-	if value == nodes[len(nodes)-1].value {
-		return nil
+	for _, v := range nodes {
+		undo.saveNode(v)
 	}
 
 	// Set the new value to the last node found.
@@ -110,48 +199,111 @@ func (s *Status) Set(url string, value interface{}, revision int) (e error) {
 		v.revision = newRevision
 	}
 
-	s.checkWatchers()
-	return nil
+	return true, nil
 }
 
-// Remove a named child from a node.
-func (s *Status) Remove(url string, revision int) (e error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// Remove a node. The revision of all parent nodes is set to newRevision.
+// Changes are recorded in undo. Returns the path to the changed node, which
+// is the parent if array elements were shifted.
+func (s *Status) removeNode(pathParts []string, newRevision int, undo *undoLog) (changed []string, e error) {
+	if len(pathParts) == 0 {
+		return nil, fmt.Errorf("Status: Can't remove %s", urlBase)
+	}
 
-	pathParts, e := parseUrl(url)
+	// urlPathToNodes proves that the node we wish to remove exists.
+	nodes, e := s.urlPathToNodes(pathParts, false)
 	if e != nil {
-		return e
+		return nil, e
 	}
 
-	if e := s.validRevision(pathParts, revision); e != nil {
-		return e
+	// The final node is no longer relevant, since we are about to remove it.
+	nodes = nodes[:len(nodes)-1]
+
+	parent := nodes[len(nodes)-1]
+	name := pathParts[len(pathParts)-1]
+	changed = pathParts
+
+	switch t := parent.value.(type) {
+	case statusMap:
+		child := t[name]
+		delete(t, name)
+		undo.add(func() { t[name] = child })
+	case statusArray:
+		// Later elements shift down, and so change.
+		index, _ := strconv.Atoi(name)
+		undo.saveNode(parent)
+		parent.value = spliceArray(t, index, index+1, nil, newRevision)
+		changed = pathParts[:len(pathParts)-1]
 	}
 
-	// urlPathToNodes proves that the node we wish to remove exists.
+	// Update the revision for all affected nodes.
+	for _, v := range nodes {
+		undo.saveNode(v)
+		v.revision = newRevision
+	}
+
+	return changed, nil
+}
+
+// Insert a new element into an array before index. An index of -1 appends.
+// The revision of the array, all parent nodes, and all shifted elements is set
+// to newRevision. Changes are recorded in undo. Returns the index of the new
+// element.
+func (s *Status) insertNode(
+	pathParts []string, index int, value interface{}, newRevision int, undo *undoLog) (inserted int, e error) {
+
 	nodes, e := s.urlPathToNodes(pathParts, false)
 	if e != nil {
-		return e
+		return 0, e
 	}
 
-	// Look up the new revision to update.
-	newRevision := nodes[0].revision + 1
+	array, ok := nodes[len(nodes)-1].value.(statusArray)
+	if !ok {
+		return 0, fmt.Errorf("Status: Node %s is not an array", joinUrl(pathParts))
+	}
 
-	// The final node is no longer relevant, since we are about to remove it.
-	nodes = nodes[:len(nodes)-1]
+	if index == -1 {
+		index = len(array)
+	}
 
-	// Discover the map in the parent node.
-	parentMap := nodes[len(nodes)-1].value.(statusMap)
+	if index < 0 || index > len(array) {
+		return 0, fmt.Errorf("Status: Index %d out of range for %s", index, joinUrl(pathParts))
+	}
 
-	delete(parentMap, pathParts[len(pathParts)-1])
+	newValue, e := valueToStatusValue(value, newRevision)
+	if e != nil {
+		return 0, e
+	}
 
-	// Update the revision for all affected nodes.
 	for _, v := range nodes {
+		undo.saveNode(v)
 		v.revision = newRevision
 	}
 
-	s.checkWatchers()
-	return nil
+	newNode := &node{revision: newRevision, value: newValue}
+	nodes[len(nodes)-1].value = spliceArray(array, index, index, newNode, newRevision)
+
+	return index, nil
+}
+
+// Create a new array with the elements from start to end replaced by
+// inserted (if not nil). Elements after end move to a new index, so they are
+// recreated with the new revision. The original array is not modified.
+func spliceArray(array statusArray, start, end int, inserted *node, revision int) statusArray {
+	result := make(statusArray, 0, len(array)+1)
+	result = append(result, array[:start]...)
+
+	if inserted != nil {
+		result = append(result, inserted)
+	}
+
+	for _, moved := range array[end:] {
+		// Existing values are always valid.
+		value, _ := valueToStatusValue(statusValueToValue(moved.value), revision)
+		result = append(result, &node{revision: revision, value: value})
+	}
+
+	return result
 }
 
 // The exported version of GetMatchingUrls requires locking.
@@ -162,9 +314,9 @@ func (s *Status) GetMatchingUrls(url string) (matches UrlMatches, e error) {
 	return s.getMatchingUrls(url)
 }
 
-// Does the URL contain wildcards?
+// Does the URL contain wildcards or predicates?
 func CheckForWildcard(url string) (e error) {
-	if strings.Contains(url, "*") {
+	if containsWildcard(url) {
 		return fmt.Errorf("Status: Wildcards not allowed here: %s", url)
 	}
 	return nil
@@ -173,59 +325,17 @@ func CheckForWildcard(url string) (e error) {
 // Find all URLs that exist, which match a wildcard URL.
 //
 // A wildcard URL contains zero or more elements of '*' which match all
-// keys on the relevant node.
+// keys on the relevant node, or '**' which match any number of levels in the
+// tree. Elements may be filtered with predicates, like "*[tripped=true]".
 func (s *Status) getMatchingUrls(url string) (matches UrlMatches, e error) {
-	// Create a slice for fully expanded URLs.
-	matches = UrlMatches{}
-
-	// Create a slice of URLs that haven't been expanded yet.
-	unfinishedUrls := []string{url}
-
-UnfinishedUrls:
-	for len(unfinishedUrls) > 0 {
-		// Pop off the last element for processing.
-		testingUrl := unfinishedUrls[len(unfinishedUrls)-1]
-		unfinishedUrls = unfinishedUrls[:len(unfinishedUrls)-1]
-
-		// Parse the Url.
-		urlPath, e := parseUrl(testingUrl)
-		if e != nil {
-			return nil, e
-		}
-
-		// Walk down the tree looking for matches.
-		current := &s.node
-		for i, v := range urlPath {
-
-			currentMap, ok := current.value.(statusMap)
-			if !ok {
-				continue UnfinishedUrls
-			}
-
-			if v == "*" {
-				expandedPathParts := make([]string, len(urlPath))
-				copy(expandedPathParts, urlPath)
-
-				// Expand the star for each key in the current node.
-				for k := range currentMap {
-					expandedPathParts[i] = k
-					unfinishedUrls = append(unfinishedUrls, joinUrl(expandedPathParts))
-				}
-
-				continue UnfinishedUrls
-			}
-
-			// Step to the next child, if it exists.
-			if current, ok = currentMap[v]; !ok {
-				continue UnfinishedUrls
-			}
-		}
-
-		// If we finished without continuing to the next URL, it's a match.
-		value := statusValueToValue(current.value)
-		matches[joinUrl(urlPath)] = UrlMatch{Revision: current.revision, Value: value}
+	pattern, e := parsePattern(url)
+	if e != nil {
+		return nil, e
 	}
 
+	matches = UrlMatches{}
+	matchPattern(&s.node, []string{}, pattern, nil, matches)
+
 	return matches, nil
 }
 
@@ -268,6 +378,12 @@ func joinUrl(pathParts []string) (url string) {
 // by the URL. If fillInMissing is true, create missing nodes as needed to do
 // this.
 func (s *Status) urlPathToNodes(urlPath []string, fillInMissing bool) (result []*node, e error) {
+	return s.walkUrlPath(urlPath, fillInMissing, nil)
+}
+
+// The implementation of urlPathToNodes. Any nodes created are recorded in
+// undo, if it's not nil.
+func (s *Status) walkUrlPath(urlPath []string, fillInMissing bool, undo *undoLog) (result []*node, e error) {
 
 	// Check for wildcards.
 	if e = CheckForWildcard(joinUrl(urlPath)); e != nil {
@@ -282,9 +398,21 @@ func (s *Status) urlPathToNodes(urlPath []string, fillInMissing bool) (result []
 	for i, u := range urlPath {
 		// If there is nothing at all, and we are creating the path..
 		if fillInMissing && current.value == nil {
+			undo.saveNode(current)
 			current.value = statusMap{}
 		}
 
+		// Array elements must already exist.
+		if _, ok := current.value.(statusArray); ok {
+			if current, ok = childNode(current, u); !ok {
+				return nil, fmt.Errorf(
+					"Status: Node %s of %s does not exist.", joinUrl(urlPath[:i+1]), joinUrl(urlPath))
+			}
+
+			result[i+1] = current
+			continue
+		}
+
 		childMap, ok := current.value.(statusMap)
 		if !ok {
 			return nil, fmt.Errorf(
@@ -296,6 +424,9 @@ func (s *Status) urlPathToNodes(urlPath []string, fillInMissing bool) (result []
 			if fillInMissing {
 				current = &node{value: statusMap{}, revision: s.revision}
 				childMap[u] = current
+
+				name := u
+				undo.add(func() { delete(childMap, name) })
 			} else {
 				return nil, fmt.Errorf(
 					"Status: Node %s of %s does not exist.", joinUrl(urlPath[:i+1]), joinUrl(urlPath))
@@ -323,19 +454,11 @@ func (s *Status) validRevision(urlPath []string, revision int) (e error) {
 			return nil
 		}
 
-		childMap, ok := current.value.(statusMap)
+		child, ok := childNode(current, u)
 		if !ok {
-			// If the current value is nil, that's treated an empty map.
-			if current.value == nil && revision == NONEXISTENT {
-				return nil
-			}
-			break
-		}
-
-		child, ok := childMap[u]
-		if !ok {
-			// If the relevant child doesn't exist, then NONEXISTENT.
-			if revision == NONEXISTENT {
+			// If the relevant child doesn't exist, then NONEXISTENT. If the current
+			// value is nil, that's treated an empty map.
+			if revision == NONEXISTENT && (current.value == nil || isContainer(current)) {
 				return nil
 			}
 			break
@@ -363,18 +486,14 @@ func valueToStatusValue(value interface{}, revision int) (result statusValue, e
 		// Immutable values are simply assigned.
 		result = t
 	case []interface{}:
-		// Verify the array only contains supported values.
-		for _, v := range t {
-			switch element := v.(type) {
-			case bool, float64, int, int64, string, nil, []interface{}, map[string]interface{}:
-			default:
-				return nil, fmt.Errorf("Status: Illegal type: %T in Status array.", element)
-			}
-		}
-		// Duplicate the array.
-		valueArray := make([]statusValue, len(t))
+		// Convert each element of an array.
+		valueArray := make(statusArray, len(t))
 		for i, v := range t {
-			valueArray[i] = v
+			subValue, e := valueToStatusValue(v, revision)
+			if e != nil {
+				return nil, e
+			}
+			valueArray[i] = &node{revision: revision, value: subValue}
 		}
 		result = valueArray
 	case map[string]interface{}:
@@ -395,17 +514,136 @@ func valueToStatusValue(value interface{}, revision int) (result statusValue, e
 	return result, nil
 }
 
+// Convert an external value to a statusValue, like valueToStatusValue, but
+// reuse any nodes in oldValue which are unchanged, so they keep their old
+// revisions. oldValue is not modified. changed is false if the new value is
+// the same as the old one.
+func mergeStatusValue(oldValue statusValue, value interface{}, revision int) (result statusValue, changed bool, e error) {
+	switch old := oldValue.(type) {
+	case statusMap:
+		if valueMap, ok := value.(map[string]interface{}); ok {
+			return mergeStatusMap(old, valueMap, revision)
+		}
+	case statusArray:
+		if valueArray, ok := value.([]interface{}); ok {
+			return mergeStatusArray(old, valueArray, revision)
+		}
+	}
+
+	result, e = valueToStatusValue(value, revision)
+	if e != nil {
+		return nil, false, e
+	}
+
+	return result, !reflect.DeepEqual(statusValueToValue(oldValue), value), nil
+}
+
+// Merge a single child value. Returns the old node if it's unchanged, or a new
+// one if not.
+func mergeNode(oldChild *node, value interface{}, revision int) (result *node, changed bool, e error) {
+	if oldChild == nil {
+		result = &node{revision: revision}
+		result.value, e = valueToStatusValue(value, revision)
+		return result, true, e
+	}
+
+	subValue, changed, e := mergeStatusValue(oldChild.value, value, revision)
+	if e != nil || !changed {
+		return oldChild, false, e
+	}
+
+	return &node{revision: revision, value: subValue}, true, nil
+}
+
+func mergeStatusMap(oldMap statusMap, valueMap map[string]interface{}, revision int) (result statusMap, changed bool, e error) {
+	// Keys that were removed are a change.
+	changed = len(oldMap) != len(valueMap)
+
+	result = statusMap{}
+	for k, v := range valueMap {
+		child, subChanged, e := mergeNode(oldMap[k], v, revision)
+		if e != nil {
+			return nil, false, e
+		}
+
+		result[k] = child
+		changed = changed || subChanged
+	}
+
+	return result, changed, nil
+}
+
+func mergeStatusArray(oldArray statusArray, valueArray []interface{}, revision int) (result statusArray, changed bool, e error) {
+	// Elements that were removed are a change.
+	changed = len(oldArray) != len(valueArray)
+
+	result = make(statusArray, len(valueArray))
+	for i, v := range valueArray {
+		var oldChild *node
+		if i < len(oldArray) {
+			oldChild = oldArray[i]
+		}
+
+		child, subChanged, e := mergeNode(oldChild, v, revision)
+		if e != nil {
+			return nil, false, e
+		}
+
+		result[i] = child
+		changed = changed || subChanged
+	}
+
+	return result, changed, nil
+}
+
+// Find a named child of a node. For arrays, the name is the element index.
+func childNode(n *node, name string) (child *node, ok bool) {
+	switch t := n.value.(type) {
+	case statusMap:
+		child, ok = t[name]
+	case statusArray:
+		i, e := strconv.Atoi(name)
+		if e == nil && i >= 0 && i < len(t) && strconv.Itoa(i) == name {
+			child, ok = t[i], true
+		}
+	}
+	return child, ok
+}
+
+// Can a node have children?
+func isContainer(n *node) bool {
+	switch n.value.(type) {
+	case statusMap, statusArray:
+		return true
+	}
+	return false
+}
+
+// Call f for each child of a node. For arrays, names are element indexes.
+func forEachChild(n *node, f func(name string, child *node)) {
+	switch t := n.value.(type) {
+	case statusMap:
+		for k, child := range t {
+			f(k, child)
+		}
+	case statusArray:
+		for i, child := range t {
+			f(strconv.Itoa(i), child)
+		}
+	}
+}
+
 // Convert an internal value to the external (JSON structure) equivalent.
 func statusValueToValue(value statusValue) (result interface{}) {
 	switch t := value.(type) {
 	case bool, float64, int, int64, string, nil:
 		// Immutable values are simply assigned.
 		result = t
-	case []statusValue:
+	case statusArray:
 		// Convert each sub-value in an array.
 		valueArray := make([]interface{}, len(t))
 		for i, v := range t {
-			valueArray[i] = v
+			valueArray[i] = statusValueToValue(v.value)
 		}
 		result = valueArray
 	case statusMap:
@@ -203,6 +203,175 @@ func (s *MySuite) TestSetInvalidValue(c *check.C) {
 	c.Check(status.value, check.Equals, nil)
 }
 
+func (s *MySuite) TestSetComplexValues(c *check.C) {
+	status := Status{}
+
+	e := status.SetJson("status://",
+		[]byte(`{"a": {"b": 1, "c": [1, 2], "d": {"e": "e"}}, "f": 2}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://a/*")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	// Rewriting identical complex values changes nothing.
+	e = status.SetJson("status://a",
+		[]byte(`{"b": 1, "c": [1, 2], "d": {"e": "e"}}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+	e = status.SetJson("status://",
+		[]byte(`{"a": {"b": 1, "c": [1, 2], "d": {"e": "e"}}, "f": 2}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+
+	CheckRevision(c, &status, "status://", 1)
+	checkNotPending(c, watch)
+
+	// Only the nodes that change get the new revision.
+	e = status.SetJson("status://",
+		[]byte(`{"a": {"b": 1, "c": [1, 3], "d": {"e": "e"}}, "f": 2}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+
+	CheckRevision(c, &status, "status://", 2)
+	CheckRevision(c, &status, "status://a", 2)
+	CheckRevision(c, &status, "status://a/b", 1)
+	CheckRevision(c, &status, "status://a/c", 2)
+	CheckRevision(c, &status, "status://a/d", 1)
+	CheckRevision(c, &status, "status://a/d/e", 1)
+	CheckRevision(c, &status, "status://f", 1)
+
+	checkPending(c, watch, UrlMatches{
+		"status://a/b": UrlMatch{Revision: 1, Value: 1.0},
+		"status://a/c": UrlMatch{Revision: 2, Value: []interface{}{1.0, 3.0}},
+		"status://a/d": UrlMatch{Revision: 1, Value: map[string]interface{}{"e": "e"}},
+	})
+
+	// Adding and removing keys are changes.
+	e = status.SetJson("status://a",
+		[]byte(`{"b": 1, "c": [1, 3], "d": {"e": "e", "g": null}}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+	CheckRevision(c, &status, "status://a/d", 3)
+	CheckRevision(c, &status, "status://a/d/e", 1)
+	CheckRevision(c, &status, "status://a/d/g", 3)
+
+	e = status.SetJson("status://a",
+		[]byte(`{"b": 1, "c": [1, 3]}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+	CheckRevision(c, &status, "status://a", 4)
+	CheckRevision(c, &status, "status://a/b", 1)
+	CheckGetFailure(c, &status, "status://a/d")
+
+	// Replacing a map with a scalar, and back.
+	e = status.Set("status://a", 5, UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+	e = status.SetJson("status://a", []byte(`{"b": 1}`), UNCHECKED_REVISION)
+	c.Check(e, check.IsNil)
+	CheckRevision(c, &status, "status://a/b", 6)
+
+	status.ReleaseWatch(watch)
+}
+
+func (s *MySuite) TestArrayElements(c *check.C) {
+	status := Status{}
+
+	e := status.SetJson("status://list",
+		[]byte(`["a", {"name": "b"}, ["c"]]`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	// Elements can be read and written by index.
+	CheckValue(c, &status, "status://list/0", "a", 1)
+	CheckValue(c, &status, "status://list/1/name", "b", 1)
+	CheckValue(c, &status, "status://list/2/0", "c", 1)
+	CheckGetFailure(c, &status, "status://list/3")
+	CheckGetFailure(c, &status, "status://list/-1")
+	CheckGetFailure(c, &status, "status://list/01")
+	CheckGetFailure(c, &status, "status://list/name")
+
+	c.Check(status.Set("status://list/1/name", "B", 1), check.IsNil)
+	CheckRevision(c, &status, "status://list", 2)
+	CheckRevision(c, &status, "status://list/0", 1)
+	CheckRevision(c, &status, "status://list/1", 2)
+	CheckRevision(c, &status, "status://list/2", 1)
+
+	// Arrays don't grow from Set.
+	c.Check(status.Set("status://list/3", "d", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Set("status://list/3/name", "d", UNCHECKED_REVISION), check.NotNil)
+
+	// Revisions can be checked on elements.
+	c.Check(status.Set("status://list/0", "x", 5), check.NotNil)
+	c.Check(status.Set("status://list/0", "A", 1), check.IsNil)
+	CheckRevision(c, &status, "status://list/0", 3)
+	CheckRevision(c, &status, "status://list/1", 2)
+
+	// Setting an array only changes the changed elements.
+	e = status.SetJson("status://list", []byte(`["A", {"name": "B"}, ["C"]]`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	CheckRevision(c, &status, "status://list/0", 3)
+	CheckRevision(c, &status, "status://list/1", 2)
+	CheckRevision(c, &status, "status://list/2", 4)
+
+	// Invalid values inside of arrays are found.
+	type InvalidValue struct{}
+	c.Check(status.Set("status://list", []interface{}{InvalidValue{}}, UNCHECKED_REVISION), check.NotNil)
+}
+
+func (s *MySuite) TestArrayInsertRemove(c *check.C) {
+	status := Status{}
+
+	e := status.SetJson("status://list", []byte(`["a", "c"]`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+	c.Assert(status.Set("status://other", 1, UNCHECKED_REVISION), check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://list/1")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	// Insert shifts later elements, which changes them.
+	c.Check(status.Insert("status://list/1", "b", 2), check.IsNil)
+	CheckValue(c, &status, "status://list", []interface{}{"a", "b", "c"}, 3)
+	CheckRevision(c, &status, "status://list/0", 1)
+	CheckRevision(c, &status, "status://list/1", 3)
+	CheckRevision(c, &status, "status://list/2", 3)
+	checkPending(c, watch, UrlMatches{"status://list/1": UrlMatch{Revision: 3, Value: "b"}})
+
+	// Append doesn't change existing elements.
+	c.Check(status.Append("status://list", "d", UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Insert("status://list/4", "e", UNCHECKED_REVISION), check.IsNil)
+	CheckValue(c, &status, "status://list", []interface{}{"a", "b", "c", "d", "e"}, 5)
+	CheckRevision(c, &status, "status://list/2", 3)
+	CheckRevision(c, &status, "status://list/3", 4)
+	checkNotPending(c, watch)
+
+	// Remove shifts later elements.
+	c.Check(status.Remove("status://list/1", UNCHECKED_REVISION), check.IsNil)
+	CheckValue(c, &status, "status://list", []interface{}{"a", "c", "d", "e"}, 6)
+	CheckRevision(c, &status, "status://list/0", 1)
+	CheckRevision(c, &status, "status://list/1", 6)
+	checkPending(c, watch, UrlMatches{"status://list/1": UrlMatch{Revision: 6, Value: "c"}})
+
+	// Failures.
+	c.Check(status.Insert("status://list/5", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Insert("status://list/x", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Insert("status://list/-1", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Insert("status://", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Insert("status://list/0", "x", 1), check.NotNil)
+	c.Check(status.Append("status://other", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Append("status://missing", "x", UNCHECKED_REVISION), check.NotNil)
+	c.Check(status.Remove("status://list/4", UNCHECKED_REVISION), check.NotNil)
+
+	// A failed update rolls back array changes.
+	e = status.Update(func(tx *Tx) error {
+		c.Check(tx.Append("status://list", "f", UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Remove("status://list/0", UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Append("status://other", "x", UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+	c.Check(e, check.NotNil)
+	CheckValue(c, &status, "status://list", []interface{}{"a", "c", "d", "e"}, 6)
+	CheckRevision(c, &status, "status://list/1", 6)
+	checkNotPending(c, watch)
+
+	status.ReleaseWatch(watch)
+}
+
 func (s *MySuite) TestIdentity(c *check.C) {
 
 	verifyIdentity := func(value interface{}) {
@@ -301,7 +470,7 @@ func (s *MySuite) TestGetMatchingUrls(c *check.C) {
 	validate("", nil)
 
 	// Test base url.
-	validate("status://", UrlMatches{"status://": {1, tree_value}})
+	validate("status://", UrlMatches{"status://": {Revision: 1, Value: tree_value}})
 
 	// Test non-existent url.
 	validate("status://bogus", UrlMatches{})
@@ -330,7 +499,14 @@ func (s *MySuite) TestGetMatchingUrls(c *check.C) {
 	})
 
 	// Test wildcard url to value children.
-	validate("status://sub1/sub2/array/*", UrlMatches{})
+	validate("status://sub1/sub2/int/*", UrlMatches{})
+
+	// Test wildcard url to array elements.
+	validate("status://sub1/sub2/array/*", UrlMatches{
+		"status://sub1/sub2/array/0": {Revision: 1, Value: 1},
+		"status://sub1/sub2/array/1": {Revision: 1, Value: "foo"},
+		"status://sub1/sub2/array/2": {Revision: 1, Value: 2.5},
+	})
 }
 
 func (s *MySuite) TestGetSetWildcardNotAllowed(c *check.C) {
@@ -1,7 +1,18 @@
 package status
 
+import (
+	"github.com/DonGar/go-house/clock"
+)
+
+// Anything indexed in the watch trie, which is rechecked after changes which
+// may affect its URL. Watchers and histories.
+type urlChecker interface {
+	checkForUpdate(status *Status)
+}
+
 type watcher struct {
 	watchUrl      string          // Wildcard URL to watch.
+	watchPath     []string        // Parsed version of watchUrl.
 	lastSeen      map[string]int  // Map expanded URL to revision of last value.
 	updateChannel chan UrlMatches // Channel to notify clients.
 }
@@ -11,7 +22,13 @@ type watcher struct {
 // change affecting the specified URL. If multiple updates happen and the
 // channel is not read from, then intermediate updates may be lost.
 func (s *Status) WatchForUpdate(url string) (<-chan UrlMatches, error) {
-	if _, e := parseUrl(url); e != nil {
+	watchPath, e := parseUrl(url)
+	if e != nil {
+		return nil, e
+	}
+
+	// Make sure any wildcards and predicates are valid.
+	if _, e := parsePattern(url); e != nil {
 		return nil, e
 	}
 
@@ -23,12 +40,14 @@ func (s *Status) WatchForUpdate(url string) (<-chan UrlMatches, error) {
 
 	w := &watcher{
 		watchUrl:      url,
+		watchPath:     watchPath,
 		lastSeen:      map[string]int{"bad_url_to_force_no_match": 0},
 		updateChannel: notifyChannel,
 	}
 
 	// Add new watcher to Status.
 	s.watchers = append(s.watchers, w)
+	s.watchIndex.add(watchPath, w)
 
 	// Do an initial look for updates to populate lastSeen, and send initial
 	// update notifcation.
@@ -47,6 +66,15 @@ func (s *Status) ReleaseWatch(wc <-chan UrlMatches) {
 	for _, w := range s.watchers {
 		if wc != w.updateChannel {
 			trimmedWatchers = append(trimmedWatchers, w)
+		} else {
+			s.watchIndex.remove(w.watchPath, w)
+
+			// An unread notification won't be handled now.
+			select {
+			case <-w.updateChannel:
+				clock.Handled(s.getClock())
+			default:
+			}
 		}
 	}
 
@@ -89,15 +117,123 @@ func (w *watcher) checkForUpdate(status *Status) {
 		select {
 		case <-w.updateChannel: // Clear the channel if it hasn't been read from.
 		default: // Read nothing if it's empty.
+			clock.Sent(status.getClock())
 		}
 
 		w.updateChannel <- matches
 	}
 }
 
-// Method to check all watchers to see if notifications should be sent.
-func (s *Status) checkWatchers() {
-	for _, w := range s.watchers {
+// Method to check watchers to see if notifications should be sent, and
+// histories to see if values should be recorded. Only those whose URLs
+// overlap one of the changed paths are checked.
+func (s *Status) checkWatchers(changed [][]string) {
+	found := map[urlChecker]bool{}
+	for _, path := range changed {
+		s.watchIndex.collect(path, found)
+	}
+
+	for w := range found {
 		w.checkForUpdate(s)
 	}
 }
+
+// A trie of watchers and histories, indexed by the elements of their wildcard
+// URLs.
+// Elements with wildcards or predicates are kept apart from plain names, since
+// they have to be matched one by one.
+type watchNode struct {
+	children     map[string]*watchNode
+	wildChildren map[string]*watchNode
+	segment      segment      // Parsed element leading to this node.
+	watchers     []urlChecker // Watchers whose URLs end at this node.
+}
+
+func (n *watchNode) childMap(part string) *map[string]*watchNode {
+	if containsWildcard(part) {
+		return &n.wildChildren
+	}
+	return &n.children
+}
+
+func (n *watchNode) add(path []string, w urlChecker) {
+	if len(path) == 0 {
+		n.watchers = append(n.watchers, w)
+		return
+	}
+
+	children := n.childMap(path[0])
+	if *children == nil {
+		*children = map[string]*watchNode{}
+	}
+
+	child, ok := (*children)[path[0]]
+	if !ok {
+		// The URL was validated by the watcher.
+		seg, _ := parseSegment(path[0])
+		child = &watchNode{segment: seg}
+		(*children)[path[0]] = child
+	}
+
+	child.add(path[1:], w)
+}
+
+// Remove a watcher, and prune any nodes left empty. Returns true if this node
+// is now empty.
+func (n *watchNode) remove(path []string, w urlChecker) bool {
+	if len(path) == 0 {
+		for i, v := range n.watchers {
+			if v == w {
+				n.watchers = append(n.watchers[:i], n.watchers[i+1:]...)
+				break
+			}
+		}
+	} else {
+		children := *n.childMap(path[0])
+		if child, ok := children[path[0]]; ok && child.remove(path[1:], w) {
+			delete(children, path[0])
+		}
+	}
+
+	return len(n.watchers) == 0 && len(n.children) == 0 && len(n.wildChildren) == 0
+}
+
+// Find all watchers that can be affected by a change to path. Changing a node
+// updates the revisions of its parents and the values of its children, so
+// that's any watcher whose URL matches a parent of path, path itself, or
+// something inside of path.
+//
+// Predicates are ignored here, since they only depend on values inside of the
+// nodes they filter.
+func (n *watchNode) collect(path []string, found map[urlChecker]bool) {
+	for _, w := range n.watchers {
+		found[w] = true
+	}
+
+	if len(path) == 0 {
+		for _, child := range n.children {
+			child.collect(path, found)
+		}
+		for _, child := range n.wildChildren {
+			child.collect(path, found)
+		}
+		return
+	}
+
+	if child, ok := n.children[path[0]]; ok {
+		child.collect(path[1:], found)
+	}
+
+	for _, child := range n.wildChildren {
+		switch child.segment.name {
+		case "**":
+			// ** may match any number of elements of path.
+			for i := range path {
+				child.collect(path[i:], found)
+			}
+			child.collect(nil, found)
+		case "*", path[0]:
+			child.collect(path[1:], found)
+		}
+	}
+}
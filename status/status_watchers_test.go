@@ -2,7 +2,9 @@ package status
 
 import (
 	"encoding/json"
+	"fmt"
 	"gopkg.in/check.v1"
+	"testing"
 )
 
 // Compare two UrlMatches structures since DeepEquals can't.
@@ -205,3 +207,93 @@ func (s *MySuite) TestNoReadDeadlock(c *check.C) {
 	e = status.SetJson("status://foo", []byte("2"), UNCHECKED_REVISION)
 	c.Check(e, check.IsNil)
 }
+
+func (s *MySuite) TestWatchIndex(c *check.C) {
+	index := watchNode{}
+
+	a := &watcher{watchUrl: "status://a"}
+	ab := &watcher{watchUrl: "status://a/b"}
+	star := &watcher{watchUrl: "status://*/c"}
+	root := &watcher{watchUrl: "status://"}
+
+	for _, w := range []*watcher{a, ab, star, root} {
+		w.watchPath, _ = parseUrl(w.watchUrl)
+		index.add(w.watchPath, w)
+	}
+
+	checkCollect := func(url string, expected ...*watcher) {
+		path, e := parseUrl(url)
+		c.Assert(e, check.IsNil)
+
+		found := map[urlChecker]bool{}
+		index.collect(path, found)
+
+		c.Check(len(found), check.Equals, len(expected), check.Commentf(url))
+		for _, w := range expected {
+			c.Check(found[w], check.Equals, true, check.Commentf("%s: %s", url, w.watchUrl))
+		}
+	}
+
+	checkCollect("status://", a, ab, star, root)
+	checkCollect("status://a", a, ab, star, root)
+	checkCollect("status://a/b/deep", a, ab, root)
+	checkCollect("status://a/c", a, star, root)
+	checkCollect("status://d", star, root)
+	checkCollect("status://d/c/deep", star, root)
+	checkCollect("status://d/e", root)
+
+	c.Check(index.remove(ab.watchPath, ab), check.Equals, false)
+	c.Check(index.remove(star.watchPath, star), check.Equals, false)
+	checkCollect("status://a/c", a, root)
+	c.Check(len(index.children), check.Equals, 1)
+	c.Check(len(index.children["a"].children), check.Equals, 0)
+
+	c.Check(index.remove(a.watchPath, a), check.Equals, false)
+	c.Check(index.remove(root.watchPath, root), check.Equals, true)
+}
+
+// Changes to unrelated URLs don't recheck a watcher.
+func (s *MySuite) TestWatchUnrelatedChange(c *check.C) {
+	status := Status{}
+
+	wc, e := status.WatchForUpdate("status://a/*/c")
+	c.Assert(e, check.IsNil)
+	<-wc
+
+	// Make the watcher's view of the world stale, so any check would notify.
+	status.watchers[0].lastSeen = map[string]int{}
+
+	c.Check(status.Set("status://b/c", 1, UNCHECKED_REVISION), check.IsNil)
+	c.Check(status.Set("status://a/b/d", 1, UNCHECKED_REVISION), check.IsNil)
+	checkNotPending(c, wc)
+
+	c.Check(status.Set("status://a/b/c", 1, UNCHECKED_REVISION), check.IsNil)
+	checkPending(c, wc, UrlMatches{"status://a/b/c": UrlMatch{Revision: 3, Value: 1}})
+
+	status.ReleaseWatch(wc)
+	c.Check(len(status.watchIndex.children), check.Equals, 0)
+}
+
+// Set a value while many unrelated watchers exist.
+func benchmarkSetWithWatchers(b *testing.B, count int) {
+	status := Status{}
+
+	for i := 0; i < count; i++ {
+		url := fmt.Sprintf("status://devices/device%d/*", i)
+		if _, e := status.WatchForUpdate(url); e != nil {
+			b.Fatal(e)
+		}
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if e := status.Set("status://devices/device0/value", i, UNCHECKED_REVISION); e != nil {
+			b.Fatal(e)
+		}
+	}
+}
+
+func BenchmarkSetWith10Watchers(b *testing.B)   { benchmarkSetWithWatchers(b, 10) }
+func BenchmarkSetWith100Watchers(b *testing.B)  { benchmarkSetWithWatchers(b, 100) }
+func BenchmarkSetWith1000Watchers(b *testing.B) { benchmarkSetWithWatchers(b, 1000) }
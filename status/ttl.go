@@ -0,0 +1,196 @@
+package status
+
+import (
+	"fmt"
+	"github.com/DonGar/go-house/clock"
+	"time"
+)
+
+// A pending expiration of a value set with a TTL.
+type expiry struct {
+	pathParts   []string
+	ttl         time.Duration
+	fallback    interface{}
+	hasFallback bool
+
+	revision int       // Revision of the node when the TTL was set.
+	deadline time.Time // When the value expires.
+	timer    *clock.Timer
+	stop     chan bool // Closed when the expiration is cancelled.
+}
+
+// Set a value which is removed after ttl, unless it's set again first.
+// Watchers are notified of the removal normally.
+func (s *Status) SetWithTTL(url string, value interface{}, revision int, ttl time.Duration) (e error) {
+	return s.Update(func(tx *Tx) error {
+		return tx.SetWithTTL(url, value, revision, ttl)
+	})
+}
+
+// Set a value which is reset to fallback after ttl, unless it's set again
+// first. Watchers are notified of the reset normally.
+func (s *Status) SetWithFallback(
+	url string, value interface{}, revision int, ttl time.Duration, fallback interface{}) (e error) {
+
+	return s.Update(func(tx *Tx) error {
+		return tx.SetWithFallback(url, value, revision, ttl, fallback)
+	})
+}
+
+// Queue a Set which expires after ttl.
+func (tx *Tx) SetWithTTL(url string, value interface{}, revision int, ttl time.Duration) (e error) {
+	return tx.setExpiring(url, value, revision, &expiry{ttl: ttl})
+}
+
+// Queue a Set which is reset to fallback after ttl.
+func (tx *Tx) SetWithFallback(
+	url string, value interface{}, revision int, ttl time.Duration, fallback interface{}) (e error) {
+
+	return tx.setExpiring(url, value, revision, &expiry{ttl: ttl, fallback: fallback, hasFallback: true})
+}
+
+func (tx *Tx) setExpiring(url string, value interface{}, revision int, exp *expiry) (e error) {
+	if exp.ttl <= 0 {
+		return fmt.Errorf("Status: Invalid TTL %s for %s", exp.ttl, url)
+	}
+
+	if exp.hasFallback {
+		// Make sure the fallback can be stored, before it's needed.
+		if _, e = valueToStatusValue(exp.fallback, 0); e != nil {
+			return e
+		}
+	}
+
+	if e = tx.Set(url, value, revision); e != nil {
+		return e
+	}
+
+	exp.pathParts = tx.ops[len(tx.ops)-1].pathParts
+	tx.ops[len(tx.ops)-1].expire = exp
+	return nil
+}
+
+// Start, restart, or cancel expirations for a successful group of changes.
+// Setting a value without a TTL, or removing it, cancels any pending
+// expiration. Called with the Status lock held.
+func (s *Status) updateExpiries(ops []txOp) {
+	for _, op := range ops {
+		if op.kind == opInsert {
+			continue
+		}
+
+		url := joinUrl(op.pathParts)
+
+		if old, ok := s.expiries[url]; ok {
+			old.timer.Stop()
+			close(old.stop)
+			delete(s.expiries, url)
+
+			if op.expire == nil && s.persister != nil {
+				s.persister.recordExpiry(op.pathParts, nil, s)
+			}
+		}
+
+		if op.expire == nil {
+			continue
+		}
+
+		// The node exists, since the Set succeeded.
+		nodes, _ := s.urlPathToNodes(op.pathParts, false)
+
+		clk := s.getClock()
+
+		exp := *op.expire
+		exp.revision = nodes[len(nodes)-1].revision
+		exp.deadline = clk.Now().Add(exp.ttl)
+		exp.timer = clk.NewTimer(exp.ttl)
+		exp.stop = make(chan bool)
+
+		if s.expiries == nil {
+			s.expiries = map[string]*expiry{}
+		}
+		s.expiries[url] = &exp
+
+		if s.persister != nil {
+			s.persister.recordExpiry(op.pathParts, &exp, s)
+		}
+
+		go s.waitToExpire(&exp, clk)
+	}
+}
+
+// The expiration, as saved by persistence.
+func (exp *expiry) saved() savedExpiry {
+	return savedExpiry{exp.deadline, exp.fallback, exp.hasFallback}
+}
+
+func (s *Status) waitToExpire(exp *expiry, clk clock.Clock) {
+	select {
+	case <-exp.timer.C:
+		s.expire(exp)
+		clock.Handled(clk)
+	case <-exp.stop:
+	}
+}
+
+// Restart expirations saved by persistence, after the values are restored.
+// Values whose time passed while we were down expire right away.
+func (s *Status) restoreExpiries(saved map[string]savedExpiry) (e error) {
+	s.lock.RLock()
+	now := s.getClock().Now()
+	s.lock.RUnlock()
+
+	for url, exp := range saved {
+		value, revision, getErr := s.Get(url)
+		if getErr != nil {
+			continue
+		}
+
+		ttl := exp.Time.Sub(now)
+
+		switch {
+		case ttl <= 0 && exp.HasFallback:
+			e = s.SetFrom("ttl", url, exp.Fallback, revision)
+		case ttl <= 0:
+			e = s.RemoveFrom("ttl", url, revision)
+		case exp.HasFallback:
+			e = s.UpdateFrom("ttl", func(tx *Tx) error {
+				return tx.SetWithFallback(url, value, revision, ttl, exp.Fallback)
+			})
+		default:
+			e = s.UpdateFrom("ttl", func(tx *Tx) error {
+				return tx.SetWithTTL(url, value, revision, ttl)
+			})
+		}
+
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// Expire a value, if it hasn't been refreshed or changed some other way since
+// its TTL was set. The change is attributed to the origin "ttl".
+func (s *Status) expire(exp *expiry) {
+	url := joinUrl(exp.pathParts)
+
+	s.UpdateFrom("ttl", func(tx *Tx) error {
+		if s.expiries[url] != exp {
+			return nil
+		}
+
+		_, revision, e := tx.Get(url)
+		if e != nil || revision != exp.revision {
+			// Something else changed the value. Leave it alone.
+			delete(s.expiries, url)
+			return nil
+		}
+
+		if exp.hasFallback {
+			return tx.Set(url, exp.fallback, revision)
+		}
+		return tx.Remove(url, revision)
+	})
+}
@@ -0,0 +1,134 @@
+package status
+
+import (
+	"github.com/DonGar/go-house/clock"
+	"gopkg.in/check.v1"
+	"time"
+)
+
+const testTTL = 20 * time.Millisecond
+
+// Wait for a watch notification, failing if it takes too long.
+func waitPending(c *check.C, wc <-chan UrlMatches, expected UrlMatches) {
+	select {
+	case received := <-wc:
+		compareUrlMatches(c, received, expected)
+	case <-time.After(time.Second):
+		c.Fatal("Nothing received")
+	}
+}
+
+func (s *MySuite) TestTTLRemove(c *check.C) {
+	status := &Status{}
+
+	c.Assert(status.SetWithTTL("status://a/motion", true, UNCHECKED_REVISION, testTTL), check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://a/*")
+	c.Assert(e, check.IsNil)
+	checkPending(c, watch, UrlMatches{"status://a/motion": UrlMatch{Revision: 1, Value: true}})
+
+	// Expires, and watchers see it.
+	waitPending(c, watch, UrlMatches{})
+	CheckGetFailure(c, status, "status://a/motion")
+	CheckRevision(c, status, "status://a", 2)
+	c.Check(len(status.expiries), check.Equals, 0)
+
+	status.ReleaseWatch(watch)
+}
+
+func (s *MySuite) TestTTLFallback(c *check.C) {
+	status := &Status{}
+
+	e := status.SetWithFallback("status://home", true, UNCHECKED_REVISION, testTTL, false)
+	c.Assert(e, check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://home")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	waitPending(c, watch, UrlMatches{"status://home": UrlMatch{Revision: 2, Value: false, Origin: "ttl"}})
+
+	// The fallback doesn't expire.
+	time.Sleep(2 * testTTL)
+	CheckValue(c, status, "status://home", false, 2)
+
+	status.ReleaseWatch(watch)
+}
+
+func (s *MySuite) TestTTLRefresh(c *check.C) {
+	status := &Status{}
+
+	c.Assert(status.SetWithTTL("status://phone", "here", UNCHECKED_REVISION, testTTL), check.IsNil)
+
+	// Refreshing the same value pushes back expiration, without a new revision.
+	for i := 0; i < 4; i++ {
+		time.Sleep(testTTL / 2)
+		c.Assert(status.SetWithTTL("status://phone", "here", UNCHECKED_REVISION, testTTL), check.IsNil)
+	}
+	CheckValue(c, status, "status://phone", "here", 1)
+
+	time.Sleep(3 * testTTL)
+	CheckGetFailure(c, status, "status://phone")
+}
+
+func (s *MySuite) TestTTLCancel(c *check.C) {
+	status := &Status{}
+
+	// A normal Set cancels the TTL.
+	c.Assert(status.SetWithTTL("status://a", 1, UNCHECKED_REVISION, testTTL), check.IsNil)
+	c.Assert(status.Set("status://a", 1, UNCHECKED_REVISION), check.IsNil)
+
+	// A change by any other means cancels the TTL.
+	c.Assert(status.SetWithTTL("status://b/c", 1, UNCHECKED_REVISION, testTTL), check.IsNil)
+	c.Assert(status.Set("status://b", map[string]interface{}{"c": 2}, UNCHECKED_REVISION), check.IsNil)
+
+	// Removing and recreating also cancels it.
+	c.Assert(status.SetWithTTL("status://d", 1, UNCHECKED_REVISION, testTTL), check.IsNil)
+	c.Assert(status.Remove("status://d", UNCHECKED_REVISION), check.IsNil)
+	c.Assert(status.Set("status://d", 2, UNCHECKED_REVISION), check.IsNil)
+
+	time.Sleep(3 * testTTL)
+	c.Check(status.PrettyDump("status://"), check.Equals, NormalizeJson(`{"a": 1, "b": {"c": 2}, "d": 2}`))
+	c.Check(len(status.expiries), check.Equals, 0)
+}
+
+func (s *MySuite) TestTTLClock(c *check.C) {
+	clk := clock.NewFake(time.Date(2014, time.June, 12, 10, 0, 0, 0, time.UTC))
+
+	status := &Status{}
+	status.SetClock(clk)
+
+	c.Assert(status.SetWithTTL("status://a", 1, UNCHECKED_REVISION, time.Minute), check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://a")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	clk.Advance(59 * time.Second)
+	checkNotPending(c, watch)
+
+	clk.Advance(time.Second)
+	waitPending(c, watch, UrlMatches{})
+	c.Check(clk.PendingTimers(), check.Equals, 0)
+
+	status.ReleaseWatch(watch)
+}
+
+func (s *MySuite) TestTTLFailures(c *check.C) {
+	status := &Status{}
+
+	type InvalidValue struct{}
+
+	c.Check(status.SetWithTTL("status://a", 1, UNCHECKED_REVISION, 0), check.NotNil)
+	c.Check(status.SetWithTTL("status://a", 1, 22, testTTL), check.NotNil)
+	c.Check(status.SetWithFallback("status://a", 1, UNCHECKED_REVISION, testTTL, InvalidValue{}), check.NotNil)
+
+	// A failed update doesn't start an expiration.
+	e := status.Update(func(tx *Tx) error {
+		c.Check(tx.SetWithTTL("status://a", 1, UNCHECKED_REVISION, testTTL), check.IsNil)
+		c.Check(tx.Remove("status://missing", UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+	c.Check(e, check.NotNil)
+	c.Check(len(status.expiries), check.Equals, 0)
+}
@@ -0,0 +1,286 @@
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// A group of changes applied together by Status.Update.
+type Tx struct {
+	status *Status
+	origin string
+	ops    []txOp
+}
+
+// A single queued change.
+type txOp struct {
+	kind      opKind
+	pathParts []string // For inserts, the path to the array.
+	index     int      // For inserts, the new element index. -1 to append.
+	value     interface{}
+	revision  int
+	expire    *expiry // For sets, nil unless the value has a TTL.
+}
+
+type opKind int
+
+const (
+	opSet opKind = iota
+	opRemove
+	opInsert
+)
+
+// Changes made to the tree, recorded so they can be rolled back if a later
+// change in the same update fails.
+type undoLog []func()
+
+// Apply a group of changes atomically. update is called with the Status
+// locked, and queues changes on tx. Then revisions for every change are
+// validated against the status as it was before the update. If they are all
+// valid, the changes are applied with a single new revision, and watchers are
+// notified once.
+//
+// If update returns an error, or any change fails, nothing is modified.
+// update must only use tx, not the Status itself, or it will deadlock.
+func (s *Status) Update(update func(tx *Tx) error) (e error) {
+	return s.UpdateFrom("", update)
+}
+
+// This is just like Update, except the changes are attributed to origin, which
+// describes who made them, such as "rule:porch_light" or "adapter:vera".
+// Origins are recorded with revisions, and in the audit log.
+func (s *Status) UpdateFrom(origin string, update func(tx *Tx) error) (e error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	tx := &Tx{status: s, origin: origin}
+	if e = update(tx); e != nil {
+		return e
+	}
+
+	return tx.commit()
+}
+
+// Get a value, as it was before the update began. Queued changes are not
+// visible.
+func (tx *Tx) Get(url string) (value interface{}, revision int, e error) {
+	return tx.status.get(url)
+}
+
+// Queue a Set. Revision is checked when the update is committed.
+func (tx *Tx) Set(url string, value interface{}, revision int) (e error) {
+	pathParts, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	if e = CheckForWildcard(url); e != nil {
+		return e
+	}
+
+	tx.ops = append(tx.ops, txOp{opSet, pathParts, 0, value, revision, nil})
+	return nil
+}
+
+// This is just like Set, except it accepts the value to set in Json format.
+func (tx *Tx) SetJson(url string, valueJson []byte, revision int) (e error) {
+	var value interface{}
+	e = json.Unmarshal(valueJson, &value)
+	if e != nil {
+		return e
+	}
+
+	return tx.Set(url, value, revision)
+}
+
+// This is just like Set, except that data which is valid Json is decoded, and
+// anything else is set as a plain string.
+func (tx *Tx) SetJsonOrString(url, data string, revision int) (e error) {
+	var value interface{}
+	if e = json.Unmarshal([]byte(data), &value); e != nil {
+		value = data
+	}
+
+	return tx.Set(url, value, revision)
+}
+
+// Queue a Remove. Revision is checked when the update is committed.
+func (tx *Tx) Remove(url string, revision int) (e error) {
+	pathParts, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	if e = CheckForWildcard(url); e != nil {
+		return e
+	}
+
+	tx.ops = append(tx.ops, txOp{opRemove, pathParts, 0, nil, revision, nil})
+	return nil
+}
+
+// Queue an Insert. The URL names the new array element. Revision is checked
+// against the array when the update is committed.
+func (tx *Tx) Insert(url string, value interface{}, revision int) (e error) {
+	pathParts, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	if e = CheckForWildcard(url); e != nil {
+		return e
+	}
+
+	if len(pathParts) == 0 {
+		return fmt.Errorf("Status: Can't insert at %s", url)
+	}
+
+	last := len(pathParts) - 1
+	index, e := strconv.Atoi(pathParts[last])
+	if e != nil || index < 0 {
+		return fmt.Errorf("Status: Invalid array index in %s", url)
+	}
+
+	tx.ops = append(tx.ops, txOp{opInsert, pathParts[:last], index, value, revision, nil})
+	return nil
+}
+
+// Queue an Append to the array at URL. Revision is checked against the array
+// when the update is committed.
+func (tx *Tx) Append(url string, value interface{}, revision int) (e error) {
+	pathParts, e := parseUrl(url)
+	if e != nil {
+		return e
+	}
+
+	if e = CheckForWildcard(url); e != nil {
+		return e
+	}
+
+	tx.ops = append(tx.ops, txOp{opInsert, pathParts, -1, value, revision, nil})
+	return nil
+}
+
+// Apply all queued changes, or none of them. Called with the Status lock held.
+func (tx *Tx) commit() (e error) {
+	s := tx.status
+
+	if e = s.checkReadOnly(tx.ops, tx.origin); e != nil {
+		return e
+	}
+
+	// Check all revisions before changing anything.
+	for _, op := range tx.ops {
+		if e = s.validRevision(op.pathParts, op.revision); e != nil {
+			return e
+		}
+	}
+
+	// Look up the new revision to update.
+	newRevision := s.revision + 1
+
+	undo := undoLog{}
+	applied := []txOp{}
+	changed := [][]string{}
+
+	for _, op := range tx.ops {
+		var changedPath []string
+
+		switch op.kind {
+		case opSet:
+			var nodeChanged bool
+			nodeChanged, e = s.setNode(op.pathParts, op.value, newRevision, &undo)
+			if nodeChanged {
+				changedPath = op.pathParts
+			}
+		case opRemove:
+			changedPath, e = s.removeNode(op.pathParts, newRevision, &undo)
+		case opInsert:
+			op.index, e = s.insertNode(op.pathParts, op.index, op.value, newRevision, &undo)
+			changedPath = op.pathParts
+		}
+
+		if e != nil {
+			undo.rollback()
+			return e
+		}
+
+		if changedPath != nil {
+			applied = append(applied, op)
+			changed = append(changed, changedPath)
+		}
+	}
+
+	// Make sure the results are allowed, before anything else sees them.
+	rejected, flagged := s.checkSchemas(changed)
+	if rejected != nil {
+		undo.rollback()
+		return rejected
+	}
+	logSchemaErrors(flagged)
+
+	if len(applied) != 0 {
+		setOrigin(&s.node, newRevision, tx.origin)
+		s.recordAudit(applied, newRevision, tx.origin)
+	}
+
+	if s.persister != nil {
+		for _, op := range applied {
+			switch op.kind {
+			case opSet:
+				s.persister.recordSet(op.pathParts, op.value, s)
+			case opRemove:
+				s.persister.recordRemove(op.pathParts, s)
+			case opInsert:
+				path := childPath(op.pathParts, strconv.Itoa(op.index))
+				s.persister.recordInsert(path, op.value, s)
+			}
+		}
+	}
+
+	// Refreshing a TTL counts, even if the value didn't change. This comes
+	// after the changes are journaled, since setting a value cancels it's old
+	// expiration.
+	s.updateExpiries(tx.ops)
+
+	// If nothing changed, nobody needs to know.
+	if len(applied) == 0 {
+		return nil
+	}
+
+	s.checkWatchers(changed)
+	return nil
+}
+
+func (u *undoLog) add(f func()) {
+	if u != nil {
+		*u = append(*u, f)
+	}
+}
+
+// Remember the current contents of a node, so they can be restored.
+func (u *undoLog) saveNode(n *node) {
+	value, revision, origin := n.value, n.revision, n.origin
+	u.add(func() { n.value, n.revision, n.origin = value, revision, origin })
+}
+
+// Record origin on every node changed in newRevision. Since the parents of a
+// changed node always change too, only they need to be searched.
+func setOrigin(n *node, newRevision int, origin string) {
+	if n.revision != newRevision {
+		return
+	}
+
+	n.origin = origin
+	forEachChild(n, func(_ string, child *node) {
+		setOrigin(child, newRevision, origin)
+	})
+}
+
+// Undo all recorded changes, newest first.
+func (u undoLog) rollback() {
+	for i := len(u) - 1; i >= 0; i-- {
+		u[i]()
+	}
+}
@@ -0,0 +1,128 @@
+package status
+
+import (
+	"fmt"
+	"gopkg.in/check.v1"
+)
+
+func (s *MySuite) TestUpdate(c *check.C) {
+	status := &Status{}
+
+	e := status.SetJson("status://", []byte(`{"a": 1, "b": {"c": 2}}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	watch, e := status.WatchForUpdate("status://")
+	c.Assert(e, check.IsNil)
+	<-watch
+
+	e = status.Update(func(tx *Tx) error {
+		// Reads see values from before the update.
+		value, revision, e := tx.Get("status://a")
+		c.Check(value, check.Equals, 1.0)
+		c.Check(revision, check.Equals, 1)
+		c.Check(e, check.IsNil)
+
+		c.Check(tx.Set("status://a", 3, 1), check.IsNil)
+		c.Check(tx.Set("status://d/e", "new", NONEXISTENT), check.IsNil)
+		c.Check(tx.Remove("status://b/c", 1), check.IsNil)
+
+		value, _, _ = tx.Get("status://a")
+		c.Check(value, check.Equals, 1.0)
+		return nil
+	})
+	c.Check(e, check.IsNil)
+
+	// All changes share a single revision.
+	CheckValue(c, status, "status://",
+		map[string]interface{}{
+			"a": 3,
+			"b": map[string]interface{}{},
+			"d": map[string]interface{}{"e": "new"},
+		},
+		2)
+	CheckRevision(c, status, "status://a", 2)
+	CheckRevision(c, status, "status://b", 2)
+	CheckRevision(c, status, "status://d/e", 2)
+
+	// Watchers are notified once.
+	checkPending(c, watch, UrlMatches{"status://": UrlMatch{Revision: 2, Value: map[string]interface{}{
+		"a": 3,
+		"b": map[string]interface{}{},
+		"d": map[string]interface{}{"e": "new"},
+	}}})
+	checkNotPending(c, watch)
+
+	status.ReleaseWatch(watch)
+}
+
+func (s *MySuite) TestUpdateFailures(c *check.C) {
+	status := &Status{}
+
+	e := status.SetJson("status://", []byte(`{"a": 1, "b": {"c": 2}}`), UNCHECKED_REVISION)
+	c.Assert(e, check.IsNil)
+
+	expected := status.PrettyDump("status://")
+
+	checkUnchanged := func(update func(tx *Tx) error) {
+		c.Check(status.Update(update), check.NotNil)
+		c.Check(status.PrettyDump("status://"), check.Equals, expected)
+		CheckRevision(c, status, "status://", 1)
+	}
+
+	// The update function fails.
+	checkUnchanged(func(tx *Tx) error {
+		c.Check(tx.Set("status://a", 2, UNCHECKED_REVISION), check.IsNil)
+		return fmt.Errorf("Failure")
+	})
+
+	// Bad URLs.
+	checkUnchanged(func(tx *Tx) error {
+		return tx.Set("bogus", 2, UNCHECKED_REVISION)
+	})
+	checkUnchanged(func(tx *Tx) error {
+		return tx.Remove("status://*", UNCHECKED_REVISION)
+	})
+
+	// A later revision check fails.
+	checkUnchanged(func(tx *Tx) error {
+		c.Check(tx.Set("status://a", 2, 1), check.IsNil)
+		c.Check(tx.Set("status://b/c", 2, 22), check.IsNil)
+		return nil
+	})
+
+	// A later change fails, after earlier changes were applied.
+	checkUnchanged(func(tx *Tx) error {
+		c.Check(tx.Set("status://new/value", 2, UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Remove("status://b", UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Set("status://a/sub", 2, UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+
+	// Removing something that doesn't exist.
+	checkUnchanged(func(tx *Tx) error {
+		c.Check(tx.Set("status://a", 5, UNCHECKED_REVISION), check.IsNil)
+		c.Check(tx.Remove("status://bogus", UNCHECKED_REVISION), check.IsNil)
+		return nil
+	})
+
+	// Removing the root.
+	checkUnchanged(func(tx *Tx) error {
+		return tx.Remove("status://", UNCHECKED_REVISION)
+	})
+}
+
+func (s *MySuite) TestUpdateNoChange(c *check.C) {
+	status := &Status{}
+
+	c.Assert(status.Set("status://a", 1, UNCHECKED_REVISION), check.IsNil)
+
+	e := status.Update(func(tx *Tx) error {
+		return tx.Set("status://a", 1, UNCHECKED_REVISION)
+	})
+	c.Check(e, check.IsNil)
+	CheckRevision(c, status, "status://", 1)
+
+	e = status.Update(func(tx *Tx) error { return nil })
+	c.Check(e, check.IsNil)
+	CheckRevision(c, status, "status://", 1)
+}